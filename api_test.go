@@ -405,6 +405,7 @@ func TestApi_PostConfigReload(t *testing.T) {
 		defer s.Close()
 		testURL, _ := url.Parse(s.URL)
 		os.Setenv("IMUP_REALTIME_CONFIG", testURL.String())
+		os.Setenv("IMUP_REALTIME_CONFIG_ACK", testURL.String())
 		os.Setenv("API_KEY", c.ApiKey)
 		os.Setenv("EMAIL", c.Email)
 		os.Setenv("HOST_ID", c.HostID)
@@ -433,6 +434,44 @@ func TestApi_PostConfigReload(t *testing.T) {
 	}
 }
 
+// imup.RealtimeConfigAck = getEnv("IMUP_REALTIME_CONFIG_ACK", "https://api.imup.io/v1/realtime/configApplied")
+func TestApi_PostConfigApplied(t *testing.T) {
+	cases := []struct {
+		ApiKey   string
+		Email    string
+		EndPoint string
+		HostID   string
+		Version  string
+		RetCode  int
+		Type     string
+	}{
+		{ApiKey: "1234", HostID: "homer", Version: "dev-preview", Email: "org-test@example.com", EndPoint: "realtime/configApplied", Type: "org", RetCode: http.StatusOK},
+		{ApiKey: "1234", HostID: "homer", Version: "dev-preview", Email: "org-test@example.com", EndPoint: "realtime/configApplied", Type: "org", RetCode: http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		data := &realtimeApiPayload{ID: c.HostID, Key: c.ApiKey, Email: c.Email, Version: c.Version}
+		s := apiTestServer(c.EndPoint, data, c.RetCode, t)
+		defer s.Close()
+		testURL, _ := url.Parse(s.URL)
+		os.Setenv("IMUP_REALTIME_CONFIG_ACK", testURL.String())
+		os.Setenv("API_KEY", c.ApiKey)
+		os.Setenv("EMAIL", c.Email)
+		os.Setenv("HOST_ID", c.HostID)
+
+		is := is.New(t)
+
+		imup := newApp()
+		err := imup.acknowledgeConfig(context.Background())
+
+		if c.RetCode >= 500 {
+			is.True(err != nil)
+		} else {
+			is.NoErr(err)
+		}
+	}
+}
+
 // loose reflection of imup api endpoints and their expected payloads
 func apiTestServer(endpoint string, payload interface{}, retcode int, t *testing.T) *httptest.Server {
 	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -596,6 +635,20 @@ func apiTestServer(endpoint string, payload interface{}, retcode int, t *testing
 				t.Errorf("Expected: %v Got: %v", expected, got)
 			}
 
+		case "realtime/configApplied":
+			recvdata := &realtimeApiPayload{}
+			if err := json.NewDecoder(r.Body).Decode(recvdata); err != nil {
+				t.Error(err)
+			}
+
+			expected, ok := payload.(*realtimeApiPayload)
+			if !ok {
+				t.Error("payload is not the expected type")
+			}
+			if expected.Version != recvdata.Version {
+				t.Errorf("Expected: %v Got: %v", expected.Version, recvdata.Version)
+			}
+
 		case "realtime/remoteConfigReload":
 			if retcode == http.StatusNoContent {
 				w.WriteHeader(http.StatusNoContent)
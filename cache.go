@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imup-io/client/util"
+)
+
+// flags of the cache command, they may follow the cache action, e.g. cache purge -older-than 168h
+var (
+	cacheOlderThan   time.Duration
+	cacheDestination string
+	cacheReplayURL   string
+)
+
+func cacheFlags(fs *flag.FlagSet) {
+	outputFlag(fs)
+	fs.DurationVar(&cacheOlderThan, "older-than", 0, "purge only removes jobs queued longer than this, e.g. 168h")
+	fs.StringVar(&cacheDestination, "destination", "", "list, replay and purge only act on jobs of this type or with a destination containing this value")
+	fs.StringVar(&cacheReplayURL, "replay-url", "", "replay sends jobs to this host instead of their cached destination, the path of each job is kept")
+}
+
+// cacheAction is an operation of the cache command
+type cacheAction struct {
+	usage string
+	run   func(ctx context.Context, w io.Writer, entries []cacheEntry, args []string) error
+}
+
+var cacheActions = map[string]cacheAction{
+	"list":    {usage: "list queued jobs with their type, destination, size and age", run: listCache},
+	"inspect": {usage: "print a queued job, secrets are redacted: inspect <id>", run: inspectCache},
+	"replay":  {usage: "send every queued job and remove the ones accepted by the api", run: replayCache},
+	"purge":   {usage: "remove queued jobs, all of them unless -older-than or -destination is set", run: purgeCache},
+}
+
+// cacheEntry is a job persisted to the offline cache by toUserCache
+type cacheEntry struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Destination string    `json:"destination"`
+	Size        int64     `json:"size"`
+	QueuedAt    time.Time `json:"queuedAt"`
+	Age         string    `json:"age"`
+
+	path string
+	job  sendDataJob
+}
+
+// matches reports if the entry is selected by the -destination flag
+func (e cacheEntry) matches(destination string) bool {
+	return destination == "" || e.Type == destination || strings.Contains(e.job.IMUPAddress, destination)
+}
+
+func cacheCommand(ctx context.Context, w io.Writer) error {
+	// the configuration parses the flags, the cache command needs no client
+	if _, err := commandConfig(); err != nil {
+		return err
+	}
+
+	name := flag.Arg(0)
+	action, ok := cacheActions[name]
+	if !ok {
+		return fmt.Errorf("cache action must be list, inspect, replay or purge: %q", name)
+	}
+
+	// flags after the action were not parsed with the configuration
+	args, err := parseArgs(flag.Args()[1:])
+	if err != nil {
+		return err
+	}
+
+	entries, err := readCache()
+	if err != nil {
+		return err
+	}
+
+	return action.run(ctx, w, entries, args)
+}
+
+// readCache returns every job in the offline cache, oldest first
+func readCache() ([]cacheEntry, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("cannot find the user cache directory: %v", err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot read the cache directory: %v", err)
+	}
+
+	entries := []cacheEntry{}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		name := filepath.Join(dir, f.Name())
+		job, ok := fromCache(name)
+		if !ok {
+			continue
+		}
+
+		entries = append(entries, cacheEntry{
+			ID:          strings.TrimSuffix(f.Name(), ".json"),
+			Type:        jobType(job.IMUPAddress),
+			Destination: util.Redact(job.IMUPAddress),
+			Size:        info.Size(),
+			QueuedAt:    info.ModTime(),
+			Age:         time.Since(info.ModTime()).Round(time.Second).String(),
+			path:        name,
+			job:         job,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].QueuedAt.Before(entries[j].QueuedAt) })
+	return entries, nil
+}
+
+// jobType names a job after the last element of its destination, e.g. connectivity or speedtest
+func jobType(address string) string {
+	u, err := url.Parse(address)
+	if err != nil || path.Base(u.Path) == "/" || path.Base(u.Path) == "." {
+		return "unknown"
+	}
+
+	return path.Base(u.Path)
+}
+
+func listCache(_ context.Context, w io.Writer, entries []cacheEntry, _ []string) error {
+	selected := []cacheEntry{}
+	for _, e := range entries {
+		if e.matches(cacheDestination) {
+			selected = append(selected, e)
+		}
+	}
+
+	if output == "json" {
+		return printJSON(w, selected)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTYPE\tDESTINATION\tSIZE\tAGE")
+	for _, e := range selected {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", e.ID, e.Type, e.Destination, e.Size, e.Age)
+	}
+	return tw.Flush()
+}
+
+func inspectCache(_ context.Context, w io.Writer, entries []cacheEntry, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("inspect takes the id of a single job")
+	}
+
+	// like git, a unique prefix of the id is enough
+	var found []cacheEntry
+	for _, e := range entries {
+		if strings.HasPrefix(e.ID, args[0]) {
+			found = append(found, e)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return fmt.Errorf("no job matches id %s", args[0])
+	case 1:
+	default:
+		return fmt.Errorf("%d jobs match id %s, use a longer prefix", len(found), args[0])
+	}
+
+	b, err := os.ReadFile(found[0].path)
+	if err != nil {
+		return fmt.Errorf("cannot read job: %v", err)
+	}
+
+	var job any
+	if err := json.Unmarshal(b, &job); err != nil {
+		return fmt.Errorf("cannot decode job: %v", err)
+	}
+
+	return printJSON(w, redactJSON(job))
+}
+
+// redactJSON masks the values of secret keys in a decoded json document
+func redactJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if util.IsSecretKey(k) {
+				t[k] = util.Redacted
+				continue
+			}
+			t[k] = redactJSON(val)
+		}
+	case []any:
+		for i := range t {
+			t[i] = redactJSON(t[i])
+		}
+	case string:
+		return util.Redact(t)
+	}
+
+	return v
+}
+
+// cacheReplayResult is the outcome of replaying a single job
+type cacheReplayResult struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Destination string `json:"destination"`
+	Replayed    bool   `json:"replayed"`
+	Error       string `json:"error,omitempty"`
+}
+
+func replayCache(ctx context.Context, w io.Writer, entries []cacheEntry, _ []string) error {
+	selected := []cacheEntry{}
+	for _, e := range entries {
+		if e.matches(cacheDestination) {
+			selected = append(selected, e)
+		}
+	}
+
+	failed := 0
+	for n, e := range selected {
+		job := e.job
+		if cacheReplayURL != "" {
+			address, err := replayAddress(cacheReplayURL, job.IMUPAddress)
+			if err != nil {
+				return err
+			}
+			job.IMUPAddress = address
+		}
+
+		result := cacheReplayResult{ID: e.ID, Type: e.Type, Destination: util.Redact(job.IMUPAddress)}
+		if err := postImupData(ctx, job, uploadRetries); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			failed++
+			result.Error = util.Redact(err.Error())
+		} else if err := removeCachedJob(e.path); err != nil {
+			// the job was sent, leaving it behind would send it again on the next start
+			failed++
+			result.Error = fmt.Sprintf("sent but cannot be removed from the cache: %v", err)
+		} else {
+			result.Replayed = true
+		}
+
+		// progress is printed as each job completes, one json document per line
+		if output == "json" {
+			if err := json.NewEncoder(w).Encode(result); err != nil {
+				return err
+			}
+			continue
+		}
+
+		status := "ok"
+		if result.Error != "" {
+			status = "failed: " + result.Error
+		}
+		fmt.Fprintf(w, "[%d/%d] %s %s %s %s\n", n+1, len(selected), result.ID, result.Type, result.Destination, status)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs were not replayed and remain in the cache", failed, len(selected))
+	}
+
+	return nil
+}
+
+// replayAddress moves address to the scheme and host of base
+func replayAddress(base, address string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil || b.Scheme == "" || b.Host == "" {
+		return "", fmt.Errorf("replay url must be an absolute url, e.g. https://api.imup.io: %s", base)
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", fmt.Errorf("url.Parse: %v", err)
+	}
+
+	u.Scheme, u.Host, u.User = b.Scheme, b.Host, b.User
+	return u.String(), nil
+}
+
+// cachePurgeResult is the output of the purge action
+type cachePurgeResult struct {
+	Removed []cacheEntry `json:"removed"`
+	Kept    int          `json:"kept"`
+}
+
+func purgeCache(_ context.Context, w io.Writer, entries []cacheEntry, _ []string) error {
+	result := cachePurgeResult{Removed: []cacheEntry{}}
+	for _, e := range entries {
+		if !e.matches(cacheDestination) || time.Since(e.QueuedAt) < cacheOlderThan {
+			result.Kept++
+			continue
+		}
+
+		if err := removeCachedJob(e.path); err != nil {
+			return fmt.Errorf("cannot remove job %s: %v", e.ID, err)
+		}
+		result.Removed = append(result.Removed, e)
+	}
+
+	if output == "json" {
+		return printJSON(w, result)
+	}
+
+	for _, e := range result.Removed {
+		fmt.Fprintf(w, "removed %s %s %s\n", e.ID, e.Type, e.Age)
+	}
+	fmt.Fprintf(w, "%d removed, %d kept\n", len(result.Removed), result.Kept)
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func testCache(t *testing.T) string {
+	dir := t.TempDir()
+	userCacheDir = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { userCacheDir = os.UserCacheDir })
+
+	return dir
+}
+
+func TestCachedJobs(t *testing.T) {
+	is := is.New(t)
+	dir := testCache(t)
+
+	is.Equal(0, cachedJobs())
+
+	job := sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/connectivity", IMUPData: imupData{ID: "counted"}}
+	toUserCache(job)
+	toUserCache(job) // the same job is written to the same file
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/speedtest", IMUPData: imupData{ID: "counted"}})
+	is.Equal(2, cachedJobs())
+
+	// jobs written or removed by another process, e.g. the cache command, are counted
+	// once the modification time of the directory changes
+	external := filepath.Join(dir, "imup", "external.json")
+	is.NoErr(os.WriteFile(external, []byte("{}"), 0600))
+	is.NoErr(os.Chtimes(filepath.Join(dir, "imup"), time.Now(), time.Now().Add(time.Minute)))
+	is.Equal(3, cachedJobs())
+
+	is.NoErr(os.Remove(external))
+	is.NoErr(os.Chtimes(filepath.Join(dir, "imup"), time.Now(), time.Now().Add(2*time.Minute)))
+	is.Equal(2, cachedJobs())
+
+	clearCache()
+	is.Equal(0, cachedJobs())
+}
+
+func TestReadCache(t *testing.T) {
+	is := is.New(t)
+	testCache(t)
+
+	entries, err := readCache()
+	is.NoErr(err)
+	is.Equal(0, len(entries)) // the cache directory does not exist yet
+
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/speedtest", IMUPData: imupData{ID: "new"}})
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/connectivity", IMUPData: imupData{ID: "old"}})
+	entries, err = readCache()
+	is.NoErr(err)
+	is.Equal(2, len(entries))
+
+	// oldest first
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	for _, e := range entries {
+		if e.Type == "connectivity" {
+			is.NoErr(os.Chtimes(e.path, old, old))
+		}
+	}
+
+	entries, err = readCache()
+	is.NoErr(err)
+	is.Equal("connectivity", entries[0].Type)
+	is.Equal("speedtest", entries[1].Type)
+	is.True(entries[0].Size > 0)
+
+	is.Equal("unknown", jobType("https://api.imup.io"))
+}
+
+func TestListAndInspectCache(t *testing.T) {
+	is := is.New(t)
+	testCache(t)
+
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/connectivity", IMUPData: imupData{ID: "host", Key: "cached-api-key", Downtime: 1}})
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/speedtest", IMUPData: imupData{ID: "host", Key: "cached-api-key"}})
+	entries, err := readCache()
+	is.NoErr(err)
+
+	b := &bytes.Buffer{}
+	is.NoErr(listCache(context.Background(), b, entries, nil))
+	listed := []cacheEntry{}
+	is.NoErr(json.Unmarshal(b.Bytes(), &listed))
+	is.Equal(2, len(listed))
+
+	cacheDestination = "speedtest"
+	defer func() { cacheDestination = "" }()
+	output = "table"
+	defer func() { output = "json" }()
+
+	b.Reset()
+	is.NoErr(listCache(context.Background(), b, entries, nil))
+	is.True(strings.Contains(b.String(), "speedtest"))
+	is.True(!strings.Contains(b.String(), "connectivity"))
+
+	var id string
+	for _, e := range entries {
+		if e.Type == "connectivity" {
+			id = e.ID
+		}
+	}
+
+	b.Reset()
+	is.NoErr(inspectCache(context.Background(), b, entries, []string{id[:8]}))
+	is.True(strings.Contains(b.String(), `"downtime": 1`))
+	is.True(!strings.Contains(b.String(), "cached-api-key")) // secrets are redacted
+
+	is.True(inspectCache(context.Background(), b, entries, []string{"not-a-job"}) != nil)
+	is.True(inspectCache(context.Background(), b, entries, []string{""}) != nil) // ambiguous
+}
+
+func TestReplayCache(t *testing.T) {
+	is := is.New(t)
+	testCache(t)
+
+	s := apiTestServer("data/connectivity", imupData{Key: "replay-api-key"}, 200, t)
+	defer s.Close()
+
+	// jobs are replayed to an alternate host, failures stay in the cache
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/connectivity", IMUPData: imupData{Key: "replay-api-key"}})
+	entries, err := readCache()
+	is.NoErr(err)
+
+	cacheReplayURL = s.URL
+	defer func() { cacheReplayURL = "" }()
+
+	b := &bytes.Buffer{}
+	is.NoErr(replayCache(context.Background(), b, entries, nil))
+
+	result := cacheReplayResult{}
+	is.NoErr(json.Unmarshal(b.Bytes(), &result))
+	is.True(result.Replayed)
+	is.True(strings.HasPrefix(result.Destination, s.URL+"/v1/data/connectivity"))
+
+	entries, err = readCache()
+	is.NoErr(err)
+	is.Equal(0, len(entries))
+
+	cacheReplayURL = "not a url"
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/connectivity", IMUPData: imupData{Key: "replay-api-key"}})
+	entries, err = readCache()
+	is.NoErr(err)
+	is.True(replayCache(context.Background(), b, entries, nil) != nil)
+}
+
+func TestPurgeCache(t *testing.T) {
+	is := is.New(t)
+	testCache(t)
+
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/connectivity", IMUPData: imupData{ID: "old"}})
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/connectivity", IMUPData: imupData{ID: "new"}})
+	toUserCache(sendDataJob{IMUPAddress: "https://api.imup.io/v1/data/speedtest", IMUPData: imupData{ID: "old"}})
+	entries, err := readCache()
+	is.NoErr(err)
+
+	old := time.Now().Add(-8 * 24 * time.Hour)
+	for _, e := range entries {
+		if strings.Contains(string(mustMarshal(t, e.job)), `"hostId":"old"`) {
+			is.NoErr(os.Chtimes(e.path, old, old))
+		}
+	}
+
+	cacheOlderThan = 7 * 24 * time.Hour
+	cacheDestination = "connectivity"
+	defer func() { cacheOlderThan, cacheDestination = 0, "" }()
+
+	entries, err = readCache()
+	is.NoErr(err)
+
+	b := &bytes.Buffer{}
+	is.NoErr(purgeCache(context.Background(), b, entries, nil))
+
+	result := cachePurgeResult{}
+	is.NoErr(json.Unmarshal(b.Bytes(), &result))
+	is.Equal(1, len(result.Removed))
+	is.Equal(2, result.Kept)
+
+	entries, err = readCache()
+	is.NoErr(err)
+	is.Equal(2, len(entries))
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/imup-io/client/config"
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/speedtesting"
+	log "golang.org/x/exp/slog"
+)
+
+// flags of the diagnostic commands, each command parses the ones it accepts with its own flag set
+var (
+	output    = "json"
+	pingCount = defaultPingCount
+	upload    = false
+)
+
+// defaultPingCount is the number of requests the ping command sends to each address
+const defaultPingCount = 10
+
+// uploadRetries bounds retries when a command uploads its results, unlike the daemon it does not wait for weeks
+const uploadRetries = 3
+
+// command is a subcommand of the client, every command but run exits once it is done
+type command struct {
+	usage string
+	// flags defines the flags of the command besides the configuration flags
+	flags func(fs *flag.FlagSet)
+	run   func(ctx context.Context, w io.Writer) error
+}
+
+var commands = map[string]command{
+	"cache":     {usage: "list, inspect, replay or purge jobs queued in the offline cache: cache <action> [flags]", flags: cacheFlags, run: cacheCommand},
+	"once":      {usage: "run a single connectivity test, like the daemon, and print the result", flags: resultFlags, run: onceCommand},
+	"ping":      {usage: "test connectivity to the given addresses, or the configured external addresses: ping [addresses] [flags]", flags: pingFlags, run: pingCommand},
+	"run":       {usage: "run the client, this is the default command"},
+	"speedtest": {usage: "run a speed test and print the result", flags: resultFlags, run: speedTestCommand},
+	"status":    {usage: "print the state of the client running on this host, the status server must be enabled", flags: outputFlag, run: statusCommand},
+}
+
+func outputFlag(fs *flag.FlagSet) {
+	fs.StringVar(&output, "output", "json", "output format of the result [json, table]")
+}
+
+// resultFlags are the flags of the commands that test like the daemon
+func resultFlags(fs *flag.FlagSet) {
+	outputFlag(fs)
+	fs.BoolVar(&upload, "upload", false, "send the result to the imup api, default is false")
+}
+
+func pingFlags(fs *flag.FlagSet) {
+	resultFlags(fs)
+	fs.IntVar(&pingCount, "count", defaultPingCount, "number of requests sent to each address")
+}
+
+// flagSet returns the flags of a command, the configuration flags are not part of it
+func (c command) flagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	if c.flags != nil {
+		c.flags(fs)
+	}
+
+	return fs
+}
+
+// parseCommand splits the name of a command from its arguments, run is the default command
+func parseCommand(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+
+	return "run", args
+}
+
+// usage describes the available commands and flags
+func usage() {
+	w := flag.CommandLine.Output()
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range []string{"run", "once", "ping", "speedtest", "status", "cache"} {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].usage)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nCache actions:\n")
+	for _, name := range []string{"list", "inspect", "replay", "purge"} {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, cacheActions[name].usage)
+	}
+	tw.Flush()
+
+	for _, name := range []string{"once", "ping", "speedtest", "status", "cache"} {
+		fs := commands[name].flagSet(name)
+		fs.SetOutput(w)
+		fmt.Fprintf(w, "\nFlags of %s:\n", name)
+		fs.PrintDefaults()
+	}
+
+	fmt.Fprintf(w, "\nConfiguration flags, of every command:\n")
+	flag.PrintDefaults()
+}
+
+// commandFlags parses the flags of a command wherever they are in args, e.g. ping 1.1.1.1 -count 5,
+// and returns the other arguments, which are left to the configuration
+func commandFlags(name string, args []string) ([]string, error) {
+	fs := commands[name].flagSet(name)
+	fs.Usage = usage
+
+	own, rest := []string{}, []string{}
+	for n := 0; n < len(args); n++ {
+		arg := args[n]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+
+		key, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		f := fs.Lookup(key)
+		if f == nil {
+			rest = append(rest, arg)
+			continue
+		}
+
+		own = append(own, arg)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !hasValue && !(ok && b.IsBoolFlag()) && n+1 < len(args) {
+			n++
+			own = append(own, args[n])
+		}
+	}
+
+	if err := fs.Parse(own); err != nil {
+		return nil, err
+	}
+
+	return rest, nil
+}
+
+// parseArgs parses configuration flags that follow the arguments of a command, e.g. ping 1.1.1.1 -ping=false,
+// and returns the arguments, the configuration must be loaded first to define the flags
+func parseArgs(args []string) ([]string, error) {
+	parsed := []string{}
+	for len(args) > 0 {
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return nil, err
+		}
+
+		args = flag.Args()
+		if len(args) > 0 {
+			parsed = append(parsed, args[0])
+			args = args[1:]
+		}
+	}
+
+	return parsed, checkOutput()
+}
+
+func checkOutput() error {
+	switch output {
+	case "json", "table":
+		return nil
+	default:
+		return fmt.Errorf("output must be json or table: %s", output)
+	}
+}
+
+// commandConfig returns the configuration of a diagnostic command, credentials are only required to upload results
+func commandConfig() (config.Reloadable, error) {
+	load := config.NewLocal
+	if upload {
+		load = config.New
+	}
+
+	cfg, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, checkOutput()
+}
+
+// commandApp returns a client for a diagnostic command. Unlike the daemon it has no sinks and
+// connects to nothing on its own, discover looks up the public ip and network the results are
+// tagged with, which only commands that test like the daemon need
+func commandApp(discover bool) (*imup, error) {
+	cfg, err := commandConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	i := &imup{
+		State:      newClientState(),
+		cfg:        cfg,
+		rebaseline: make(chan struct{}, 1),
+	}
+
+	if discover {
+		i.cfg.RefreshPublicIP()
+		i.detectNetwork()
+	}
+
+	return i, nil
+}
+
+// connectivityResult is the output of the once and ping commands
+type connectivityResult struct {
+	HostID     string                    `json:"hostId"`
+	Verdict    string                    `json:"verdict"`
+	Downtime   int                       `json:"downtime"`
+	Statistics []connectivity.Statistics `json:"statistics"`
+	Uploaded   bool                      `json:"uploaded"`
+}
+
+func onceCommand(ctx context.Context, w io.Writer) error {
+	i, err := commandApp(true)
+	if err != nil {
+		return err
+	}
+
+	collector := i.newCollector(i.cfg.InternalPingAddress())
+	collected := i.collect(ctx, collector)
+	_, dt := collector.DetectDowntime(collected)
+
+	return i.printConnectivity(ctx, w, collected, dt)
+}
+
+func pingCommand(ctx context.Context, w io.Writer) error {
+	i, err := commandApp(false)
+	if err != nil {
+		return err
+	}
+
+	// flags may follow the addresses, e.g. ping 1.1.1.1 -count 5
+	addresses, err := parseArgs(flag.Args())
+	if err != nil {
+		return err
+	}
+
+	if pingCount < 1 {
+		return fmt.Errorf("count must be at least 1: %d", pingCount)
+	}
+
+	if len(addresses) == 0 {
+		addresses = i.cfg.PingAddresses()
+	}
+
+	// a collector tests a random address per interval, test every address with its own collector
+	results := make([][]connectivity.Statistics, len(addresses))
+	wg := sync.WaitGroup{}
+	for n, addr := range addresses {
+		wg.Add(1)
+		go func(n int, addr string) {
+			defer wg.Done()
+			results[n] = i.pingCollector(pingCount).Collect(ctx, []string{addr})
+		}(n, addr)
+	}
+	wg.Wait()
+
+	collected := []connectivity.Statistics{}
+	for _, r := range results {
+		collected = append(collected, r...)
+	}
+	i.State.recordStatistics(collected)
+
+	return i.printConnectivity(ctx, w, collected, 0)
+}
+
+// pingCollector returns a collector that sends count requests to an address, instead of
+// the requests the daemon spreads over a whole interval
+func (i *imup) pingCollector(count int) connectivity.StatCollector {
+	opts := connectivity.Options{
+		ClientVersion: ClientVersion,
+		Count:         count,
+		Debug:         i.cfg.Verbosity() == log.LevelDebug,
+	}
+
+	if i.cfg.PingTests() {
+		opts.Delay = time.Duration(i.cfg.PingDelayMilli()) * time.Millisecond
+		// every request and a few seconds for the last reply
+		opts.Timeout = time.Duration(count)*opts.Delay + 5*time.Second
+		return connectivity.NewPingCollector(opts)
+	}
+
+	opts.Delay = time.Duration(i.cfg.ConnDelayMilli()) * time.Millisecond
+	opts.Timeout = 5 * time.Second
+	return connectivity.NewDialerCollector(opts)
+}
+
+// printConnectivity uploads collected statistics if asked and prints them
+func (i *imup) printConnectivity(ctx context.Context, w io.Writer, collected []connectivity.Statistics, downtime int) error {
+	result := connectivityResult{
+		HostID:     i.cfg.HostID(),
+		Verdict:    i.State.verdict(),
+		Downtime:   downtime,
+		Statistics: collected,
+	}
+
+	if upload {
+		job := sendDataJob{
+			IMUPAddress: i.cfg.PostConnectionData(),
+			IMUPData: imupData{
+				Downtime: downtime,
+				Email:    i.cfg.EmailAddress(),
+				ID:       i.cfg.HostID(),
+				Key:      i.cfg.APIKey(),
+				GroupID:  i.cfg.GroupID(),
+				IMUPData: collected,
+			},
+		}
+
+		if err := postImupData(ctx, job, uploadRetries); err != nil {
+			return fmt.Errorf("cannot upload connectivity data: %v", err)
+		}
+		result.Uploaded = true
+	}
+
+	if output == "json" {
+		return printJSON(w, result)
+	}
+
+	fmt.Fprintf(w, "host: %s\nverdict: %s\ndowntime: %d\n\n", result.HostID, result.Verdict, result.Downtime)
+	printStatistics(w, collected)
+	return nil
+}
+
+// speedTestOutput is the output of the speedtest command
+type speedTestOutput struct {
+	HostID   string                        `json:"hostId"`
+	Result   *speedtesting.SpeedTestResult `json:"result"`
+	Uploaded bool                          `json:"uploaded"`
+}
+
+func speedTestCommand(ctx context.Context, w io.Writer) error {
+	i, err := commandApp(true)
+	if err != nil {
+		return err
+	}
+
+	result, err := i.runSpeedTest(ctx, i.throughTunnel(speedtesting.Options{
+		Insecure:      i.cfg.InsecureSpeedTests(),
+		OnDemand:      true,
+		ClientVersion: ClientVersion,
+	}))
+	if err != nil {
+		return fmt.Errorf("speed test failed: %v", err)
+	}
+
+	out := speedTestOutput{HostID: i.cfg.HostID(), Result: result}
+	if upload {
+		job := sendDataJob{
+			IMUPAddress: i.cfg.PostSpeedTestData(),
+			IMUPData: &imupData{
+				Email:    i.cfg.EmailAddress(),
+				ID:       i.cfg.HostID(),
+				Key:      i.cfg.APIKey(),
+				GroupID:  i.cfg.GroupID(),
+				IMUPData: result,
+			},
+		}
+
+		if err := postImupData(ctx, job, uploadRetries); err != nil {
+			return fmt.Errorf("cannot upload speed test: %v", err)
+		}
+		out.Uploaded = true
+	}
+
+	if output == "json" {
+		return printJSON(w, out)
+	}
+
+	printSpeedTest(w, result)
+	return nil
+}
+
+func statusCommand(ctx context.Context, w io.Writer) error {
+	cfg, err := commandConfig()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	addr := fmt.Sprintf("http://%s/status", cfg.StatusAddress())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr, nil)
+	if err != nil {
+		return fmt.Errorf("NewRequest: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach the client, is it running with the status server enabled? %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("addr: %s, unexpected status: %s", addr, resp.Status)
+	}
+
+	s := clientStatus{}
+	if err := json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return fmt.Errorf("cannot decode status: %v", err)
+	}
+
+	if output == "json" {
+		return printJSON(w, s)
+	}
+
+	fmt.Fprintf(w, "host: %s\nversion: %s (%s/%s)\nconfig version: %s\nverdict: %s\nuptime: %s\nmonitoring: %t\nqueue depth: %d\ncached jobs: %d\n",
+		s.HostID, s.ClientVersion, s.OS, s.Arch, s.ConfigVersion, s.Verdict, s.Uptime, s.Monitoring, s.QueueDepth, s.CachedJobs)
+
+	if len(s.PublicIPs) > 0 {
+		fmt.Fprintf(w, "public ip: %s\n", strings.Join(s.PublicIPs, ", "))
+	} else if s.PublicIP != "" {
+		fmt.Fprintf(w, "public ip: %s\n", s.PublicIP)
+	}
+
+	if s.Maintenance {
+		fmt.Fprintf(w, "maintenance: %t\n", s.Maintenance)
+	}
+
+	if s.Network.ID != "" {
+		fmt.Fprintf(w, "network: %s (gateway %s %s, subnet %s)\n", s.Network.ID, s.Network.GatewayIP, s.Network.GatewayMAC, s.Network.Subnet)
+	}
+
+	if s.Network.ASN != "" {
+		fmt.Fprintf(w, "asn: %s\n", s.Network.ASN)
+	}
+
+	if s.Network.Tunnel != "" {
+		fmt.Fprintf(w, "tunnel: %s\n", s.Network.Tunnel)
+	}
+
+	if s.NetworkProfile != "" {
+		fmt.Fprintf(w, "network profile: %s\n", s.NetworkProfile)
+	}
+
+	if s.LastSentAt != nil {
+		fmt.Fprintf(w, "last sent at: %s\n", s.LastSentAt.Format(time.RFC3339))
+	}
+
+	if s.LastCollectedAt != nil {
+		fmt.Fprintf(w, "\nlast collected at %s\n", s.LastCollectedAt.Format(time.RFC3339))
+		printStatistics(w, s.LastStatistics)
+	}
+
+	if s.LastSpeedTestAt != nil {
+		fmt.Fprintf(w, "\nlast speed test at %s\n", s.LastSpeedTestAt.Format(time.RFC3339))
+		printSpeedTest(w, s.LastSpeedTest)
+	}
+
+	if len(s.Errors) > 0 {
+		fmt.Fprintf(w, "\nerrors:\n")
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "  OPERATION\tSTATE\tCOUNT\tDURATION\tLAST SEEN\tERROR")
+		for name, e := range s.Errors {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%s\t%s\t%s\n", name, e.State, e.Count, e.Duration, e.LastSeen.Format(time.RFC3339), e.Error)
+		}
+		tw.Flush()
+	}
+
+	return nil
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printStatistics(w io.Writer, stats []connectivity.Statistics) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADDRESS\tTYPE\tSUCCESS\tSENT\tRECEIVED\tLOSS\tMIN\tAVG\tMAX")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n",
+			s.PingAddress, s.EndpointType, s.Success, s.PacketsSent, s.PacketsRecv, s.PacketLoss,
+			s.MinRtt.Round(time.Microsecond), s.AvgRtt.Round(time.Microsecond), s.MaxRtt.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
+
+func printSpeedTest(w io.Writer, r *speedtesting.SpeedTestResult) {
+	if r == nil {
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIRECTION\tMBPS\tMIN RTT (MS)\tRETRANSMISSIONS")
+	fmt.Fprintf(tw, "download\t%.2f\t%.2f\t%.2f%%\n", r.DownloadMbps, r.DownloadMinRtt, r.DownloadRetrans)
+	fmt.Fprintf(tw, "upload\t%.2f\t%.2f\t%.2f%%\n", r.UploadMbps, r.UploadMinRTT, r.UploadRetrans)
+	tw.Flush()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/reporting"
+	"github.com/matryer/is"
+)
+
+func TestParseCommand(t *testing.T) {
+	is := is.New(t)
+
+	name, args := parseCommand([]string{})
+	is.Equal("run", name)
+	is.Equal(0, len(args))
+
+	// flags without a command run the daemon as before
+	name, args = parseCommand([]string{"--email", "imup@example.com"})
+	is.Equal("run", name)
+	is.Equal([]string{"--email", "imup@example.com"}, args)
+
+	name, args = parseCommand([]string{"ping", "-output", "table", "1.1.1.1"})
+	is.Equal("ping", name)
+	is.Equal([]string{"-output", "table", "1.1.1.1"}, args)
+}
+
+func TestCommandFlags(t *testing.T) {
+	is := is.New(t)
+	defer func() { pingCount, output, upload = 10, "json", false }()
+
+	// the flags of a command may follow its arguments, configuration flags are left to the configuration
+	args, err := commandFlags("ping", []string{"1.1.1.1", "-count", "5", "-ping=false", "8.8.8.8", "--output=table", "-upload", "-email", "imup@example.com"})
+	is.NoErr(err)
+	is.Equal([]string{"1.1.1.1", "-ping=false", "8.8.8.8", "-email", "imup@example.com"}, args)
+	is.Equal(5, pingCount)
+	is.Equal("table", output)
+	is.True(upload)
+
+	// the daemon has no flags of its own, the configuration rejects the flags of other commands
+	args, err = commandFlags("run", []string{"-count", "5"})
+	is.NoErr(err)
+	is.Equal([]string{"-count", "5"}, args)
+
+	args, err = commandFlags("status", []string{"-upload"})
+	is.NoErr(err)
+	is.Equal([]string{"-upload"}, args)
+
+	_, err = commandFlags("ping", []string{"-count", "many"})
+	is.True(err != nil)
+}
+
+func TestParseArgs(t *testing.T) {
+	is := is.New(t)
+	defer func() { output = "json" }()
+
+	args, err := parseArgs([]string{"1.1.1.1", "8.8.8.8"})
+	is.NoErr(err)
+	is.Equal([]string{"1.1.1.1", "8.8.8.8"}, args)
+
+	output = "yaml"
+	_, err = parseArgs([]string{"1.1.1.1"})
+	is.True(err != nil)
+}
+
+func TestCommandApp(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("HOST_ID", "command-host")
+	os.Setenv("MQTT_BROKER", "tcp://127.0.0.1:1")
+
+	// a command neither sends to sinks nor discovers the network unless asked
+	i, err := commandApp(false)
+	is.NoErr(err)
+	is.True(i.Sinks == nil)
+	is.True(i.api == nil)
+	is.Equal("", i.State.network().ID)
+}
+
+func TestStatusCommand(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "status-api-key")
+	os.Setenv("HOST_ID", "status-host")
+
+	daemon := newApp()
+	daemon.Errors = NewErrMap(reporting.NewNoop())
+	daemon.State.recordStatistics([]connectivity.Statistics{{PingAddress: "1.1.1.1", EndpointType: "external", Success: true}})
+
+	s := httptest.NewServer(daemon.statusHandler())
+	defer s.Close()
+
+	// the status command does not need credentials
+	os.Clearenv()
+	os.Setenv("STATUS_ADDRESS", strings.TrimPrefix(s.URL, "http://"))
+
+	b := &bytes.Buffer{}
+	is.NoErr(statusCommand(context.Background(), b))
+
+	status := clientStatus{}
+	is.NoErr(json.Unmarshal(b.Bytes(), &status))
+	is.Equal("status-host", status.HostID)
+	is.Equal(verdictUp, status.Verdict)
+
+	output = "table"
+	defer func() { output = "json" }()
+
+	b.Reset()
+	is.NoErr(statusCommand(context.Background(), b))
+	is.True(strings.Contains(b.String(), "verdict: up"))
+	is.True(strings.Contains(b.String(), "config version: dev-preview"))
+	is.True(strings.Contains(b.String(), "1.1.1.1"))
+
+	output = "yaml"
+	is.True(statusCommand(context.Background(), b) != nil) // unsupported output
+}
+
+func TestPrintConnectivityUpload(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "upload-api-key")
+	os.Setenv("HOST_ID", "upload-host")
+
+	s := apiTestServer("data/connectivity", imupData{Key: "upload-api-key"}, 200, t)
+	defer s.Close()
+	os.Setenv("IMUP_ADDRESS", s.URL)
+
+	upload = true
+	defer func() { upload = false }()
+
+	i, err := commandApp(false)
+	is.NoErr(err)
+
+	b := &bytes.Buffer{}
+	collected := []connectivity.Statistics{{PingAddress: "1.1.1.1", EndpointType: "external", Success: false}}
+	i.State.recordStatistics(collected)
+	is.NoErr(i.printConnectivity(context.Background(), b, collected, 1))
+
+	result := connectivityResult{}
+	is.NoErr(json.Unmarshal(b.Bytes(), &result))
+	is.True(result.Uploaded)
+	is.Equal(verdictDown, result.Verdict)
+	is.Equal(1, result.Downtime)
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// auditRecord is a single entry in the configuration audit trail
+type auditRecord struct {
+	Timestamp       time.Time `json:"timestamp"`
+	HostID          string    `json:"hostId"`
+	Version         string    `json:"version"`
+	PreviousVersion string    `json:"previousVersion"`
+	Changes         []Change  `json:"changes"`
+}
+
+// defaultAuditFile is the audit trail location in the users cache directory
+func defaultAuditFile() string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		log.Error("$HOME is likely undefined", "error", err)
+		return ""
+	}
+
+	return filepath.Join(cache, "imup", "audit", "config.log")
+}
+
+// appendAudit writes a configuration change to the end of the audit trail as a json line
+func appendAudit(file string, record auditRecord) error {
+	if file == "" {
+		return fmt.Errorf("no audit file configured")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("cannot create audit directory: %v", err)
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open audit file: %v", err)
+	}
+	defer f.Close()
+
+	if err := json.NewEncoder(f).Encode(record); err != nil {
+		return fmt.Errorf("cannot write audit record: %v", err)
+	}
+
+	return nil
+}
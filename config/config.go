@@ -1,18 +1,24 @@
 package config
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"net"
-	"net/http"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/imup-io/client/logging"
+	"github.com/imup-io/client/netid"
+	"github.com/imup-io/client/publicip"
+	"github.com/imup-io/client/schedule"
 	"github.com/imup-io/client/util"
 	log "golang.org/x/exp/slog"
 )
@@ -20,43 +26,97 @@ import (
 // NOTE: ImUpAPIHost is set via build flags
 var ImUpAPIHost = "https://api.imup.io"
 
+// how scheduled speed tests run while the internet is routed through a vpn or tunnel
+const (
+	// TunnelSpeedTestsRun runs them through the tunnel
+	TunnelSpeedTestsRun = "run"
+	// TunnelSpeedTestsPause skips them
+	TunnelSpeedTestsPause = "pause"
+	// TunnelSpeedTestsBoth runs them through the tunnel and again around it
+	TunnelSpeedTestsBoth = "both"
+)
+
 var (
 	setupFlags sync.Once
 
 	allowlistedIPs               *string
 	apiKey                       *string
+	apiKeyFile                   *string
 	apiPostConnectionData        *string
 	apiPostSpeedTestData         *string
+	asnDatabase                  *string
 	blocklistedIPs               *string
+	commandResultsAddress        *string
+	configAuditFile              *string
 	configVersion                *string
 	connDelay                    *string
 	connInterval                 *string
 	connRequests                 *string
+	credentialHelper             *string
+	diagnosticCommands           *string
 	email                        *string
+	emailFile                    *string
+	errorReporter                *string
+	errorReportFile              *string
 	groupID                      *string
 	hostID                       *string
 	imupDataLength               *string
-	logFile                      *string
+	influxDBToken                *string
+	influxDBTokenFile            *string
+	influxDBURL                  *string
 	livenessCheckInAddress       *string
+	logFile                      *string
+	logMaxAge                    *string
+	logMaxBackups                *string
+	logMaxSize                   *string
+	logOutput                    *string
+	maintenanceWindows           *string
+	monitoringSchedule           *string
+	monitoringTimezone           *string
+	mqttBroker                   *string
+	mqttClientID                 *string
+	mqttPassword                 *string
+	mqttPasswordFile             *string
+	mqttQoS                      *string
+	mqttTopicPrefix              *string
+	mqttUsername                 *string
+	networkProfiles              *string
+	otlpEndpoint                 *string
 	pingAddressesExternal        *string
 	pingAddressInternal          *string
 	pingDelay                    *string
 	pingInterval                 *string
 	pingRequests                 *string
+	pushAddress                  *string
 	realtimeAuthorized           *string
 	realtimeConfig               *string
+	realtimeConfigAck            *string
+	sentryDSN                    *string
+	sentryDSNFile                *string
 	shouldRunSpeedTestAddress    *string
+	sinkFile                     *string
 	speedTestResultsAddress      *string
 	speedTestStatusUpdateAddress *string
+	stateFile                    *string
+	statusAddress                *string
+	syslogAddress                *string
+	tunnelInterfaces             *string
+	tunnelSpeedTests             *string
 	verbosity                    *string
+	webhookURL                   *string
 
 	insecureSpeedTest  *bool
+	logCompress        *bool
 	logToFile          *bool
+	metricsEnabled     *bool
+	noAPISink          *bool
 	noGatewayDiscovery *bool
 	nonvolatile        *bool
+	noPush             *bool
 	noSpeedTest        *bool
 	pingEnabled        *bool
 	realtimeEnabled    *bool
+	statusServer       *bool
 
 	mu sync.RWMutex
 )
@@ -70,10 +130,14 @@ type Reloadable interface {
 	GroupID() string
 	HostID() string
 	PublicIP() string
+	PublicIPs() []string
 	RefreshPublicIP() string
+	SetPublicIP(ip string)
+	RefreshSecrets()
 	Version() string
 
 	Realtime() bool
+	Push() bool
 	SpeedTests() bool
 	StoreJobsOnDisk() bool
 	InsecureSpeedTests() bool
@@ -83,9 +147,40 @@ type Reloadable interface {
 	DisableRealtime()
 
 	Verbosity() log.Level
-
-	AllowedIPs() []string
-	BlockedIPs() []string
+	SetVerbosity(level log.Level)
+
+	Redacted() map[string]any
+
+	StatusServer() bool
+	Metrics() bool
+	StatusAddress() string
+
+	APISink() bool
+	WebhookURL() string
+	InfluxDBURL() string
+	InfluxDBToken() string
+	SinkFile() string
+	MQTTBroker() string
+	MQTTClientID() string
+	MQTTUsername() string
+	MQTTPassword() string
+	MQTTTopicPrefix() string
+	MQTTQoS() byte
+
+	OTLPEndpoint() string
+	StateFile() string
+
+	ErrorReporter() string
+	ErrorReportFile() string
+	SentryDSN() string
+
+	AllowRules() *netid.Rules
+	BlockRules() *netid.Rules
+	NetworkProfiles() *netid.Profiles
+	TunnelInterfaces() []string
+	TunnelSpeedTests() string
+	ASNDatabase() string
+	Schedule() *schedule.Schedule
 
 	PostConnectionData() string
 	PostSpeedTestData() string
@@ -95,8 +190,13 @@ type Reloadable interface {
 	SpeedTestStatusUpdateURL() string
 	RealtimeAuth() string
 	RealtimeConfigURL() string
+	RealtimeConfigAckURL() string
+	PushURL() string
+	CommandResultsURL() string
+	DiagnosticCommands() []string
 	PingAddresses() []string
 	InternalPingAddress() string
+	RefreshGateway() string
 	PingIntervalSeconds() int
 	ConnIntervalSeconds() int
 	PingDelayMilli() int
@@ -112,22 +212,66 @@ type Reloadable interface {
 var cfg *config
 
 type config struct {
-	apiKey   string
-	email    string
-	hostID   string
-	publicIP string
+	apiKey string
+	email  string
+	hostID string
+
+	publicIPv4 string
+	publicIPv6 string
+
+	// gatewayDiscovered is set when the internal ping address is not configured and
+	// is discovered again when the host changes networks
+	gatewayDiscovered bool
+
+	// allowRules, blockRules and profiles are compiled from the allow and block lists
+	// and the network profiles on load and reload
+	allowRules *netid.Rules
+	blockRules *netid.Rules
+	profiles   *netid.Profiles
+
+	// schedule is compiled from the monitoring schedule, maintenance windows and timezone
+	schedule *schedule.Schedule
+
+	auditFile     string
+	influxDBToken string
+	mqttPassword  string
+	sentryDSN     string
+
+	// secrets read from a file or credential helper, re-read by RefreshSecrets
+	secrets []secret
 
 	logLevel log.Level
 
 	APIPostConnectionData        string
 	APIPostSpeedTestData         string
+	CommandResultsAddress        string
 	LivenessCheckInAddress       string
 	PingAddressInternal          string
 	RealtimeAuthorized           string
 	RealtimeConfig               string
+	RealtimeConfigAck            string
+	PushAddress                  string
 	ShouldRunSpeedTestAddress    string
 	SpeedTestResultsAddress      string
 	SpeedTestStatusUpdateAddress string
+	StatusAddr                   string
+	InfluxDBAddress              string
+	SinkFilePath                 string
+	WebhookAddress               string
+	MQTTBrokerAddress            string
+	MQTTClient                   string
+	MQTTUser                     string
+	MQTTTopic                    string
+	OTLPAddress                  string
+	SyslogAddress                string
+	ErrorReporting               string
+	ErrorReportFilePath          string
+	StateFilePath                string
+
+	APISinkEnabled bool
+	StatusEnabled  bool
+	MetricsEnabled bool
+	PushEnabled    bool
 
 	ConnDelay      int
 	ConnInterval   int
@@ -137,12 +281,23 @@ type config struct {
 	PingInterval   int
 	PingRequests   int
 
+	MQTTQualityOfService int
+
+	LogMaxAge     int
+	LogMaxBackups int
+	LogMaxSize    int
+	LogCompress   bool
+
 	PingAddressesExternal []string
 
+	// only commands in the local configuration are accepted, the api cannot extend it
+	DiagnosticCommandsAllowed []string
+
 	// reloadable elements
 	ConfigVersion string `json:"version"`
 	Group         string `json:"group_id"`
 	LogLevel      string `json:"verbosity"`
+	LogOutput     string `json:"logOutput"`
 
 	InsecureSpeedTest bool `json:"insecureSpeedTest"`
 	FileLogger        bool `json:"fileLogger"`
@@ -154,60 +309,146 @@ type config struct {
 
 	AllowlistedIPs []string `json:"allowlisted_ips"`
 	BlocklistedIPs []string `json:"blocklisted_ips"`
+
+	Profiles []netid.Profile `json:"network_profiles"`
+
+	TunnelPrefixes  []string `json:"tunnelInterfaces"`
+	TunnelSpeedTest string   `json:"tunnelSpeedTests"`
+
+	ASNDatabasePath string `json:"asnDatabase"`
+
+	MonitoringSchedule []string `json:"monitoringSchedule"`
+	MaintenanceWindows []string `json:"maintenanceWindows"`
+	Timezone           string   `json:"timezone"`
 }
 
 // New returns a freshly setup Reloadable config.
 func New() (Reloadable, error) {
+	return newConfig(false)
+}
+
+// NewLocal returns a config for commands that only test locally, unlike New it does not
+// require an email address or api key and does not record the configuration in the audit trail.
+func NewLocal() (Reloadable, error) {
+	return newConfig(true)
+}
+
+func newConfig(local bool) (Reloadable, error) {
 	mu.Lock()
 	defer mu.Unlock()
 	// do not instantiate a new copy of config, use the package level global
 	cfg = &config{}
 
 	setupFlags.Do(func() {
-		allowlistedIPs = flag.String("allowlisted-ips", "", "comma separated list of CIDR strings to match against host IP that determines whether speed and connectivity testing will be run, default is allow all")
+		allowlistedIPs = flag.String("allowlisted-ips", "", "comma separated list of CIDR strings or network rules, e.g. gateway-mac:aa:bb:cc:dd:ee:ff, that determines whether speed and connectivity testing will be run, default is allow all")
 		apiKey = flag.String("key", "", "an api key associated with an imup organization")
+		apiKeyFile = flag.String("key-file", "", "path to a file containing the api key, re-read when the key is rotated")
 		apiPostConnectionData = flag.String("api-post-connection-data", "", fmt.Sprintf("api endpoint for connectivity data ingestion, default is %s/v1/data/connectivity", ImUpAPIHost))
 		apiPostSpeedTestData = flag.String("api-post-speed-test-data", "", fmt.Sprintf("api endpoint for speed data ingestion, default is %s/v1/data/speedtest", ImUpAPIHost))
-		blocklistedIPs = flag.String("blocklisted-ips", "", "comma separated list of CIDR strings to match against host IP that determines whether speed and connectivity testing will be paused, default is block none")
+		asnDatabase = flag.String("asn-database", "", "path to a MaxMind DB (.mmdb) or CSV database of autonomous systems used to record the isp of the public and speed test server addresses, re-read when it is updated, default is none")
+		blocklistedIPs = flag.String("blocklisted-ips", "", "comma separated list of CIDR strings or network rules, e.g. gateway-mac:aa:bb:cc:dd:ee:ff, that determines whether speed and connectivity testing will be paused, default is block none")
+		commandResultsAddress = flag.String("command-results-address", "", fmt.Sprintf("api endpoint diagnostic command results are posted to, default is %s/v1/realtime/commandResults", ImUpAPIHost))
+		configAuditFile = flag.String("config-audit-file", "", "writes an audit trail of applied configurations to this file path, default is the imup directory in the user cache")
 		configVersion = flag.String("config-version", "", "config version for realtime reloadable configs") //todo: placeholder for reloadable configs
 		connDelay = flag.String("conn-delay", "", "the delay between connectivity tests with a net dialer (milliseconds), default is 200")
 		connInterval = flag.String("conn-interval", "", "how often a dial test is run (seconds), default is 60")
 		connRequests = flag.String("conn-requests", "", "the number of dials executed during a connectivity test, default is 300")
+		credentialHelper = flag.String("credential-helper", "", "command that prints a secret to stdout, invoked with the secret name (API_KEY, EMAIL) as its last argument, either the path of the command or a json list of the command and its arguments, e.g. [\"/opt/vault helper/get\", \"--profile\", \"prod\"]; it is not run through a shell")
+		diagnosticCommands = flag.String("diagnostic-commands", "", "comma separated list of diagnostic commands the imup api may run on this host, e.g. report-config,run-ping-to-target, default is none")
 		email = flag.String("email", "", "email address associated with the gathered connectivity and speed data")
+		emailFile = flag.String("email-file", "", "path to a file containing the email address, re-read when it is rotated")
+		errorReportFile = flag.String("error-report-file", "", "file errors are appended to when the error reporter is file, default is the imup directory in the user cache")
+		errorReporter = flag.String("error-reporter", "", "where client errors are reported [honeybadger, sentry, file, none], default is honeybadger")
 		groupID = flag.String("group-id", "", "an imup org users group id")
 		hostID = flag.String("host-id", "", "the host id associated with the gathered connectivity and speed data")
+		influxDBToken = flag.String("influxdb-token", "", "token used to authorize writes to influxdb")
+		influxDBTokenFile = flag.String("influxdb-token-file", "", "path to a file containing the influxdb token, re-read when it is rotated")
+		influxDBURL = flag.String("influxdb-url", "", "influxdb http write url measurements are also sent to as line protocol, default is unset")
 		imupDataLength = flag.String("imup-data-length", "", "the number of data points collected before sending data to the api, default is 15 data points")
+		mqttBroker = flag.String("mqtt-broker", "", "mqtt broker url measurements are also published to, e.g. tcp://localhost:1883, default is unset")
+		mqttClientID = flag.String("mqtt-client-id", "", "client id used to connect to the mqtt broker, default is imup-<host id>")
+		mqttPassword = flag.String("mqtt-password", "", "password used to connect to the mqtt broker")
+		mqttPasswordFile = flag.String("mqtt-password-file", "", "path to a file containing the mqtt password, re-read when it is rotated")
+		mqttQoS = flag.String("mqtt-qos", "", "mqtt quality of service [0, 1, 2] for published messages, default is 1")
+		mqttTopicPrefix = flag.String("mqtt-topic-prefix", "", "first level of every published mqtt topic, default is imup")
+		mqttUsername = flag.String("mqtt-username", "", "username used to connect to the mqtt broker")
 		logFile = flag.String("log-file", "", "writes all logs to this file path, default is unset")
+		logMaxAge = flag.String("log-max-age", "", "days a log file is written to before it is rotated, 0 disables age based rotation, default is 7")
+		logMaxBackups = flag.String("log-max-backups", "", "number of rotated log files kept, 0 keeps every file, default is 5")
+		logMaxSize = flag.String("log-max-size", "", "size in megabytes a log file grows to before it is rotated, 0 disables size based rotation, default is 10")
+		logOutput = flag.String("log-output", "", "where logs are written [json, syslog, journald], json logs are written to stderr or a log file, default is json")
+		maintenanceWindows = flag.String("maintenance-windows", "", "comma separated list of planned isp maintenance windows, weekly as in sun 02:00-04:00 or once as in 2026-11-02T01:00/2026-11-02T05:00, connectivity data collected during one is tagged maintenance and not counted as downtime, default is none")
+		monitoringSchedule = flag.String("monitoring-schedule", "", "comma separated list of weekly windows connectivity and speed testing run in, e.g. mon-fri 08:00-18:00,sat+sun 10:00-14:00, default is always")
+		monitoringTimezone = flag.String("monitoring-timezone", "", "IANA timezone of the monitoring schedule and maintenance windows, e.g. America/New_York, default is the local timezone")
 		livenessCheckInAddress = flag.String("liveness-check-in-address", "", fmt.Sprintf("api endpoint for liveness checkins default is %s/v1/realtime/livenesscheckin", ImUpAPIHost))
+		networkProfiles = flag.String("network-profiles", "", "json list of monitoring profiles for the networks they match, e.g. [{\"name\": \"office\", \"match\": [\"gateway-mac:aa:bb:cc:dd:ee:ff\"], \"speedTests\": false}]")
+		otlpEndpoint = flag.String("otlp-endpoint", "", "base url of an otlp/http collector traces and metrics are exported to, e.g. http://localhost:4318, default is unset")
 		pingAddressesExternal = flag.String("ping-addresses-external", "", "external IP addresses imup will use to validate connectivity, defaults are 1.1.1.1/32,1.0.0.1/32,8.8.8.8/32,8.8.4.4/32")
 		pingAddressInternal = flag.String("ping-address-internal", "", "an internal gateway to differentiate between local networking issues and internet connectivity, by default imup attempts to discover your gateway")
 		pingDelay = flag.String("ping-delay", "", "the delay between connectivity tests with ping (milliseconds), default is 100")
 		pingInterval = flag.String("ping-interval", "", "how often a ping test is run (seconds), default is 60")
 		pingRequests = flag.String("ping-requests", "", "the number of icmp echos executed during a ping test, default is 600")
+		pushAddress = flag.String("push-address", "", fmt.Sprintf("websocket endpoint the imup api pushes realtime commands and configuration over, default is %s/v1/realtime/push", ImUpAPIHost))
 		realtimeAuthorized = flag.String("realtime-authorized", "", fmt.Sprintf("api endpoint for imup real-time features, default is %s/v1/auth/realtimeAuthorized", ImUpAPIHost))
 		realtimeConfig = flag.String("realtime-config", "", fmt.Sprintf("api endpoint for imup realtime reloadable configuration, default is %s/v1/realtime/config", ImUpAPIHost))
+		realtimeConfigAck = flag.String("realtime-config-ack", "", fmt.Sprintf("api endpoint to acknowledge an applied realtime configuration, default is %s/v1/realtime/configApplied", ImUpAPIHost))
+		sentryDSN = flag.String("sentry-dsn", "", "dsn of a sentry compatible project errors are reported to when the error reporter is sentry")
+		sentryDSNFile = flag.String("sentry-dsn-file", "", "path to a file containing the sentry dsn")
 		shouldRunSpeedTestAddress = flag.String("should-run-speed-test-address", "", fmt.Sprintf("api endpoint for imup realtime speed tests, default is %s/v1/realtime/shouldClientRunSpeedTest", ImUpAPIHost))
+		sinkFile = flag.String("sink-file", "", "file path measurements are also appended to as json lines, default is unset")
 		speedTestResultsAddress = flag.String("speed-test-results-address", "", fmt.Sprintf("api endpoint for imup realtime speed test results, default is %s/v1/realtime/speedTestResults", ImUpAPIHost))
 		speedTestStatusUpdateAddress = flag.String("speed-test-status-update-address", "", fmt.Sprintf("api endpoint for imup real-time speed test status updates, default is %s/v1/realtime/speedTestStatusUpdate", ImUpAPIHost))
+		stateFile = flag.String("state-file", "", "file the client keeps its state in between restarts, default is the imup directory in the user cache")
+		statusAddress = flag.String("status-address", "", "address the local status server listens on, default is 127.0.0.1:4900")
+		tunnelInterfaces = flag.String("tunnel-interfaces", "", "comma separated list of interface name prefixes that are vpns or tunnels in addition to tun, tap, wg, utun and other known names, default is none")
+		tunnelSpeedTests = flag.String("tunnel-speed-tests", "", "scheduled speed tests while the internet is routed through a vpn or tunnel [run, pause, both], both also tests around the tunnel, default is run")
+		syslogAddress = flag.String("syslog-address", "", "syslog server logs are sent to when the log output is syslog, e.g. udp://localhost:514, default is the local syslog socket")
 		verbosity = flag.String("verbosity", "", "verbosity for log output [debug, info, warn, error], default is info")
+		webhookURL = flag.String("webhook-url", "", "url measurements are also posted to as json, default is unset")
 
 		insecureSpeedTest = flag.Bool("insecure", false, "run insecure speed tests (ws:// and not wss://), default is false")
+		logCompress = flag.Bool("log-compress", false, "gzip rotated log files, default is false")
 		logToFile = flag.Bool("log-to-file", false, "if enabled, will log to the default root directory to use for user-specified cached data, default is false")
+		metricsEnabled = flag.Bool("metrics", false, "expose prometheus metrics at /metrics on the status server address, default is false")
+		noAPISink = flag.Bool("no-api-sink", false, "do not send measurements to the imup api, default is false")
 		noGatewayDiscovery = flag.Bool("no-gateway-discovery", false, "do not attempt to discover a default gateway, default is true")
 		noSpeedTest = flag.Bool("no-speed-test", false, "do not run speed tests, default is false")
+		noPush = flag.Bool("no-push", false, "poll for realtime commands and configuration instead of keeping a websocket open to the imup api, default is false")
 		nonvolatile = flag.Bool("nonvolatile", false, "use disk to store collected data between tests to ensure no lost data, default is false to be minimally invasive")
 		pingEnabled = flag.Bool("ping", true, "use ICMP ping for connectivity tests, default is true")
 		realtimeEnabled = flag.Bool("realtime", true, "enable realtime features, default is true")
+		statusServer = flag.Bool("status-server", false, "serve the current state of the client on a local http listener, default is false")
 
 		flag.Parse()
 	})
 
 	hostname, _ := os.Hostname()
 
-	cfg.apiKey = util.ValueOr(apiKey, "API_KEY", "")
-	cfg.email = util.ValueOr(email, "EMAIL", "unknown")
+	// secrets passed directly take precedence over files and credential helpers
+	helper, err := commandArgs(util.ValueOr(credentialHelper, "CREDENTIAL_HELPER", ""))
+	if err != nil {
+		return nil, fmt.Errorf("configuration of client is not valid: credential helper: %v", err)
+	}
+	// the credential helper is only asked for secrets of features that are configured
+	source := func(name string, file *string, needed bool) util.SecretSource {
+		src := util.SecretSource{Name: name, File: util.ValueOr(file, name+"_FILE", "")}
+		if needed {
+			src.Helper = helper
+		}
+		return src
+	}
+
+	cfg.apiKey = cfg.readSecret(apiKey, source("API_KEY", apiKeyFile, true), "", func(c *config) *string { return &c.apiKey })
+	cfg.email = cfg.readSecret(email, source("EMAIL", emailFile, true), "unknown", func(c *config) *string { return &c.email })
+
 	cfg.hostID = util.ValueOr(hostID, "HOST_ID", hostname)
 
+	// never log an api key or email address
+	util.RegisterSecret(cfg.apiKey)
+	if cfg.email != "unknown" {
+		util.RegisterSecret(cfg.email)
+	}
+
 	cfg.AllowlistedIPs = strings.Split(util.ValueOr(allowlistedIPs, "ALLOWLISTED_IPS", ""), ",")
 	cfg.APIPostConnectionData = util.ValueOr(apiPostConnectionData, "IMUP_ADDRESS", fmt.Sprintf("%s/v1/data/connectivity", ImUpAPIHost))
 	cfg.APIPostSpeedTestData = util.ValueOr(apiPostSpeedTestData, "IMUP_ADDRESS_SPEEDTEST", fmt.Sprintf("%s/v1/data/speedtest", ImUpAPIHost))
@@ -216,16 +457,47 @@ func New() (Reloadable, error) {
 	cfg.Group = util.ValueOr(groupID, "GROUP_ID", "")
 
 	cfg.PingAddressInternal = util.ValueOr(pingAddressInternal, "PING_ADDRESS_INTERNAL", cfg.discoverGateway())
+	cfg.gatewayDiscovered = util.ValueOr(pingAddressInternal, "PING_ADDRESS_INTERNAL", "") == ""
 	cfg.LivenessCheckInAddress = util.ValueOr(livenessCheckInAddress, "IMUP_LIVENESS_CHECKIN_ADDRESS", fmt.Sprintf("%s/v1/realtime/livenesscheckin", ImUpAPIHost))
 	cfg.RealtimeAuthorized = util.ValueOr(realtimeAuthorized, "IMUP_REALTIME_AUTHORIZED", fmt.Sprintf("%s/v1/auth/realtimeAuthorized", ImUpAPIHost))
 	cfg.RealtimeConfig = util.ValueOr(realtimeConfig, "IMUP_REALTIME_CONFIG", fmt.Sprintf("%s/v1/realtime/config", ImUpAPIHost))
+	cfg.RealtimeConfigAck = util.ValueOr(realtimeConfigAck, "IMUP_REALTIME_CONFIG_ACK", fmt.Sprintf("%s/v1/realtime/configApplied", ImUpAPIHost))
+	cfg.CommandResultsAddress = util.ValueOr(commandResultsAddress, "IMUP_COMMAND_RESULTS_ADDRESS", fmt.Sprintf("%s/v1/realtime/commandResults", ImUpAPIHost))
+	cfg.DiagnosticCommandsAllowed = list(util.ValueOr(diagnosticCommands, "DIAGNOSTIC_COMMANDS", ""))
+	cfg.PushAddress = util.ValueOr(pushAddress, "IMUP_PUSH_ADDRESS", fmt.Sprintf("%s/v1/realtime/push", ImUpAPIHost))
+	cfg.PushEnabled = !util.BooleanValueOr(noPush, "NO_PUSH", "false")
 	cfg.ShouldRunSpeedTestAddress = util.ValueOr(shouldRunSpeedTestAddress, "IMUP_SHOULD_RUN_SPEEDTEST_ADDRESS", fmt.Sprintf("%s/v1/realtime/shouldClientRunSpeedTest", ImUpAPIHost))
 	cfg.SpeedTestResultsAddress = util.ValueOr(speedTestResultsAddress, "IMUP_SPEED_TEST_RESULTS_ADDRESS", fmt.Sprintf("%s/v1/realtime/speedTestResults", ImUpAPIHost))
 	cfg.SpeedTestStatusUpdateAddress = util.ValueOr(speedTestStatusUpdateAddress, "IMUP_SPEED_TEST_STATUS_ADDRESS", fmt.Sprintf("%s/v1/realtime/speedTestStatusUpdate", ImUpAPIHost))
 
+	cfg.StatusAddr = util.ValueOr(statusAddress, "STATUS_ADDRESS", "127.0.0.1:4900")
+	cfg.StatusEnabled = util.BooleanValueOr(statusServer, "STATUS_SERVER", "false")
+	cfg.MetricsEnabled = util.BooleanValueOr(metricsEnabled, "METRICS", "false")
+
+	cfg.APISinkEnabled = !util.BooleanValueOr(noAPISink, "NO_API_SINK", "false")
+	cfg.InfluxDBAddress = util.ValueOr(influxDBURL, "INFLUXDB_URL", "")
+	cfg.influxDBToken = cfg.readSecret(influxDBToken, source("INFLUXDB_TOKEN", influxDBTokenFile, cfg.InfluxDBAddress != ""), "", func(c *config) *string { return &c.influxDBToken })
+	util.RegisterSecret(cfg.influxDBToken)
+	cfg.SinkFilePath = util.ValueOr(sinkFile, "SINK_FILE", "")
+	cfg.WebhookAddress = util.ValueOr(webhookURL, "WEBHOOK_URL", "")
+	cfg.MQTTBrokerAddress = util.ValueOr(mqttBroker, "MQTT_BROKER", "")
+	cfg.MQTTClient = util.ValueOr(mqttClientID, "MQTT_CLIENT_ID", "")
+	cfg.MQTTUser = util.ValueOr(mqttUsername, "MQTT_USERNAME", "")
+	cfg.mqttPassword = cfg.readSecret(mqttPassword, source("MQTT_PASSWORD", mqttPasswordFile, cfg.MQTTBrokerAddress != ""), "", func(c *config) *string { return &c.mqttPassword })
+	util.RegisterSecret(cfg.mqttPassword)
+	cfg.MQTTTopic = util.ValueOr(mqttTopicPrefix, "MQTT_TOPIC_PREFIX", "imup")
+
+	cfg.OTLPAddress = util.ValueOr(otlpEndpoint, "OTLP_ENDPOINT", "")
+
+	cfg.StateFilePath = util.ValueOr(stateFile, "STATE_FILE", defaultStateFile())
+
+	cfg.ErrorReporting = util.ValueOr(errorReporter, "ERROR_REPORTER", "honeybadger")
+	cfg.ErrorReportFilePath = util.ValueOr(errorReportFile, "ERROR_REPORT_FILE", defaultErrorReportFile())
+	cfg.sentryDSN = cfg.readSecret(sentryDSN, source("SENTRY_DSN", sentryDSNFile, cfg.ErrorReporting == "sentry"), "", func(c *config) *string { return &c.sentryDSN })
+	util.RegisterSecret(cfg.sentryDSN)
+
 	cfg.PingAddressesExternal = strings.Split(util.ValueOr(pingAddressesExternal, "PING_ADDRESS", "1.1.1.1/32,1.0.0.1/32,8.8.8.8/32,8.8.4.4/32"), ",")
 
-	var err error
 	connDelayStr := util.ValueOr(connDelay, "CONN_DELAY", "200")
 	cfg.ConnDelay, err = strconv.Atoi(connDelayStr)
 	if err != nil {
@@ -268,7 +540,33 @@ func New() (Reloadable, error) {
 		panic(err)
 	}
 
+	mqttQoSStr := util.ValueOr(mqttQoS, "MQTT_QOS", "1")
+	cfg.MQTTQualityOfService, err = strconv.Atoi(mqttQoSStr)
+	if err != nil {
+		panic(err)
+	}
+
+	logMaxAgeStr := util.ValueOr(logMaxAge, "LOG_MAX_AGE", "7")
+	cfg.LogMaxAge, err = strconv.Atoi(logMaxAgeStr)
+	if err != nil {
+		panic(err)
+	}
+
+	logMaxBackupsStr := util.ValueOr(logMaxBackups, "LOG_MAX_BACKUPS", "5")
+	cfg.LogMaxBackups, err = strconv.Atoi(logMaxBackupsStr)
+	if err != nil {
+		panic(err)
+	}
+
+	logMaxSizeStr := util.ValueOr(logMaxSize, "LOG_MAX_SIZE", "10")
+	cfg.LogMaxSize, err = strconv.Atoi(logMaxSizeStr)
+	if err != nil {
+		panic(err)
+	}
+
+	cfg.LogCompress = util.BooleanValueOr(logCompress, "LOG_COMPRESS", "false")
 	logFilePathStr := util.ValueOr(logFile, "LOG_FILE", "")
+	cfg.auditFile = util.ValueOr(configAuditFile, "CONFIG_AUDIT_FILE", defaultAuditFile())
 	cfg.InsecureSpeedTest = util.BooleanValueOr(insecureSpeedTest, "INSECURE_SPEED_TEST", "false")
 	cfg.FileLogger = util.BooleanValueOr(logToFile, "LOG_TO_FILE", "false")
 	cfg.NoDiscoverGateway = util.BooleanValueOr(noGatewayDiscovery, "NO_GATEWAY_DISCOVERY", "false")
@@ -278,63 +576,227 @@ func New() (Reloadable, error) {
 	cfg.RealtimeEnabled = util.BooleanValueOr(realtimeEnabled, "REALTIME", "true")
 
 	cfg.logLevel = util.LevelMap(verbosity, "VERBOSITY", "info")
+	cfg.LogOutput = util.ValueOr(logOutput, "LOG_OUTPUT", "json")
+	cfg.SyslogAddress = util.ValueOr(syslogAddress, "SYSLOG_ADDRESS", "")
+
+	cfg.TunnelPrefixes = list(util.ValueOr(tunnelInterfaces, "TUNNEL_INTERFACES", ""))
+	cfg.TunnelSpeedTest = util.ValueOr(tunnelSpeedTests, "TUNNEL_SPEED_TESTS", TunnelSpeedTestsRun)
+	cfg.ASNDatabasePath = util.ValueOr(asnDatabase, "ASN_DATABASE", "")
+
+	cfg.MonitoringSchedule = list(util.ValueOr(monitoringSchedule, "MONITORING_SCHEDULE", ""))
+	cfg.MaintenanceWindows = list(util.ValueOr(maintenanceWindows, "MAINTENANCE_WINDOWS", ""))
+	cfg.Timezone = util.ValueOr(monitoringTimezone, "MONITORING_TIMEZONE", "")
 
 	var w io.Writer
 	if logFilePathStr != "" {
-		w = logToThisFile(logFilePathStr)
+		w = logToThisFile(logFilePathStr, cfg.rotateOptions())
 	} else if cfg.FileLogger {
-		w = logToUserCache()
+		w = logToUserCache(cfg.rotateOptions())
 	} else {
 		w = os.Stderr
 	}
 
-	configureLogger(cfg.logLevel, w)
+	configureLogger(cfg, w)
+
+	if cfg.Profiles, err = netid.ParseProfiles(util.ValueOr(networkProfiles, "NETWORK_PROFILES", "")); err != nil {
+		return nil, fmt.Errorf("configuration of client is not valid: network profiles: %v", err)
+	}
+	cfg.compileRules()
+
+	if local {
+		if err := cfg.validateSettings(); err != nil {
+			return nil, fmt.Errorf("configuration of client is not valid: %s", err)
+		}
+
+		return cfg, nil
+	}
 
 	if err := cfg.validate(); err != nil {
 		return nil, fmt.Errorf("configuration of client is not valid: %s", err)
 	}
 
+	// the startup configuration is the first entry of the audit trail
+	record := auditRecord{Timestamp: time.Now(), HostID: cfg.hostID, Version: cfg.ConfigVersion, Changes: diff(&config{}, cfg)}
+	if err := appendAudit(cfg.auditFile, record); err != nil {
+		log.Warn("cannot record startup configuration", "error", err)
+	}
+
 	return cfg, nil
 }
 
-func configureLogger(verbosity log.Level, w io.Writer) {
-	h := log.NewJSONHandler(w, &log.HandlerOptions{Level: verbosity})
-	log.SetDefault(log.New(h))
+// logMu protects the log writer and handler, which are replaced when the logger is reconfigured
+var logMu sync.Mutex
+
+// logWriter is where json logs are written
+var logWriter io.Writer = os.Stderr
+
+// logHandler is the syslog or journald handler in use, it is closed when the logger is reconfigured
+var logHandler io.Closer
+
+// configureLogger sets the default logger for c, json logs are written to w or the previous writer when w is nil
+func configureLogger(c *config, w io.Writer) {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if w != nil && w != logWriter {
+		// a closed log file is reopened if a stale logger writes to it
+		if f, ok := logWriter.(*logging.RotatingFile); ok {
+			f.Close()
+		}
+
+		logWriter = w
+	}
+
+	opts := &log.HandlerOptions{Level: c.logLevel}
+	previous := logHandler
+	logHandler = nil
+
+	h, err := outputHandler(c.LogOutput, c.SyslogAddress, opts)
+	if h != nil {
+		logHandler = h
+		log.SetDefault(log.New(util.NewRedactingHandler(h)))
+	} else {
+		log.SetDefault(log.New(util.NewRedactingHandler(log.NewJSONHandler(logWriter, opts))))
+	}
+
+	if previous != nil {
+		previous.Close()
+	}
+
+	if err != nil {
+		log.Error("cannot configure log output, writing json logs instead", "output", c.LogOutput, "error", err)
+	}
+}
+
+// LogFile is the file logs are written to, empty when they are not written to a file
+func LogFile() string {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if f, ok := logWriter.(*logging.RotatingFile); ok && logHandler == nil {
+		return f.Path()
+	}
+
+	return ""
+}
+
+// ReopenLogs reopens the log file, if logs are written to one. Use it when an external
+// tool such as logrotate has moved the file aside.
+func ReopenLogs() error {
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	if f, ok := logWriter.(*logging.RotatingFile); ok {
+		return f.Reopen()
+	}
+
+	return nil
 }
 
-func logToThisFile(file string) *os.File {
-	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+func outputHandler(output, syslogAddress string, opts *log.HandlerOptions) (*logging.Handler, error) {
+	switch output {
+	case "syslog":
+		return logging.NewSyslog(syslogAddress, opts)
+	case "journald":
+		return logging.NewJournald(opts)
+	}
+
+	return nil, nil
+}
+
+func logToThisFile(file string, opts logging.RotateOptions) io.Writer {
+	f, err := logging.NewRotatingFile(file, opts)
 	if err != nil {
-		log.Error("cannot open file", "error", err)
+		log.Error("cannot open file, logging to stderr", "error", err)
+		return os.Stderr
 	}
 
 	log.Debug("log file at", "file", file)
 	return f
 }
 
-func logToUserCache() *os.File {
+// defaultStateFile is where the client keeps its state between restarts
+func defaultStateFile() string {
 	cache, err := os.UserCacheDir()
 	if err != nil {
 		log.Error("$HOME is likely undefined", "error", err)
+		return ""
 	}
 
-	targetDir := filepath.Join(cache, "imup", "logs")
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		log.Error("cannot create directory in user cache", "error", err)
+	return filepath.Join(cache, "imup", "state", "client.json")
+}
+
+// defaultErrorReportFile is where errors are written when they are reported to a file
+func defaultErrorReportFile() string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		log.Error("$HOME is likely undefined", "error", err)
+		return ""
 	}
 
-	f, err := os.OpenFile(filepath.Join(targetDir, "imup.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	return filepath.Join(cache, "imup", "errors", "errors.log")
+}
+
+func logToUserCache(opts logging.RotateOptions) io.Writer {
+	cache, err := os.UserCacheDir()
 	if err != nil {
-		log.Error("cannot open file", "error", err)
+		log.Error("$HOME is likely undefined", "error", err)
 	}
 
+	targetDir := filepath.Join(cache, "imup", "logs")
 	log.Debug("log file located at", "path", targetDir)
-	return f
+
+	return logToThisFile(filepath.Join(targetDir, "imup.log"), opts)
+}
+
+// rotateOptions controls the rotation of log files
+func (c *config) rotateOptions() logging.RotateOptions {
+	return logging.RotateOptions{
+		MaxSize:    int64(c.LogMaxSize) * 1024 * 1024,
+		MaxAge:     time.Duration(c.LogMaxAge) * 24 * time.Hour,
+		MaxBackups: c.LogMaxBackups,
+		Compress:   c.LogCompress,
+	}
 }
 
 func (cfg *config) validate() error {
 	if (cfg.email == "unknown" || cfg.email == "") && (cfg.apiKey == "" || cfg.hostID == "") {
-		return fmt.Errorf("please supply an email address (--email) or api key and host id (--key, --host-id)!: email: %s, key: %s, host id: %s", cfg.email, cfg.apiKey, cfg.hostID)
+		return fmt.Errorf("please supply an email address (--email) or api key and host id (--key, --host-id)!: email: %s, key: %s, host id: %s", redactSecret(cfg.email), redactSecret(cfg.apiKey), cfg.hostID)
+	}
+
+	return cfg.validateSettings()
+}
+
+// validateSettings validates everything but the credentials
+func (cfg *config) validateSettings() error {
+	switch cfg.LogOutput {
+	case "json", "syslog", "journald":
+	default:
+		return fmt.Errorf("log output must be one of json, syslog or journald: %s", cfg.LogOutput)
+	}
+
+	switch cfg.ErrorReporting {
+	case "honeybadger", "file", "none":
+	case "sentry":
+		if cfg.sentryDSN == "" {
+			return fmt.Errorf("a sentry dsn is required to report errors to sentry")
+		}
+	default:
+		return fmt.Errorf("error reporter must be one of honeybadger, sentry, file or none: %s", cfg.ErrorReporting)
+	}
+
+	switch cfg.TunnelSpeedTest {
+	case "", TunnelSpeedTestsRun, TunnelSpeedTestsPause, TunnelSpeedTestsBoth:
+	default:
+		return fmt.Errorf("tunnel speed tests must be one of run, pause or both: %s", cfg.TunnelSpeedTest)
+	}
+
+	if _, err := schedule.New(cfg.MonitoringSchedule, cfg.MaintenanceWindows, cfg.Timezone); err != nil {
+		return fmt.Errorf("monitoring schedule: %v", err)
+	}
+
+	if cfg.MQTTQualityOfService < 0 || cfg.MQTTQualityOfService > 2 {
+		return fmt.Errorf("mqtt qos must be 0, 1 or 2: %d", cfg.MQTTQualityOfService)
 	}
 
 	return nil
@@ -378,11 +840,35 @@ func (c *config) EnableRealtime() {
 	c.RealtimeEnabled = true
 }
 
-// PublicIP retrieves the clients public ip address
+// PublicIP retrieves the clients public ip address, its ipv4 address
+// unless it only has an ipv6 address
 func (c *config) PublicIP() string {
 	mu.RLock()
 	defer mu.RUnlock()
-	return c.publicIP
+	return c.preferredPublicIP()
+}
+
+// PublicIPs retrieves the clients public ipv4 and ipv6 addresses that are known
+func (c *config) PublicIPs() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	ips := []string{}
+	for _, ip := range []string{c.publicIPv4, c.publicIPv6} {
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+
+	return ips
+}
+
+func (c *config) preferredPublicIP() string {
+	if c.publicIPv4 != "" {
+		return c.publicIPv4
+	}
+
+	return c.publicIPv6
 }
 
 // Realtime boolean indicating wether or not realtime features should be used
@@ -392,45 +878,164 @@ func (c *config) Realtime() bool {
 	return c.RealtimeEnabled
 }
 
-// RefreshPublicIP uses an open api to retrieve the clients public ip address
+// RefreshPublicIP asks several providers for the clients public ip addresses,
+// the last known addresses are kept when none of them answers
 func (c *config) RefreshPublicIP() string {
 	ip, err := getIP()
 	if err != nil {
-		log.Warn("cannot get public ip", err)
-		return c.publicIP
+		log.Warn("cannot get public ip", "error", err)
+		return c.PublicIP()
 	}
 
-	if ip != c.publicIP {
-		mu.Lock()
-		log.Debug("setting publicIP", "publicIP", ip)
-		c.publicIP = ip
-		defer mu.Unlock()
+	mu.Lock()
+	defer mu.Unlock()
+
+	if ip.IPv4 != c.publicIPv4 || ip.IPv6 != c.publicIPv6 {
+		log.Debug("setting publicIP", "ipv4", ip.IPv4, "ipv6", ip.IPv6)
+		c.publicIPv4, c.publicIPv6 = ip.IPv4, ip.IPv6
 	}
 
-	return c.publicIP
+	return c.preferredPublicIP()
 }
 
-func getIP() (string, error) {
-	req, err := http.Get("https://api64.ipify.org?format=json")
-	if err != nil {
-		return "", err
+// SetPublicIP sets the public ip address, e.g. to the last known address
+// until it can be refreshed
+func (c *config) SetPublicIP(ip string) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if addr, err := netip.ParseAddr(ip); err == nil && addr.Is6() && !addr.Is4In6() {
+		c.publicIPv6 = ip
+	} else {
+		c.publicIPv4 = ip
+	}
+}
+
+// SetVerbosity changes the log level without changing the configuration,
+// e.g. to collect debug logs for a while
+func (c *config) SetVerbosity(level log.Level) {
+	mu.Lock()
+	c.logLevel = level
+	mu.Unlock()
+
+	configureLogger(c, nil)
+}
+
+// secret is a value read from a file or credential helper, field points at where it is kept
+type secret struct {
+	source util.SecretSource
+	field  func(c *config) *string
+}
+
+// readSecret returns the value of a secret passed as a flag or in the environment variable named
+// like src, otherwise it reads it from src, which is kept to pick up rotated values
+func (c *config) readSecret(value *string, src util.SecretSource, fallback string, field func(c *config) *string) string {
+	if util.ValueOr(value, src.Name, "") != "" || !src.Configured() {
+		return util.ValueOr(value, src.Name, fallback)
 	}
-	defer req.Body.Close()
 
-	body, err := io.ReadAll(req.Body)
+	c.secrets = append(c.secrets, secret{source: src, field: field})
+	return src.ValueOr(fallback)
+}
+
+// RefreshSecrets re-reads secrets from their files or credential helper to pick up rotated values,
+// a credential helper runs without holding the configuration lock
+func (c *config) RefreshSecrets() {
+	mu.RLock()
+	secrets := c.secrets
+	mu.RUnlock()
+
+	for _, s := range secrets {
+		mu.RLock()
+		current := *s.field(c)
+		mu.RUnlock()
+
+		if v, ok := refreshSecret(s.source, current); ok {
+			mu.Lock()
+			*s.field(c) = v
+			mu.Unlock()
+		}
+	}
+}
+
+// refreshSecret reads a secret from its source and reports if it has been rotated
+func refreshSecret(src util.SecretSource, current string) (string, bool) {
+	if !src.Configured() {
+		return current, false
+	}
+
+	v, err := src.Read(context.Background())
 	if err != nil {
-		return "", err
+		log.Warn("cannot refresh secret", "name", src.Name, "error", err)
+		return current, false
 	}
 
-	type IP struct {
-		IP string `json:"ip"`
+	if v == "" || v == current {
+		return current, false
 	}
-	var ip IP
-	if err := json.Unmarshal(body, &ip); err != nil {
-		return "", err
+
+	util.RegisterSecret(v)
+	log.Info("secret rotated", "name", src.Name)
+
+	return v, true
+}
+
+// ipResolver discovers the public ip addresses of the client, its results are cached briefly
+// so that a config reload right after a refresh does not ask the providers again
+var ipResolver = publicip.New(publicip.Options{})
+
+func getIP() (publicip.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	return ipResolver.Lookup(ctx)
+}
+
+// list splits a comma separated value, ignoring whitespace and empty elements
+func list(value string) []string {
+	l := []string{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
 	}
 
-	return ip.IP, nil
+	return l
+}
+
+// compileRules compiles the allow and block lists, network profiles and the monitoring
+// schedule once instead of on every check
+func (c *config) compileRules() {
+	c.allowRules = netid.NewRules(c.AllowlistedIPs)
+	c.blockRules = netid.NewRules(c.BlocklistedIPs)
+	c.profiles = netid.NewProfiles(c.Profiles)
+
+	// an invalid schedule does not pass validation
+	c.schedule, _ = schedule.New(c.MonitoringSchedule, c.MaintenanceWindows, c.Timezone)
+}
+
+// commandArgs returns the arguments of a command given as its path or as a json list of the
+// command and its arguments, a path is never split on spaces
+func commandArgs(command string) ([]string, error) {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(command, "[") {
+		return []string{command}, nil
+	}
+
+	args := []string{}
+	if err := json.Unmarshal([]byte(command), &args); err != nil {
+		return nil, fmt.Errorf("not a json list of strings: %v", err)
+	}
+
+	if len(args) == 0 || args[0] == "" {
+		return nil, fmt.Errorf("no command in %s", command)
+	}
+
+	return args, nil
 }
 
 func ips(ips []string) []string {
@@ -512,6 +1117,152 @@ func (c *config) RealtimeConfigURL() string {
 	return cfg.RealtimeConfig
 }
 
+func (c *config) RealtimeConfigAckURL() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.RealtimeConfigAck
+}
+
+func (c *config) PushURL() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.PushAddress
+}
+
+func (c *config) CommandResultsURL() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.CommandResultsAddress
+}
+
+// DiagnosticCommands are the diagnostic commands the api may run on this host
+func (c *config) DiagnosticCommands() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]string{}, cfg.DiagnosticCommandsAllowed...)
+}
+
+// Push indicates whether realtime commands and configuration are pushed over a websocket
+func (c *config) Push() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.PushEnabled
+}
+
+func (c *config) StatusServer() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.StatusEnabled
+}
+
+func (c *config) Metrics() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.MetricsEnabled
+}
+
+func (c *config) APISink() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.APISinkEnabled
+}
+
+func (c *config) WebhookURL() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.WebhookAddress
+}
+
+func (c *config) InfluxDBURL() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.InfluxDBAddress
+}
+
+func (c *config) InfluxDBToken() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.influxDBToken
+}
+
+func (c *config) SinkFile() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.SinkFilePath
+}
+
+func (c *config) MQTTBroker() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.MQTTBrokerAddress
+}
+
+func (c *config) MQTTClientID() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.MQTTClient
+}
+
+func (c *config) MQTTUsername() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.MQTTUser
+}
+
+func (c *config) MQTTPassword() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.mqttPassword
+}
+
+func (c *config) MQTTTopicPrefix() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.MQTTTopic
+}
+
+func (c *config) MQTTQoS() byte {
+	mu.RLock()
+	defer mu.RUnlock()
+	return byte(cfg.MQTTQualityOfService)
+}
+
+func (c *config) OTLPEndpoint() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.OTLPAddress
+}
+
+func (c *config) StateFile() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.StateFilePath
+}
+
+func (c *config) ErrorReporter() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.ErrorReporting
+}
+
+func (c *config) ErrorReportFile() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.ErrorReportFilePath
+}
+
+func (c *config) SentryDSN() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.sentryDSN
+}
+
+func (c *config) StatusAddress() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return cfg.StatusAddr
+}
+
 func (c *config) PingAddresses() []string {
 	mu.RLock()
 	defer mu.RUnlock()
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change describes a single field of the effective configuration
+// that differs between two configurations
+type Change struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// String is a human readable representation of a configuration change
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Field, c.Old, c.New)
+}
+
+// diff computes a field level difference between two configurations.
+// Only exported fields are considered, non exported fields hold values
+// that are either secret or not reloadable.
+func diff(prev, next *config) []Change {
+	changes := []Change{}
+	if prev == nil || next == nil {
+		return changes
+	}
+
+	pv := reflect.ValueOf(prev).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	t := pv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		o, n := pv.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(o, n) {
+			continue
+		}
+
+		changes = append(changes, Change{Field: fieldName(f), Old: redactValue(o), New: redactValue(n)})
+	}
+
+	return changes
+}
+
+// preserveNonReloadable copies exported fields without a json tag from prev into next,
+// these fields are read only after startup and are never part of a remote configuration
+func preserveNonReloadable(prev, next *config) {
+	pv := reflect.ValueOf(prev).Elem()
+	nv := reflect.ValueOf(next).Elem()
+	t := pv.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		if _, ok := f.Tag.Lookup("json"); ok {
+			continue
+		}
+
+		nv.Field(i).Set(pv.Field(i))
+	}
+}
+
+// fieldName prefers the json name of a field, which is how the api refers to it
+func fieldName(f reflect.StructField) string {
+	if tag, ok := f.Tag.Lookup("json"); ok {
+		if name, _, _ := strings.Cut(tag, ","); name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return f.Name
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/imup-io/client/util"
+	log "golang.org/x/exp/slog"
+)
+
+// Redacted returns a representation of the effective configuration that is
+// safe to log or share, secrets are masked and url credentials are removed
+func (c *config) Redacted() map[string]any {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	r := map[string]any{
+		"apiKey":     redactSecret(c.apiKey),
+		"email":      redactSecret(c.email),
+		"hostId":     c.hostID,
+		"publicIP":   c.preferredPublicIP(),
+		"publicIPv6": c.publicIPv6,
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := fieldName(f)
+		if util.IsSecretKey(name) {
+			r[name] = redactSecret(fmt.Sprint(v.Field(i).Interface()))
+		} else {
+			r[name] = redactValue(v.Field(i).Interface())
+		}
+	}
+
+	return r
+}
+
+// String implements fmt.Stringer so that formatting a config never exposes a secret
+func (c *config) String() string {
+	return fmt.Sprintf("%v", c.Redacted())
+}
+
+// LogValue implements log.LogValuer so that logging a config never exposes a secret
+func (c *config) LogValue() log.Value {
+	r := c.Redacted()
+
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]log.Attr, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, log.Any(k, r[k]))
+	}
+
+	return log.GroupValue(attrs...)
+}
+
+// redactValue removes url credentials and registered secrets from string values
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return util.Redact(v)
+	case []string:
+		redacted := make([]string, 0, len(v))
+		for _, s := range v {
+			redacted = append(redacted, util.Redact(s))
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+func redactSecret(s string) string {
+	if s == "" {
+		return s
+	}
+
+	return util.Redacted
+}
@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	gw "github.com/jackpal/gateway"
 
+	"github.com/imup-io/client/netid"
+	"github.com/imup-io/client/schedule"
 	"github.com/imup-io/client/util"
 	log "golang.org/x/exp/slog"
 )
@@ -32,10 +35,29 @@ func Reload(data []byte) (Reloadable, error) {
 	c.CFG.email = cfg.email
 	c.CFG.hostID = cfg.hostID
 	c.CFG.apiKey = cfg.apiKey
+	c.CFG.secrets = cfg.secrets
+	c.CFG.auditFile = cfg.auditFile
+	c.CFG.influxDBToken = cfg.influxDBToken
+	c.CFG.mqttPassword = cfg.mqttPassword
+	c.CFG.sentryDSN = cfg.sentryDSN
+	c.CFG.publicIPv4 = cfg.publicIPv4
+	c.CFG.publicIPv6 = cfg.publicIPv6
+	c.CFG.gatewayDiscovered = cfg.gatewayDiscovered
+
+	// as well as the non reloadable endpoints and intervals
+	preserveNonReloadable(cfg, c.CFG)
 
 	var reloadLogger bool
+	c.CFG.logLevel = cfg.logLevel
 	if logLevel := util.LevelMap(&c.CFG.LogLevel, "VERBOSITY", "INFO"); logLevel != cfg.logLevel && c.CFG.LogLevel != "" {
-		cfg.logLevel = logLevel
+		c.CFG.logLevel = logLevel
+		reloadLogger = true
+	}
+
+	// keep the current log output unless one is configured
+	if c.CFG.LogOutput == "" {
+		c.CFG.LogOutput = cfg.LogOutput
+	} else if c.CFG.LogOutput != cfg.LogOutput {
 		reloadLogger = true
 	}
 
@@ -44,7 +66,7 @@ func Reload(data []byte) (Reloadable, error) {
 		reloadLogger = true
 
 		if c.CFG.FileLogger {
-			w = logToUserCache()
+			w = logToUserCache(c.CFG.rotateOptions())
 		} else {
 			w = os.Stderr
 		}
@@ -56,39 +78,91 @@ func Reload(data []byte) (Reloadable, error) {
 
 	// reload logger using configuration from API
 	if reloadLogger {
-		configureLogger(c.CFG.logLevel, w)
+		configureLogger(c.CFG, w)
 	}
-
-	// lock the configuration
-	mu.Lock()
+	c.CFG.compileRules()
 
 	// refresh a clients public IP after a config reload
 	if ip, err := getIP(); err != nil {
 		log.Info("cannot get public ip", "error", err)
-
 	} else {
-		cfg.publicIP = ip
+		c.CFG.publicIPv4, c.CFG.publicIPv6 = ip.IPv4, ip.IPv6
 	}
 
-	log.Info("imup config reloaded", "config", fmt.Sprintf("config: %+v", c.CFG))
+	// lock the configuration
+	mu.Lock()
+
+	changes := diff(cfg, c.CFG)
+	log.Info("imup config reloaded", "version", c.CFG.ConfigVersion, "previousVersion", cfg.ConfigVersion, "changes", changes)
+
+	record := auditRecord{
+		Timestamp:       time.Now(),
+		HostID:          c.CFG.hostID,
+		Version:         c.CFG.ConfigVersion,
+		PreviousVersion: cfg.ConfigVersion,
+		Changes:         changes,
+	}
+	if err := appendAudit(c.CFG.auditFile, record); err != nil {
+		log.Warn("cannot record configuration change", "error", err)
+	}
 
 	cfg = c.CFG
 	defer mu.Unlock()
 	return cfg, nil
 }
 
-// AllowedIPs returns a reloadable list of allow-listed ips for running speed tests
-func (c *config) AllowedIPs() []string {
+// AllowRules returns reloadable rules of allow-listed ips and networks for running speed tests
+func (c *config) AllowRules() *netid.Rules {
+	mu.RLock()
+	defer mu.RUnlock()
+	return c.allowRules
+}
+
+// BlockRules returns reloadable rules of block-listed ips and networks to avoid running speed tests for
+func (c *config) BlockRules() *netid.Rules {
+	mu.RLock()
+	defer mu.RUnlock()
+	return c.blockRules
+}
+
+// NetworkProfiles returns the reloadable monitoring profiles of networks
+func (c *config) NetworkProfiles() *netid.Profiles {
 	mu.RLock()
 	defer mu.RUnlock()
-	return ips(c.AllowlistedIPs)
+	return c.profiles
 }
 
-// AllowedIPs returns a reloadable list of block-listed ips to avoid running speed tests for
-func (c *config) BlockedIPs() []string {
+// TunnelSpeedTests returns how scheduled speed tests run while the internet is routed
+// through a vpn or tunnel, one of TunnelSpeedTestsRun, TunnelSpeedTestsPause or TunnelSpeedTestsBoth
+func (c *config) TunnelSpeedTests() string {
 	mu.RLock()
 	defer mu.RUnlock()
-	return ips(c.BlocklistedIPs)
+
+	if c.TunnelSpeedTest == "" {
+		return TunnelSpeedTestsRun
+	}
+	return c.TunnelSpeedTest
+}
+
+// TunnelInterfaces returns reloadable name prefixes of interfaces that are vpns or tunnels
+func (c *config) TunnelInterfaces() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]string{}, c.TunnelPrefixes...)
+}
+
+// Schedule returns the reloadable monitoring schedule and maintenance windows
+func (c *config) Schedule() *schedule.Schedule {
+	mu.RLock()
+	defer mu.RUnlock()
+	return c.schedule
+}
+
+// ASNDatabase returns the reloadable path of the database autonomous systems are looked up in
+func (c *config) ASNDatabase() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return c.ASNDatabasePath
 }
 
 // discoverGateway provides for automatic gateway discovery
@@ -100,6 +174,31 @@ func (c *config) discoverGateway() string {
 	}
 }
 
+// RefreshGateway discovers the internal gateway again after the host changed networks,
+// a configured internal ping address is kept
+func (c *config) RefreshGateway() string {
+	mu.RLock()
+	discovered, gateway := c.gatewayDiscovered, c.PingAddressInternal
+	if discovered {
+		gateway = c.discoverGateway()
+	}
+	mu.RUnlock()
+
+	if !discovered {
+		return gateway
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if gateway != c.PingAddressInternal {
+		log.Info("internal gateway changed", "gateway", gateway, "previous", c.PingAddressInternal)
+		c.PingAddressInternal = gateway
+	}
+
+	return c.PingAddressInternal
+}
+
 // GroupID is the logical name for a group of org hosts
 func (c *config) GroupID() string {
 	mu.RLock()
@@ -3,6 +3,7 @@ package connectivity
 import (
 	"context"
 	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -10,6 +11,8 @@ type StatCollector interface {
 	Interval() time.Duration
 	Collect(context.Context, []string) []Statistics
 	DetectDowntime([]Statistics) (bool, int)
+	// Avoided returns the addresses skipped because they did not answer a previous test
+	Avoided() []string
 }
 
 type Options struct {
@@ -18,6 +21,9 @@ type Options struct {
 	Count           int
 	Debug           bool
 
+	// Avoid are addresses that did not answer before, e.g. before the client restarted
+	Avoid []string
+
 	Delay    time.Duration
 	Interval time.Duration
 	Timeout  time.Duration
@@ -38,6 +44,44 @@ type Statistics struct {
 	OS              string        `json:"operatingSystem,omitempty"`
 	EndpointType    string        `json:"endpointType,omitempty"`
 	SuccessInternal bool          `json:"successInternal,omitempty"`
+	NetworkID       string        `json:"networkId,omitempty"`
+	Tunnel          string        `json:"tunnel,omitempty"`
+	// Maintenance is set on statistics collected during a planned maintenance window of
+	// the isp, they are not counted as downtime
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+// OutsideMaintenance returns the statistics that were not collected during maintenance
+func OutsideMaintenance(data []Statistics) []Statistics {
+	measured := make([]Statistics, 0, len(data))
+	for _, s := range data {
+		if !s.Maintenance {
+			measured = append(measured, s)
+		}
+	}
+
+	return measured
+}
+
+// avoidList returns addrs as a set
+func avoidList(addrs []string) map[string]bool {
+	avoid := map[string]bool{}
+	for _, a := range addrs {
+		avoid[a] = true
+	}
+
+	return avoid
+}
+
+// avoided returns the addresses in an avoid list in a stable order
+func avoided(avoid map[string]bool) []string {
+	addrs := make([]string, 0, len(avoid))
+	for a := range avoid {
+		addrs = append(addrs, a)
+	}
+	sort.Strings(addrs)
+
+	return addrs
 }
 
 // pingAddress chooses a semi random ping address from a list of ips
@@ -28,7 +28,7 @@ type dialCollector struct {
 
 func NewDialerCollector(opts Options) StatCollector {
 	return &dialCollector{
-		avoidAddrs:    map[string]bool{},
+		avoidAddrs:    avoidList(opts.Avoid),
 		clientVersion: opts.ClientVersion,
 		count:         opts.Count,
 		connected:     0,
@@ -40,6 +40,11 @@ func NewDialerCollector(opts Options) StatCollector {
 	}
 }
 
+// Avoided returns the addresses skipped because they did not answer a previous test
+func (d *dialCollector) Avoided() []string {
+	return avoided(d.avoidAddrs)
+}
+
 // Interval is the time to wait between dialer tests
 func (d *dialCollector) Interval() time.Duration {
 	return d.interval
@@ -68,8 +73,10 @@ func (d *dialCollector) Collect(ctx context.Context, pingAddrs []string) []Stati
 	}
 }
 
-// DetectDowntime only increments downtime if Success is false but Internal Success is true
+// DetectDowntime only increments downtime if Success is false but Internal Success is true,
+// statistics collected during maintenance are ignored
 func (d *dialCollector) DetectDowntime(data []Statistics) (bool, int) {
+	data = OutsideMaintenance(data)
 	if len(data) == 0 {
 		return false, 0
 	}
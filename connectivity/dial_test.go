@@ -69,3 +69,38 @@ func testNoConnData(connected, downtime bool, externalAddrs []string, opts conne
 		}
 	}
 }
+
+func TestAvoided(t *testing.T) {
+	is := is.New(t)
+
+	opts := connectivity.Options{Avoid: []string{"8.8.8.8", "1.1.1.1"}}
+	is.Equal([]string{"1.1.1.1", "8.8.8.8"}, connectivity.NewDialerCollector(opts).Avoided())
+	is.Equal([]string{"1.1.1.1", "8.8.8.8"}, connectivity.NewPingCollector(opts).Avoided())
+	is.Equal([]string{}, connectivity.NewPingCollector(connectivity.Options{}).Avoided())
+}
+
+func TestMaintenanceDowntime(t *testing.T) {
+	is := is.New(t)
+
+	data := []connectivity.Statistics{
+		{EndpointType: "external", Success: true},
+		{EndpointType: "external", SuccessInternal: true, Maintenance: true},
+		{EndpointType: "external", SuccessInternal: true, Maintenance: true},
+		{EndpointType: "external", Success: true},
+		{EndpointType: "external", SuccessInternal: true},
+	}
+
+	changed, dt := connectivity.NewDialerCollector(connectivity.Options{}).DetectDowntime(data)
+	is.True(changed)
+	is.Equal(1, dt) // maintenance is not downtime
+
+	changed, dt = connectivity.NewPingCollector(connectivity.Options{}).DetectDowntime(data)
+	is.True(changed)
+	is.Equal(1, dt)
+
+	changed, dt = connectivity.NewPingCollector(connectivity.Options{}).DetectDowntime(data[:3])
+	is.True(!changed) // an outage during maintenance is not a change of status
+	is.Equal(0, dt)
+
+	is.Equal(3, len(connectivity.OutsideMaintenance(data)))
+}
@@ -27,7 +27,7 @@ type pingCollector struct {
 
 func NewPingCollector(opts Options) StatCollector {
 	return &pingCollector{
-		avoidAddrs:      map[string]bool{},
+		avoidAddrs:      avoidList(opts.Avoid),
 		addressInternal: opts.AddressInternal,
 		count:           opts.Count,
 		clientVersion:   opts.ClientVersion,
@@ -38,6 +38,11 @@ func NewPingCollector(opts Options) StatCollector {
 	}
 }
 
+// Avoided returns the addresses skipped because they did not answer a previous test
+func (p *pingCollector) Avoided() []string {
+	return avoided(p.avoidAddrs)
+}
+
 // Interval is the time to wait between ping testing
 func (p *pingCollector) Interval() time.Duration {
 	return p.interval
@@ -91,8 +96,10 @@ func (p *pingCollector) Collect(ctx context.Context, pingAddrs []string) []Stati
 }
 
 // DetectDowntime only increments downtime if Success is false but Internal Success is true
-// demonstrating a connection to the gateway is not the problem
+// demonstrating a connection to the gateway is not the problem, statistics collected
+// during maintenance are ignored
 func (p *pingCollector) DetectDowntime(data []Statistics) (bool, int) {
+	data = OutsideMaintenance(data)
 	if len((data)) == 0 {
 		return false, 0
 	}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imup-io/client/config"
+	"github.com/imup-io/client/diagnostics"
+	"github.com/imup-io/client/util"
+	log "golang.org/x/exp/slog"
+)
+
+// bounds of the arguments of diagnostic commands
+const (
+	defaultTraceHops    = 30
+	maxTraceHops        = 64
+	defaultLogLines     = 200
+	maxLogLines         = 2000
+	defaultLogLevelTime = 15 * time.Minute
+	maxLogLevelTime     = 24 * time.Hour
+)
+
+type pingTargetArgs struct {
+	Target string `json:"target"`
+}
+
+type tracerouteArgs struct {
+	Target  string `json:"target"`
+	MaxHops int    `json:"maxHops,omitempty"`
+}
+
+type recentLogsArgs struct {
+	Lines int `json:"lines,omitempty"`
+}
+
+type logLevelArgs struct {
+	Level           string `json:"level"`
+	DurationSeconds int    `json:"durationSeconds,omitempty"`
+}
+
+// logLevelResult is the result of set-log-level-temporarily
+type logLevelResult struct {
+	Level    string    `json:"level"`
+	Previous string    `json:"previous"`
+	Until    time.Time `json:"until"`
+}
+
+// flushCacheResult is the result of flush-cache
+type flushCacheResult struct {
+	Sent   int      `json:"sent"`
+	Failed int      `json:"failed"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// temporaryVerbosity restores the log level a diagnostic command changed
+type temporaryVerbosity struct {
+	sync.Mutex
+	timer    *time.Timer
+	previous log.Level
+}
+
+// newDiagnostics returns the diagnostic commands the api may run, limited to the ones
+// allowed by the local configuration
+func (i *imup) newDiagnostics() *diagnostics.Dispatcher {
+	return diagnostics.NewDispatcher(i.cfg.DiagnosticCommands(),
+		diagnostics.NewCommand("run-ping-to-target", 3*time.Minute, i.pingTarget),
+		diagnostics.NewCommand("run-traceroute", 2*time.Minute, traceroute),
+		diagnostics.NewCommand("upload-recent-logs", 30*time.Second, recentLogs),
+		diagnostics.NewCommand("flush-cache", 10*time.Minute, flushCache),
+		diagnostics.NewCommand("report-config", 10*time.Second, i.reportConfig),
+		diagnostics.NewCommand("set-log-level-temporarily", 10*time.Second, i.setLogLevel),
+	)
+}
+
+// runDiagnosticCommand runs a command requested by the api and posts its result back
+func (i *imup) runDiagnosticCommand(ctx context.Context, data json.RawMessage) {
+	req := diagnostics.Request{}
+	if err := json.Unmarshal(data, &req); err != nil {
+		log.Error("cannot decode diagnostic command", "error", err)
+		return
+	}
+
+	result := i.Diagnostics.Dispatch(ctx, req)
+	if err := i.postCommandResult(ctx, result); err != nil {
+		log.Error("failed to post diagnostic command result", "error", err)
+		i.Errors.write("PostCommandResult", err)
+	} else {
+		i.Errors.resolve("PostCommandResult")
+	}
+}
+
+// pingTarget runs a single connectivity test against a target, like the ping command
+func (i *imup) pingTarget(ctx context.Context, args pingTargetArgs) (any, error) {
+	if !diagnostics.ValidTarget(args.Target) {
+		return nil, fmt.Errorf("invalid target: %q", args.Target)
+	}
+
+	return i.pingCollector(defaultPingCount).Collect(ctx, []string{args.Target}), nil
+}
+
+func traceroute(ctx context.Context, args tracerouteArgs) (any, error) {
+	hops := args.MaxHops
+	if hops <= 0 {
+		hops = defaultTraceHops
+	} else if hops > maxTraceHops {
+		hops = maxTraceHops
+	}
+
+	return diagnostics.Traceroute(ctx, args.Target, hops)
+}
+
+func recentLogs(_ context.Context, args recentLogsArgs) (any, error) {
+	path := config.LogFile()
+	if path == "" {
+		return nil, fmt.Errorf("logs are not written to a file, see LOG_FILE and LOG_TO_FILE")
+	}
+
+	lines := args.Lines
+	if lines <= 0 {
+		lines = defaultLogLines
+	} else if lines > maxLogLines {
+		lines = maxLogLines
+	}
+
+	return diagnostics.Tail(path, lines)
+}
+
+// flushCache sends the jobs in the offline cache now instead of on the next start
+func flushCache(ctx context.Context, _ struct{}) (any, error) {
+	entries, err := readCache()
+	if err != nil {
+		return nil, err
+	}
+
+	result := flushCacheResult{}
+	for _, e := range entries {
+		if err := postImupData(ctx, e.job, uploadRetries); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", e.ID, util.Redact(err.Error())))
+		} else if err := removeCachedJob(e.path); err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: sent but cannot be removed from the cache: %v", e.ID, err))
+		} else {
+			result.Sent++
+		}
+	}
+
+	return result, nil
+}
+
+func (i *imup) reportConfig(_ context.Context, _ struct{}) (any, error) {
+	return i.cfg.Redacted(), nil
+}
+
+// setLogLevel changes the log level for a while, a later change extends it and
+// the level from before the first change is restored
+func (i *imup) setLogLevel(_ context.Context, args logLevelArgs) (any, error) {
+	var level log.Level
+	if err := level.UnmarshalText([]byte(args.Level)); err != nil {
+		return nil, fmt.Errorf("invalid level: %v", err)
+	}
+
+	d := time.Duration(args.DurationSeconds) * time.Second
+	if d <= 0 {
+		d = defaultLogLevelTime
+	} else if d > maxLogLevelTime {
+		d = maxLogLevelTime
+	}
+
+	v := &i.verbosity
+	v.Lock()
+	defer v.Unlock()
+
+	if v.timer == nil {
+		v.previous = i.cfg.Verbosity()
+	} else {
+		v.timer.Stop()
+	}
+
+	i.cfg.SetVerbosity(level)
+
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		v.Lock()
+		defer v.Unlock()
+
+		// replaced by a later change
+		if v.timer != t {
+			return
+		}
+
+		v.timer = nil
+		i.cfg.SetVerbosity(v.previous)
+		log.Info("log level restored", "level", v.previous)
+	})
+	v.timer = t
+
+	return logLevelResult{Level: level.String(), Previous: v.previous.String(), Until: time.Now().Add(d)}, nil
+}
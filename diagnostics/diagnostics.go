@@ -0,0 +1,178 @@
+// Package diagnostics runs commands the imup api sends to a host to help diagnose it,
+// a host only runs the commands allowed by its local configuration.
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/imup-io/client/util"
+	log "golang.org/x/exp/slog"
+)
+
+// result status
+const (
+	StatusOK       = "ok"
+	StatusError    = "error"
+	StatusRejected = "rejected"
+	StatusTimeout  = "timeout"
+)
+
+// Request asks a host to run a command
+type Request struct {
+	ID      string          `json:"id"`
+	Command string          `json:"command"`
+	Args    json.RawMessage `json:"args,omitempty"`
+	// TimeoutSeconds shortens the timeout of the command, it cannot extend it
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+}
+
+// Result is the outcome of a request
+type Result struct {
+	ID        string    `json:"id"`
+	Command   string    `json:"command"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Data      any       `json:"data,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	Duration  string    `json:"duration"`
+}
+
+// Command is a diagnostic command a host can run
+type Command struct {
+	Name string
+	// Timeout bounds every run of the command
+	Timeout time.Duration
+
+	run func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+// NewCommand returns a command that decodes its arguments into A before it runs,
+// unknown arguments are rejected. Commands without arguments use struct{}.
+func NewCommand[A any](name string, timeout time.Duration, run func(ctx context.Context, args A) (any, error)) Command {
+	return Command{
+		Name:    name,
+		Timeout: timeout,
+		run: func(ctx context.Context, raw json.RawMessage) (any, error) {
+			var args A
+			if len(bytes.TrimSpace(raw)) > 0 && string(raw) != "null" {
+				dec := json.NewDecoder(bytes.NewReader(raw))
+				dec.DisallowUnknownFields()
+				if err := dec.Decode(&args); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %v", err)
+				}
+			}
+
+			return run(ctx, args)
+		},
+	}
+}
+
+// Dispatcher runs the commands a host allows
+type Dispatcher struct {
+	commands map[string]Command
+	allowed  map[string]bool
+}
+
+// NewDispatcher returns a dispatcher for commands, of which only the allowed ones are run
+func NewDispatcher(allowed []string, commands ...Command) *Dispatcher {
+	d := &Dispatcher{commands: map[string]Command{}, allowed: map[string]bool{}}
+	for _, c := range commands {
+		d.commands[c.Name] = c
+	}
+
+	for _, name := range allowed {
+		if _, ok := d.commands[name]; !ok {
+			log.Warn("unknown diagnostic command is allowed", "command", name, "commands", d.Commands())
+			continue
+		}
+
+		d.allowed[name] = true
+	}
+
+	return d
+}
+
+// Commands are the names of all commands in alphabetical order
+func (d *Dispatcher) Commands() []string {
+	names := []string{}
+	for name := range d.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Allowed are the names of the allowed commands in alphabetical order
+func (d *Dispatcher) Allowed() []string {
+	names := []string{}
+	for name := range d.allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Dispatch runs req if it is allowed and returns its result. A command that does not
+// return within its timeout is reported as timed out and left to finish on its own.
+func (d *Dispatcher) Dispatch(ctx context.Context, req Request) (result Result) {
+	started := time.Now()
+	result = Result{ID: req.ID, Command: req.Command, StartedAt: started}
+	defer func() {
+		result.Duration = time.Since(started).Round(time.Millisecond).String()
+		log.Info("diagnostic command", "id", result.ID, "command", result.Command, "status", result.Status, "error", result.Error)
+	}()
+
+	c, ok := d.commands[req.Command]
+	if !ok {
+		result.Status, result.Error = StatusRejected, "unknown command"
+		return result
+	}
+
+	if !d.allowed[req.Command] {
+		result.Status, result.Error = StatusRejected, "command is not allowed on this host"
+		return result
+	}
+
+	timeout := c.Timeout
+	if t := time.Duration(req.TimeoutSeconds) * time.Second; t > 0 && t < timeout {
+		timeout = t
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type outcome struct {
+		data any
+		err  error
+	}
+
+	done := make(chan outcome, 1)
+	go func() {
+		data, err := c.run(ctx, req.Args)
+		done <- outcome{data, err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			result.Status, result.Error = StatusError, util.Redact(o.err.Error())
+			if ctx.Err() == context.DeadlineExceeded {
+				result.Status = StatusTimeout
+			}
+			return result
+		}
+
+		result.Status, result.Data = StatusOK, o.data
+	case <-ctx.Done():
+		result.Status, result.Error = StatusTimeout, fmt.Sprintf("command did not complete within %s", timeout)
+	}
+
+	return result
+}
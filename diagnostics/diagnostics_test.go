@@ -0,0 +1,117 @@
+package diagnostics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type echoArgs struct {
+	Message string `json:"message"`
+}
+
+func testDispatcher(allowed ...string) *Dispatcher {
+	return NewDispatcher(allowed,
+		NewCommand("echo", time.Second, func(_ context.Context, args echoArgs) (any, error) {
+			return args.Message, nil
+		}),
+		NewCommand("fail", time.Second, func(context.Context, struct{}) (any, error) {
+			return nil, errors.New("failed")
+		}),
+		NewCommand("hang", time.Minute, func(ctx context.Context, _ struct{}) (any, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}),
+	)
+}
+
+func TestDispatch(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	d := testDispatcher("echo", "fail", "hang", "unknown")
+	is.Equal([]string{"echo", "fail", "hang"}, d.Commands())
+	is.Equal([]string{"echo", "fail", "hang"}, d.Allowed()) // unknown commands are ignored
+
+	r := d.Dispatch(ctx, Request{ID: "1", Command: "echo", Args: json.RawMessage(`{"message": "hello"}`)})
+	is.Equal(StatusOK, r.Status)
+	is.Equal("1", r.ID)
+	is.Equal("hello", r.Data)
+	is.True(r.Duration != "")
+
+	r = d.Dispatch(ctx, Request{ID: "2", Command: "echo", Args: json.RawMessage(`{"target": "1.1.1.1"}`)})
+	is.Equal(StatusError, r.Status)
+	is.True(strings.HasPrefix(r.Error, "invalid arguments"))
+
+	r = d.Dispatch(ctx, Request{ID: "3", Command: "fail"})
+	is.Equal(StatusError, r.Status)
+	is.Equal("failed", r.Error)
+
+	// a request can shorten the timeout of a command
+	r = d.Dispatch(ctx, Request{ID: "4", Command: "hang", TimeoutSeconds: 1})
+	is.Equal(StatusTimeout, r.Status)
+
+	r = d.Dispatch(ctx, Request{ID: "5", Command: "upload-everything"})
+	is.Equal(StatusRejected, r.Status)
+	is.Equal("unknown command", r.Error)
+}
+
+func TestDispatchAllowlist(t *testing.T) {
+	is := is.New(t)
+
+	d := testDispatcher("echo")
+	r := d.Dispatch(context.Background(), Request{ID: "1", Command: "fail"})
+	is.Equal(StatusRejected, r.Status)
+	is.Equal("command is not allowed on this host", r.Error)
+
+	// nothing is allowed by default
+	d = testDispatcher()
+	is.Equal(0, len(d.Allowed()))
+	is.Equal(StatusRejected, d.Dispatch(context.Background(), Request{Command: "echo"}).Status)
+}
+
+func TestValidTarget(t *testing.T) {
+	is := is.New(t)
+
+	for _, target := range []string{"1.1.1.1", "2606:4700:4700::1111", "api.imup.io", "localhost", "example.com."} {
+		is.True(ValidTarget(target)) // valid
+	}
+
+	for _, target := range []string{"", "-n", "--help", "api.imup.io; reboot", "a b", "http://api.imup.io"} {
+		is.True(!ValidTarget(target)) // invalid
+	}
+
+	_, err := Traceroute(context.Background(), "-m", 1)
+	is.True(err != nil)
+}
+
+func TestTail(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "imup.log")
+	lines := []string{}
+	for n := 0; n < 10; n++ {
+		lines = append(lines, fmt.Sprintf(`{"msg": "line %d"}`, n))
+	}
+	is.NoErr(os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+
+	logs, err := Tail(path, 3)
+	is.NoErr(err)
+	is.Equal(path, logs.File)
+	is.Equal(lines[7:], logs.Lines)
+
+	logs, err = Tail(path, 100)
+	is.NoErr(err)
+	is.Equal(lines, logs.Lines)
+
+	_, err = Tail(filepath.Join(t.TempDir(), "missing.log"), 3)
+	is.True(err != nil)
+}
@@ -0,0 +1,67 @@
+package diagnostics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/imup-io/client/util"
+)
+
+// maxTailBytes bounds how much of a file is read for its last lines
+const maxTailBytes = 1 << 20
+
+// Logs are the last lines of a log file
+type Logs struct {
+	File  string   `json:"file"`
+	Lines []string `json:"lines"`
+}
+
+// Tail returns up to n of the last lines of the file at path, redacted. Only the last
+// megabyte of the file is read.
+func Tail(path string, n int) (*Logs, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open log file: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat log file: %v", err)
+	}
+
+	offset := info.Size() - maxTailBytes
+	if offset < 0 {
+		offset = 0
+	}
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("cannot seek log file: %v", err)
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read log file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	// the first line is likely cut off when reading from an offset
+	if offset > 0 && len(lines) > 1 {
+		lines = lines[1:]
+	}
+
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	logs := &Logs{File: path, Lines: make([]string, 0, len(lines))}
+	for _, line := range lines {
+		if line != "" {
+			logs.Lines = append(logs.Lines, util.Redact(line))
+		}
+	}
+
+	return logs, nil
+}
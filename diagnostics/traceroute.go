@@ -0,0 +1,61 @@
+package diagnostics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// hostname matches a dns name, targets are passed to an external program and must not look like flags
+var hostname = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,62}\.)*[a-zA-Z0-9-]{1,63}\.?$`)
+
+// ValidTarget reports whether target is an ip address or host name
+func ValidTarget(target string) bool {
+	if net.ParseIP(target) != nil {
+		return true
+	}
+
+	return len(target) <= 253 && hostname.MatchString(target)
+}
+
+// Trace is the route to a target as reported by the system traceroute
+type Trace struct {
+	Target string   `json:"target"`
+	Hops   []string `json:"hops"`
+}
+
+// Traceroute runs the system traceroute, or tracert on windows, to target
+func Traceroute(ctx context.Context, target string, maxHops int) (*Trace, error) {
+	if !ValidTarget(target) {
+		return nil, fmt.Errorf("invalid target: %q", target)
+	}
+
+	name, args := "traceroute", []string{"-n", "-m", strconv.Itoa(maxHops), target}
+	if runtime.GOOS == "windows" {
+		name, args = "tracert", []string{"-d", "-h", strconv.Itoa(maxHops), target}
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not installed: %v", name, err)
+	}
+
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", name, err, strings.TrimSpace(string(out)))
+	}
+
+	t := &Trace{Target: target, Hops: []string{}}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			t.Hops = append(t.Hops, line)
+		}
+	}
+
+	return t, nil
+}
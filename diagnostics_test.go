@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/diagnostics"
+	"github.com/imup-io/client/reporting"
+	"github.com/matryer/is"
+	log "golang.org/x/exp/slog"
+)
+
+func TestDiagnosticCommands(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	testCache(t)
+
+	results := make(chan diagnostics.Result, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/commandResults" {
+			w.WriteHeader(http.StatusOK) // cached jobs
+			return
+		}
+
+		payload := struct {
+			Data diagnostics.Result `json:"data"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		results <- payload.Data
+	}))
+	defer s.Close()
+
+	os.Setenv("API_KEY", "diagnostics-api-key")
+	os.Setenv("HOST_ID", "diagnostics-host")
+	os.Setenv("IMUP_COMMAND_RESULTS_ADDRESS", s.URL+"/commandResults")
+	os.Setenv("DIAGNOSTIC_COMMANDS", "report-config,set-log-level-temporarily,flush-cache,upload-recent-logs")
+
+	imup := newApp()
+	imup.Errors = NewErrMap(reporting.NewNoop())
+	is.Equal([]string{"flush-cache", "report-config", "set-log-level-temporarily", "upload-recent-logs"}, imup.Diagnostics.Allowed())
+
+	ctx := context.Background()
+	run := func(req string) diagnostics.Result {
+		imup.runDiagnosticCommand(ctx, json.RawMessage(req))
+		return <-results
+	}
+
+	// the configuration is reported without secrets
+	r := run(`{"id": "1", "command": "report-config"}`)
+	is.Equal(diagnostics.StatusOK, r.Status)
+	b, err := json.Marshal(r.Data)
+	is.NoErr(err)
+	is.True(!strings.Contains(string(b), "diagnostics-api-key"))
+
+	// commands not in the local allowlist are rejected
+	r = run(`{"id": "2", "command": "run-traceroute", "args": {"target": "1.1.1.1"}}`)
+	is.Equal(diagnostics.StatusRejected, r.Status)
+
+	// logs are only available when they are written to a file
+	r = run(`{"id": "3", "command": "upload-recent-logs"}`)
+	is.Equal(diagnostics.StatusError, r.Status)
+
+	// cached jobs are sent
+	toUserCache(sendDataJob{IMUPAddress: s.URL + "/v1/data/connectivity", IMUPData: imupData{ID: "diagnostics-host"}})
+	r = run(`{"id": "4", "command": "flush-cache"}`)
+	is.Equal(diagnostics.StatusOK, r.Status)
+	is.Equal(map[string]any{"sent": float64(1), "failed": float64(0)}, r.Data)
+	entries, err := readCache()
+	is.NoErr(err)
+	is.Equal(0, len(entries))
+
+	// the log level is changed for a while and restored
+	r = run(`{"id": "5", "command": "set-log-level-temporarily", "args": {"level": "debug", "durationSeconds": 1}}`)
+	is.Equal(diagnostics.StatusOK, r.Status)
+	is.Equal(log.LevelDebug, imup.cfg.Verbosity())
+
+	r = run(`{"id": "6", "command": "set-log-level-temporarily", "args": {"level": "warn", "durationSeconds": 1}}`)
+	is.Equal(diagnostics.StatusOK, r.Status)
+	is.Equal("INFO", r.Data.(map[string]any)["previous"]) // the level before the first change
+
+	for deadline := time.Now().Add(5 * time.Second); imup.cfg.Verbosity() != log.LevelInfo; {
+		is.True(time.Now().Before(deadline)) // log level was not restored
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	r = run(`{"id": "7", "command": "set-log-level-temporarily", "args": {"level": "loud"}}`)
+	is.Equal(diagnostics.StatusError, r.Status)
+}
@@ -4,20 +4,47 @@ and running connection and speed testing at regular intervals.
 
 # Default Setup
 
-On startup any cached jobs will POST to the imup API.
+On startup any cached jobs will POST to the imup API and the state kept from a
+previous run, such as an outage in progress or the applied remote configuration,
+is restored.
 
 The main entrypoint sets up a shutdown signal used to coordinate graceful shutdown
 of the following go routines.
 
 Authorization
+Status Server (optional)
 Random Speed Testing
 Connectivity Testing
 Realtime
 
+	Push Channel
 	Liveness
 	On Demand Speed Tests
 	Remote Configuration Reloading
 
+While the push channel is connected the api pushes on demand speed tests and
+configuration changes and the client stops polling for them. The api can also ask
+the client to run the diagnostic commands allowed by its local configuration.
+
+The network the client is connected to is identified by its default gateway, local
+subnet and public ip. Allow and block lists and network profiles match on any of
+them to decide whether the client monitors the network and runs speed tests. When
+the host moves to another network the gateway is discovered again, connectivity
+testing restarts and statistics are tagged with the new network. Statistics and
+speed tests routed through a vpn or tunnel are tagged with it, and scheduled speed
+tests can be paused or repeated around the tunnel. With a local asn database the
+autonomous system, organization and country of the public ip and speed test server
+are recorded on speed test results. Monitoring can be limited to weekly windows such
+as business hours, and results during planned isp maintenance windows are tagged and
+not counted as downtime.
+
+# Commands
+
+Without a command, or with run, the client runs as described above. The once, ping,
+speedtest and status commands run a single test or query a running client, print
+the result and exit. The cache command lists, inspects, replays or purges jobs queued
+in the offline cache.
+
 # Advanced Configuration
 
 See the readme for a detailed list of configurable options.
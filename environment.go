@@ -7,6 +7,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	log "golang.org/x/exp/slog"
 )
@@ -14,15 +16,27 @@ import (
 // NOTE: ClientVersion is set via build flags
 var ClientVersion = "dev"
 
+// userCacheDir is the root of the offline cache, replaced in tests
+var userCacheDir = os.UserCacheDir
+
+// cacheDir returns the directory unsent jobs are written to
+func cacheDir() (string, error) {
+	cache, err := userCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cache, "imup"), nil
+}
+
 // write buffered data to the users cache directory
 // used to store unsent data in the case of an unexpected shutdown
 func toUserCache(data sendDataJob) {
-	cache, err := os.UserCacheDir()
+	targetDir, err := cacheDir()
 	if err != nil {
 		log.Error("$HOME is likely undefined", "error", err)
 	}
 
-	targetDir := filepath.Join(cache, "imup")
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		log.Error("cannot create directory in user cache", "error", err)
 	}
@@ -38,40 +52,122 @@ func toUserCache(data sendDataJob) {
 		log.Error("cannot hash data", "error", err)
 	}
 
-	if err := os.WriteFile(fmt.Sprintf("%s/%x.json", targetDir, h.Sum(nil)), b, 0666); err != nil {
+	// the same job is written to the same file, it is only counted once
+	name := fmt.Sprintf("%s/%x.json", targetDir, h.Sum(nil))
+	_, err = os.Stat(name)
+	existed := err == nil
+
+	if err := os.WriteFile(name, b, 0666); err != nil {
 		log.Error("cannot write data to disk", "error", err)
+	} else if !existed {
+		countCachedJobs(targetDir, 1)
 	}
 }
 
-func fromCacheDir() ([]sendDataJob, bool) {
-	data := []sendDataJob{}
-	cache, err := os.UserCacheDir()
+// removeCachedJob removes a job from the user cache directory
+func removeCachedJob(name string) error {
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+
+	if filepath.Ext(name) == ".json" {
+		countCachedJobs(filepath.Dir(name), -1)
+	}
+
+	return nil
+}
+
+// cachedJobCount is the number of jobs in the user cache directory. The directory is read
+// again when its modification time changes, e.g. after the cache command removed jobs, and
+// toUserCache and removeCachedJob keep the count where the time is too coarse to change
+var cachedJobCount struct {
+	sync.Mutex
+	dir     string
+	modTime time.Time
+	n       int
+}
+
+// countCachedJobs adds delta jobs written to or removed from dir and returns the number of jobs in it
+func countCachedJobs(dir string, delta int) int {
+	cachedJobCount.Lock()
+	defer cachedJobCount.Unlock()
+
+	info, err := os.Stat(dir)
 	if err != nil {
-		log.Error("$HOME is likely undefined", "error", err)
-		return data, false
+		cachedJobCount.dir, cachedJobCount.modTime, cachedJobCount.n = dir, time.Time{}, 0
+		return 0
 	}
 
-	targetDir := filepath.Join(cache, "imup")
+	// a change made before the directory was read is part of what is read
+	if cachedJobCount.dir != dir || !info.ModTime().Equal(cachedJobCount.modTime) {
+		cachedJobCount.dir, cachedJobCount.modTime, cachedJobCount.n = dir, info.ModTime(), readCachedJobs(dir)
+	} else {
+		cachedJobCount.n += delta
+	}
 
-	// check to see if path exists before reading
-	if _, err := os.Stat(targetDir); err != nil {
-		log.Debug("path may not exist", "error", err)
-		return data, false
+	if cachedJobCount.n < 0 {
+		cachedJobCount.n = 0
 	}
 
-	files, err := os.ReadDir(targetDir)
+	return cachedJobCount.n
+}
+
+// cachedJobs counts the jobs waiting in the user cache directory
+func cachedJobs() int {
+	targetDir, err := cacheDir()
 	if err != nil {
-		log.Error("cannot read from targetDir", "error", err)
-		return data, false
+		return 0
+	}
+
+	return countCachedJobs(targetDir, 0)
+}
+
+// readCachedJobs counts the jobs in dir by reading it
+func readCachedJobs(dir string) int {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
 	}
 
+	n := 0
 	for _, f := range files {
-		if job, ok := fromCache(fmt.Sprintf("%s/%s", targetDir, f.Name())); ok {
-			data = append(data, job)
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".json" {
+			n++
 		}
 	}
 
-	return data, len(data) > 0
+	return n
+}
+
+// sendCachedJobs queues the jobs left in the user cache by a previous run on the imup api sink,
+// a job is removed from the cache once queued, the rest stay for the next start or the cache command
+func (i *imup) sendCachedJobs() {
+	if i.api == nil {
+		return
+	}
+
+	entries, err := readCache()
+	if err != nil {
+		log.Error("cannot read cached jobs", "error", err)
+		return
+	}
+
+	for _, e := range entries {
+		r, ok := e.job.cachedRecord()
+		if !ok {
+			log.Warn("cached job cannot be queued", "id", e.ID, "type", e.Type)
+			continue
+		}
+
+		// a full queue would persist the job to the cache a second time
+		if i.api.Len() >= i.api.Cap() || !i.api.Enqueue(r) {
+			return
+		}
+
+		if err := removeCachedJob(e.path); err != nil {
+			log.Error("cannot remove queued job from the cache", "id", e.ID, "error", err)
+		}
+	}
 }
 
 // read imup data from users cache directory
@@ -94,13 +190,11 @@ func fromCache(name string) (sendDataJob, bool) {
 }
 
 func clearCache() {
-	cache, err := os.UserCacheDir()
+	targetDir, err := cacheDir()
 	if err != nil {
 		log.Error("$HOME is likely undefined", "error", err)
 		return
 	}
-
-	targetDir := filepath.Join(cache, "imup")
 	files, err := os.ReadDir(targetDir)
 	if err != nil {
 		log.Error("cannot read from targetDir", "error", err)
@@ -108,6 +202,6 @@ func clearCache() {
 	}
 
 	for _, f := range files {
-		os.Remove(fmt.Sprintf("%s/%s", targetDir, f.Name()))
+		removeCachedJob(fmt.Sprintf("%s/%s", targetDir, f.Name()))
 	}
 }
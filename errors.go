@@ -1,61 +1,177 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
+	"time"
 
-	"github.com/honeybadger-io/honeybadger-go"
+	"github.com/imup-io/client/config"
+	"github.com/imup-io/client/reporting"
+	"github.com/imup-io/client/util"
+	log "golang.org/x/exp/slog"
 )
 
-var setupHoneybadger sync.Once
+// NOTE: HoneybadgerAPIKey is set via build flags
 var HoneybadgerAPIKey string
 
-type ErrMap struct {
-	sync.RWMutex
-	internal map[string]error
+// errors of a class are reported a few times an hour at most, and the same transition of an
+// operation once a day, so a flapping connection does not flood the reporter once it recovers
+var errorReportLimits = reporting.LimitOptions{Burst: 5, Interval: time.Hour, DedupWindow: 24 * time.Hour, DedupKey: errorTransition}
+
+// errorTransition deduplicates reports by operation and transition, e.g. SendClientHealthy degraded,
+// as the message of a transition changes with every error count and duration
+func errorTransition(class, message string) string {
+	state, _, _ := strings.Cut(message, ":")
+	return class + " " + state
 }
 
-func NewErrMap(id string) *ErrMap {
-	setupHoneybadger.Do(func() {
-		hostContext := fmt.Sprintf("%s os: %s version: %s", id, runtime.GOOS, ClientVersion)
+// errorReportTimeout bounds the delivery of a single report
+const errorReportTimeout = time.Minute
 
-		honeybadger.Configure(honeybadger.Configuration{
-			APIKey:   HoneybadgerAPIKey,
-			Env:      ClientVersion,
-			Hostname: hostContext,
-		})
-	})
+// newErrorReporter returns the configured error reporter with host context, rate limiting and deduplication
+func newErrorReporter(cfg config.Reloadable) reporting.ErrorReporter {
+	host := reporting.Host{ID: cfg.HostID(), OS: runtime.GOOS, Arch: runtime.GOARCH, Version: ClientVersion}
+
+	var r reporting.ErrorReporter
+	switch cfg.ErrorReporter() {
+	case "honeybadger":
+		// development builds have no key to report with
+		if HoneybadgerAPIKey == "" {
+			return reporting.NewNoop()
+		}
+		r = reporting.NewHoneybadger(HoneybadgerAPIKey, host)
+	case "sentry":
+		var err error
+		if r, err = reporting.NewSentry(cfg.SentryDSN(), host); err != nil {
+			log.Error("cannot report errors to sentry, errors will not be reported", "error", err)
+			return reporting.NewNoop()
+		}
+	case "file":
+		r = reporting.NewFile(cfg.ErrorReportFile(), host)
+	default:
+		return reporting.NewNoop()
+	}
 
-	return &ErrMap{internal: make(map[string]error)}
+	return reporting.NewLimited(r, errorReportLimits)
 }
 
-func (m *ErrMap) read(key string) (bool, error) {
+// states of an operation tracked by ErrMap
+const (
+	errorStateFailing  = "failing"
+	errorStateDegraded = "degraded"
+)
+
+// errorDegradedAfter is how long an operation fails before it is reported as degraded
+const errorDegradedAfter = 15 * time.Minute
+
+// errorState is the failure history of a single operation
+type errorState struct {
+	State     string    `json:"state"`
+	Error     string    `json:"error"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Duration  string    `json:"duration"`
+}
+
+// duration is how long the operation has been failing
+func (e errorState) duration(now time.Time) time.Duration {
+	return now.Sub(e.FirstSeen).Round(time.Second)
+}
+
+// ErrMap tracks failing operations by key. An operation is failing from its first error,
+// degraded once it has failed for errorDegradedAfter and recovered when it next succeeds.
+// Degraded and recovered transitions are reported to the error reporter, a recovered
+// operation is forgotten once it is reported.
+type ErrMap struct {
+	sync.RWMutex
+	internal map[string]*errorState
+	reporter reporting.ErrorReporter
+	now      func() time.Time
+}
+
+func NewErrMap(r reporting.ErrorReporter) *ErrMap {
+	return &ErrMap{internal: make(map[string]*errorState), reporter: r, now: time.Now}
+}
+
+// read returns the state of key and whether the operation is currently failing
+func (m *ErrMap) read(key string) (errorState, bool) {
 	m.RLock()
 	defer m.RUnlock()
-	value, ok := m.internal[key]
 
-	return ok, value
+	e, ok := m.internal[key]
+	if !ok {
+		return errorState{}, false
+	}
+
+	return *e, true
 }
 
+// write records a failure of key
 func (m *ErrMap) write(key string, value error) {
 	m.Lock()
 	defer m.Unlock()
 
-	m.internal[key] = value
+	now := m.now()
+	message := util.Redact(value.Error())
+
+	e, ok := m.internal[key]
+	if !ok {
+		e = &errorState{State: errorStateFailing, FirstSeen: now}
+		m.internal[key] = e
+	}
+
+	e.Error = message
+	e.Count++
+	e.LastSeen = now
+
+	if e.State == errorStateFailing && now.Sub(e.FirstSeen) >= errorDegradedAfter {
+		e.State = errorStateDegraded
+		m.report(key, fmt.Sprintf("degraded: %d errors over %s, last error: %s", e.Count, e.duration(now), message))
+	}
 }
 
-func (m *ErrMap) delete(key string) {
+// resolve records a success of key, a failing operation is reported as recovered with its history and removed
+func (m *ErrMap) resolve(key string) {
 	m.Lock()
 	defer m.Unlock()
 
+	e, ok := m.internal[key]
+	if !ok {
+		return
+	}
+
 	delete(m.internal, key)
+	m.report(key, fmt.Sprintf("recovered: after %s and %d errors, last error: %s", e.duration(m.now()), e.Count, e.Error))
 }
 
-func (m *ErrMap) reportErrors(key string) {
-	if ok, value := m.read(key); ok {
-		honeybadger.Notify(value.Error(), honeybadger.ErrorClass{Name: key})
+// snapshot returns the state of every operation that is failing, keyed by operation
+func (m *ErrMap) snapshot() map[string]errorState {
+	m.RLock()
+	defer m.RUnlock()
 
-		m.delete(key)
+	now := m.now()
+	errs := make(map[string]errorState, len(m.internal))
+	for k, v := range m.internal {
+		e := *v
+		e.Duration = e.duration(now).String()
+		errs[k] = e
 	}
+
+	return errs
+}
+
+// report sends a transition of key to the error reporter without blocking the caller
+func (m *ErrMap) report(key, message string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), errorReportTimeout)
+		defer cancel()
+
+		if err := m.reporter.Report(ctx, key, message); err != nil {
+			log.Debug("cannot report error", "reporter", m.reporter.Name(), "class", key, "error", err)
+		}
+	}()
 }
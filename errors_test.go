@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+type reportedError struct {
+	class   string
+	message string
+}
+
+type channelReporter chan reportedError
+
+func (c channelReporter) Name() string { return "channel" }
+
+func (c channelReporter) Report(_ context.Context, class, message string) error {
+	c <- reportedError{class, message}
+	return nil
+}
+
+func TestErrMap(t *testing.T) {
+	is := is.New(t)
+
+	reported := make(channelReporter, 1)
+	m := NewErrMap(reported)
+
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	// nothing is reported for an operation that never failed
+	m.resolve("SendClientHealthy")
+
+	m.write("SendClientHealthy", errors.New("timeout"))
+	now = now.Add(time.Minute)
+	m.write("SendClientHealthy", errors.New("liveness failed"))
+
+	e, failing := m.read("SendClientHealthy")
+	is.True(failing)
+	is.Equal(errorStateFailing, e.State)
+	is.Equal(2, e.Count)
+	is.Equal("liveness failed", e.Error)
+	is.Equal(time.Minute, e.LastSeen.Sub(e.FirstSeen))
+
+	// errors that persist are reported once as degraded
+	now = now.Add(errorDegradedAfter)
+	m.write("SendClientHealthy", errors.New("liveness failed"))
+	is.Equal(reportedError{"SendClientHealthy", "degraded: 3 errors over 16m0s, last error: liveness failed"}, <-reported)
+
+	now = now.Add(time.Minute)
+	m.write("SendClientHealthy", errors.New("liveness failed"))
+	e, _ = m.read("SendClientHealthy")
+	is.Equal(errorStateDegraded, e.State)
+	is.Equal("17m0s", m.snapshot()["SendClientHealthy"].Duration)
+
+	// recovery is reported with the duration of the failure and the operation is forgotten
+	now = now.Add(time.Minute)
+	m.resolve("SendClientHealthy")
+	is.Equal(reportedError{"SendClientHealthy", "recovered: after 18m0s and 4 errors, last error: liveness failed"}, <-reported)
+
+	_, failing = m.read("SendClientHealthy")
+	is.True(!failing)
+	is.Equal(0, len(m.snapshot()))
+
+	m.resolve("SendClientHealthy") // already recovered, nothing is reported
+
+	m.write("SendClientHealthy", errors.New("timeout"))
+	e, failing = m.read("SendClientHealthy")
+	is.True(failing)
+	is.Equal(1, e.Count)
+	is.Equal(0, len(reported))
+}
+
+func TestErrorTransition(t *testing.T) {
+	is := is.New(t)
+
+	// the counts and durations of a transition do not make it a new report
+	is.Equal(
+		errorTransition("SendClientHealthy", "degraded: 3 errors over 16m0s, last error: timeout"),
+		errorTransition("SendClientHealthy", "degraded: 40 errors over 2h0m0s, last error: liveness failed"),
+	)
+	is.True(errorTransition("SendClientHealthy", "degraded: 3 errors over 16m0s, last error: timeout") !=
+		errorTransition("SendClientHealthy", "recovered: after 18m0s and 4 errors, last error: timeout"))
+	is.True(errorTransition("SendClientHealthy", "degraded: 3 errors") != errorTransition("RemoteConfigReload", "degraded: 3 errors"))
+}
+
+func TestNewErrorReporter(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "reporter-api-key")
+	os.Setenv("HOST_ID", "reporter-host")
+
+	// without a compiled in key honeybadger reports nothing
+	i := newApp()
+	is.Equal("none", newErrorReporter(i.cfg).Name())
+
+	os.Setenv("ERROR_REPORTER", "file")
+	i = newApp()
+	is.Equal("file", newErrorReporter(i.cfg).Name())
+
+	os.Setenv("ERROR_REPORTER", "sentry")
+	os.Setenv("SENTRY_DSN", "https://sentry-key@sentry.example.com/42")
+	i = newApp()
+	is.Equal("sentry", newErrorReporter(i.cfg).Name())
+}
@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"golang.org/x/exp/constraints"
-	log "golang.org/x/exp/slog"
 	"gonum.org/v1/gonum/stat/distuv"
 )
 
@@ -25,21 +24,6 @@ func speedTestInterval() time.Duration {
 	return time.Duration(t * float64(time.Minute))
 }
 
-func drain(c chan sendDataJob) {
-	toDrain := len(c)
-	log.Debug("unprocessed jobs at shutdown", "channel depth", toDrain)
-	i := 0
-	for job := range c {
-		i++
-		log.Debug("draining unsent data", "jobs", i, "left to drain", toDrain)
-		toUserCache(job)
-
-		if i >= toDrain {
-			break
-		}
-	}
-}
-
 func max[T constraints.Ordered](s []T) T {
 	if len(s) == 0 {
 		var zero T
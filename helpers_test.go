@@ -1,11 +1,30 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/matryer/is"
 )
 
+// TestMain keeps the audit trail every new client records its startup configuration in
+// out of the user cache directory, tests that clear the environment have no cache directory
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "imup-client")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Setenv("CONFIG_AUDIT_FILE", filepath.Join(dir, "audit", "config.log"))
+	code := m.Run()
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func Test_SpeedTestFrequency(t *testing.T) {
 	is := is.New(t)
 	hours := 24
@@ -12,6 +12,17 @@ import (
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/imup-io/client/config"
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/diagnostics"
+	"github.com/imup-io/client/metrics"
+	"github.com/imup-io/client/netid"
+	"github.com/imup-io/client/push"
+	"github.com/imup-io/client/sinks"
+	"github.com/imup-io/client/state"
+	"github.com/imup-io/client/telemetry"
+	"github.com/imup-io/client/util"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	log "golang.org/x/exp/slog"
 )
@@ -54,11 +65,25 @@ type pingStats struct {
 }
 
 type imup struct {
-	cfg                config.Reloadable
-	SpeedTestLock      sync.Mutex
-	ChannelImupData    chan sendDataJob
-	PingAddressesAvoid map[string]bool
-	Errors             *ErrMap
+	cfg           config.Reloadable
+	SpeedTestLock sync.Mutex
+	Errors        *ErrMap
+	State         *clientState
+	Sinks         *sinks.Dispatcher
+	Store         *state.Store
+	Push          *push.Client
+	Diagnostics   *diagnostics.Dispatcher
+
+	// api is the queue of the imup api sink, nil when the api sink is disabled
+	api *sinks.Queue
+
+	verbosity temporaryVerbosity
+
+	// rebaseline signals the connectivity collector that the host moved networks
+	rebaseline chan struct{}
+
+	// asns looks up who public and speed test server addresses belong to
+	asns asnDatabase
 }
 
 func newApp() *imup {
@@ -68,73 +93,178 @@ func newApp() *imup {
 		os.Exit(1)
 	}
 
+	return newImup(cfg)
+}
+
+// newImup returns a client for cfg
+func newImup(cfg config.Reloadable) *imup {
 	imup := &imup{
-		PingAddressesAvoid: map[string]bool{},
-		cfg:                cfg,
+		State:      newClientState(),
+		cfg:        cfg,
+		rebaseline: make(chan struct{}, 1),
 	}
 
-	// make a channel with a capacity of 300.
-	imup.ChannelImupData = make(chan sendDataJob, 300)
+	imup.Sinks = imup.newDispatcher()
+	imup.Diagnostics = imup.newDiagnostics()
 
-	// on startup get a clients public ip address
+	// on startup get a clients public ip address and network
 	imup.cfg.RefreshPublicIP()
+	imup.detectNetwork()
 
 	return imup
 }
 
-func sendImupData(ctx context.Context, job sendDataJob) {
-	b, err := json.Marshal(job.IMUPData)
+// newCollector returns a ping or dial collector, addressInternal is the gateway pinged
+// alongside external addresses, empty disables gateway testing
+func (i *imup) newCollector(addressInternal string) connectivity.StatCollector {
+	if i.cfg.PingTests() {
+		return connectivity.NewPingCollector(connectivity.Options{
+			AddressInternal: addressInternal,
+			ClientVersion:   ClientVersion,
+			Count:           i.cfg.PingRequestsCount(),
+			Debug:           i.cfg.Verbosity() == log.LevelDebug,
+			Delay:           time.Duration(i.cfg.PingDelayMilli()) * time.Millisecond,
+			Interval:        time.Duration(i.cfg.PingIntervalSeconds()) * time.Second,
+			Timeout:         time.Duration(i.cfg.PingIntervalSeconds()) * time.Second,
+			Avoid:           i.avoidAddresses(),
+		})
+	}
+
+	return connectivity.NewDialerCollector(connectivity.Options{
+		ClientVersion: ClientVersion,
+		Count:         i.cfg.ConnRequestsCount(),
+		Debug:         i.cfg.Verbosity() == log.LevelDebug,
+		Delay:         time.Duration(i.cfg.ConnDelayMilli()) * time.Millisecond,
+		Interval:      time.Duration(i.cfg.ConnIntervalSeconds()) * time.Second,
+		Timeout:       time.Duration(i.cfg.ConnIntervalSeconds()) * time.Second,
+		Avoid:         i.avoidAddresses(),
+	})
+}
+
+// connectivityJob returns a job that sends statistics with the downtime detected in them
+func (i *imup) connectivityJob(collector connectivity.StatCollector, data []connectivity.Statistics) sendDataJob {
+	sc, dt := collector.DetectDowntime(data)
+	return sendDataJob{
+		IMUPAddress: i.cfg.PostConnectionData(),
+		IMUPData: imupData{
+			Downtime:      dt,
+			StatusChanged: sc,
+			Email:         i.cfg.EmailAddress(),
+			ID:            i.cfg.HostID(),
+			Key:           i.cfg.APIKey(),
+			GroupID:       i.cfg.GroupID(),
+			IMUPData:      data,
+		},
+	}
+}
+
+// monitoring determines if the client is configured for speed and connectivity testing on the
+// network it is connected to and at this time, a network profile overrides the allow and block
+// lists but not the monitoring schedule
+func (i *imup) monitoring() bool {
+	if !i.cfg.Schedule().Active(time.Now()) {
+		return false
+	}
+
+	id := i.network()
+	if p := i.cfg.NetworkProfiles().Select(id); p != nil && p.Monitor != nil {
+		return *p.Monitor
+	}
+
+	return netid.Monitored(id, i.cfg.AllowRules(), i.cfg.BlockRules())
+}
+
+// maintenance reports whether the isp is under planned maintenance now or was at since,
+// so that a test that overlaps the start or end of a window is tagged
+func (i *imup) maintenance(since time.Time) bool {
+	s := i.cfg.Schedule()
+	return s.Maintenance(since) || s.Maintenance(time.Now())
+}
+
+// apiSendRetries is the number of retries of a single send, the api sink queue retries after that
+const apiSendRetries = 10
+
+func sendImupData(ctx context.Context, job sendDataJob) error {
+	err := postImupData(ctx, job, apiSendRetries)
 	if err != nil {
+		// a send interrupted by shutdown is persisted by the api sink queue
+		if ctx.Err() == nil {
+			metrics.ObserveSend(err)
+		}
+
 		log.Error("error", err)
-		return
+	} else {
+		metrics.ObserveSend(nil)
+	}
+
+	return err
+}
+
+// postImupData posts a job to the imup api and retries up to retryMax times
+func postImupData(ctx context.Context, job sendDataJob, retryMax int) error {
+	b, err := json.Marshal(job.IMUPData)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
 	}
 
+	// a send can be retried for weeks, every attempt is recorded as an event on a single span
+	ctx, span := telemetry.Start(ctx, "imup.send", attribute.String("url", util.Redact(job.IMUPAddress)))
+
 	req, err := retryablehttp.NewRequest("POST", job.IMUPAddress, bytes.NewBuffer(b))
 	if err != nil {
-		log.Error("error", err)
+		telemetry.End(span, err)
+		return fmt.Errorf("NewRequest: %v", err)
 	}
 	req = req.WithContext(ctx)
 	req.Header.Set("Content-Type", "application/json")
 
 	client := retryablehttp.NewClient()
 	client.Backoff = exactJitterBackoff
-	// 50000 should be 15-30 days with this config
-	client.RetryMax = 50000
+	client.RetryMax = retryMax
 	client.RetryWaitMin = time.Duration(30) * time.Second
 	client.RetryWaitMax = time.Duration(60) * time.Second
 	client.Logger = log.New(log.Default().Handler())
-
-	if _, err := client.Do(req); err != nil {
-		if err == context.Canceled {
-			// shutdown in progress
-			toUserCache(job)
+	client.RequestLogHook = func(_ retryablehttp.Logger, _ *http.Request, attempt int) {
+		if attempt > 0 {
+			metrics.ObserveSendRetry()
+			span.AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt)))
 		}
-
-		log.Error("error", err)
 	}
-}
+	client.ResponseLogHook = func(_ retryablehttp.Logger, resp *http.Response) {
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	}
 
-func sendDataWorker(ctx context.Context, c chan sendDataJob) {
-	for {
-		select {
-		case <-ctx.Done():
-			if len(c) > 0 {
-				log.Info("shutdown detected, persisting queued data")
-				drain(c)
-			}
-			return
-		case job := <-c:
-			sendImupData(ctx, job)
+	resp, err := client.Do(req)
+	if err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("addr: %s, unexpected status: %s", util.Redact(job.IMUPAddress), resp.Status)
 		}
 	}
+
+	telemetry.End(span, err)
+	return err
 }
 
-func (i *imup) reloadConfig(data []byte) {
-	if cfg, err := config.Reload(data); err != nil {
+func (i *imup) reloadConfig(ctx context.Context, data []byte) {
+	ctx, span := telemetry.Start(ctx, "config.Reload")
+
+	cfg, err := config.Reload(data)
+	metrics.ObserveConfigReload(err)
+	if err != nil {
 		log.Info("cannot reload config", "error", err)
 	} else {
 		i.cfg = cfg
+		span.SetAttributes(attribute.String("version", cfg.Version()))
+		i.persistConfig(data)
+
+		// let the api know which configuration version is in effect
+		if err := i.acknowledgeConfig(ctx); err != nil {
+			log.Error("failed to acknowledge applied config", "error", err)
+		}
 	}
+
+	telemetry.End(span, err)
 }
 
 func (i *imup) authorized(ctx context.Context, b *bytes.Buffer, addr string) error {
@@ -0,0 +1,203 @@
+package ipdb
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+)
+
+// columns of a csv database by the names providers give them
+var columns = map[string]string{
+	"network": "network", "cidr": "network", "prefix": "network",
+	"start_ip": "start", "range_start": "start", "ip_start": "start", "first_ip": "start", "start": "start",
+	"end_ip": "end", "range_end": "end", "ip_end": "end", "last_ip": "end", "end": "end",
+	"asn": "asn", "as_number": "asn", "autonomous_system_number": "asn", "as": "asn",
+	"organization": "org", "org": "org", "name": "org", "as_name": "org", "as_org": "org",
+	"as_description": "org", "autonomous_system_organization": "org", "isp": "org",
+	"country": "country", "country_code": "country", "country_iso_code": "country",
+}
+
+// headerless files have the layout of iptoasn.com
+var headerless = []string{"start", "end", "asn", "country", "org"}
+
+// ipRange is a range of addresses and who they belong to
+type ipRange struct {
+	from, to netip.Addr
+	info     Info
+}
+
+// table is a csv database loaded into memory, ranges are sorted and do not overlap
+type table struct {
+	ranges []ipRange
+}
+
+// openCSV reads a database with one network or range per row. The first row names the
+// columns, e.g. network,autonomous_system_number,autonomous_system_organization for the
+// GeoLite2 ASN csv or start_ip,end_ip,asn,name for ipinfo, a file that starts with an
+// address instead is read as range_start, range_end, as_number, country_code, as_description.
+func openCSV(path string, delimiter rune) (Database, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = delimiter
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.ReuseRecord = true
+
+	t := &table{}
+	var layout map[string]int
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if layout == nil {
+			var header bool
+			if layout, header = parseLayout(record); header {
+				continue
+			}
+		}
+
+		rng, ok, err := parseRow(record, layout)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		if ok {
+			t.ranges = append(t.ranges, rng)
+		}
+	}
+
+	if len(t.ranges) == 0 {
+		return nil, fmt.Errorf("no networks in %s", path)
+	}
+
+	sort.Slice(t.ranges, func(i, j int) bool { return t.ranges[i].from.Less(t.ranges[j].from) })
+	return t, nil
+}
+
+// parseLayout returns the column of each field and whether record is a header
+func parseLayout(record []string) (map[string]int, bool) {
+	layout := map[string]int{}
+	if len(record) > 0 {
+		first := strings.TrimSpace(record[0])
+		if _, err := netip.ParseAddr(first); err == nil {
+			for n, field := range headerless {
+				layout[field] = n
+			}
+			return layout, false
+		}
+
+		if _, err := netip.ParsePrefix(first); err == nil {
+			layout["network"] = 0
+			for n, field := range headerless[2:] {
+				layout[field] = n + 1
+			}
+			return layout, false
+		}
+	}
+
+	for n, name := range record {
+		if field, ok := columns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := layout[field]; !seen {
+				layout[field] = n
+			}
+		}
+	}
+
+	return layout, true
+}
+
+// parseRow returns the range of a row, rows without an autonomous system, organization
+// or country are skipped
+func parseRow(record []string, layout map[string]int) (ipRange, bool, error) {
+	get := func(field string) string {
+		if n, ok := layout[field]; ok && n < len(record) {
+			return strings.TrimSpace(record[n])
+		}
+		return ""
+	}
+
+	rng := ipRange{info: Info{
+		ASN:          normalizeASN(get("asn")),
+		Organization: get("org"),
+		Country:      get("country"),
+	}}
+
+	// iptoasn.com lists unrouted ranges with an empty autonomous system
+	if rng.info.ASN == "" && strings.EqualFold(rng.info.Organization, "not routed") {
+		return rng, false, nil
+	}
+
+	if strings.EqualFold(rng.info.Country, "none") {
+		rng.info.Country = ""
+	}
+
+	if network := get("network"); network != "" {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return rng, false, err
+		}
+
+		prefix = prefix.Masked()
+		rng.from, rng.to = prefix.Addr().Unmap(), lastAddr(prefix).Unmap()
+	} else {
+		var err error
+		if rng.from, err = netip.ParseAddr(get("start")); err != nil {
+			return rng, false, err
+		}
+		if rng.to, err = netip.ParseAddr(get("end")); err != nil {
+			return rng, false, err
+		}
+		rng.from, rng.to = rng.from.Unmap(), rng.to.Unmap()
+	}
+
+	if rng.to.Less(rng.from) {
+		return rng, false, fmt.Errorf("range %s-%s ends before it starts", rng.from, rng.to)
+	}
+
+	return rng, !rng.info.Empty(), nil
+}
+
+// lastAddr returns the highest address in a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// Lookup returns who addr belongs to
+func (t *table) Lookup(addr netip.Addr) (Info, bool) {
+	addr = addr.Unmap()
+
+	// the last range that starts at or before addr
+	n := sort.Search(len(t.ranges), func(i int) bool { return addr.Less(t.ranges[i].from) }) - 1
+	if n < 0 || t.ranges[n].to.Less(addr) {
+		return Info{}, false
+	}
+
+	return t.ranges[n].info, true
+}
+
+// Close releases nothing, the table is in memory
+func (t *table) Close() error {
+	return nil
+}
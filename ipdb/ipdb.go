@@ -0,0 +1,175 @@
+// Package ipdb looks up the autonomous system, organization and country an ip address
+// belongs to in a local MaxMind DB or CSV database, so that results can be grouped by
+// isp with the addresses the client actually used rather than those known to the api
+// later on.
+package ipdb
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// Info is who an address belongs to, fields the database does not have are empty
+type Info struct {
+	ASN          string `json:"asn,omitempty"`
+	Organization string `json:"organization,omitempty"`
+	Country      string `json:"country,omitempty"`
+}
+
+// Empty reports whether nothing is known about an address
+func (i Info) Empty() bool {
+	return i.ASN == "" && i.Organization == "" && i.Country == ""
+}
+
+// Database looks up addresses
+type Database interface {
+	Lookup(addr netip.Addr) (Info, bool)
+	Close() error
+}
+
+// Open opens a MaxMind DB file (.mmdb) or a CSV (.csv) or tab separated (.tsv, .txt) file
+func Open(path string) (Database, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mmdb":
+		return openMMDB(path)
+	case ".tsv", ".txt":
+		return openCSV(path, '\t')
+	default:
+		return openCSV(path, ',')
+	}
+}
+
+// checkInterval is how often a File checks whether its database changed
+var checkInterval = time.Minute
+
+// File is a database file that is opened again when it is replaced or modified
+type File struct {
+	path string
+
+	mu        sync.Mutex
+	db        Database
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// NewFile returns a database that opens path on the first lookup
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// Path is the path of the database file
+func (f *File) Path() string {
+	return f.path
+}
+
+// Lookup returns who an address belongs to, address may include a port as in 192.0.2.1:443
+// or [2001:db8::1]:443
+func (f *File) Lookup(address string) (Info, bool) {
+	addr, ok := ParseAddr(address)
+	if !ok {
+		return Info{}, false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.refresh()
+	if f.db == nil {
+		return Info{}, false
+	}
+
+	return f.db.Lookup(addr)
+}
+
+// refresh opens the file when it changed, a file that cannot be opened keeps the
+// previous database
+func (f *File) refresh() {
+	if f.db != nil && time.Since(f.checkedAt) < checkInterval {
+		return
+	}
+	f.checkedAt = time.Now()
+
+	fi, err := os.Stat(f.path)
+	if err != nil {
+		if f.db == nil {
+			log.Warn("cannot open ip database", "path", f.path, "error", err)
+		}
+		return
+	}
+
+	if f.db != nil && fi.ModTime().Equal(f.modTime) && fi.Size() == f.size {
+		return
+	}
+
+	db, err := Open(f.path)
+	if err != nil {
+		log.Warn("cannot open ip database", "path", f.path, "error", err)
+		return
+	}
+
+	if f.db != nil {
+		f.db.Close()
+		log.Info("ip database updated", "path", f.path)
+	}
+	f.db, f.modTime, f.size = db, fi.ModTime(), fi.Size()
+}
+
+// Close closes the database
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.db == nil {
+		return nil
+	}
+
+	err := f.db.Close()
+	f.db = nil
+	return err
+}
+
+// ParseAddr parses an address with or without a port
+func ParseAddr(s string) (netip.Addr, bool) {
+	s = strings.TrimSpace(s)
+	if ap, err := netip.ParseAddrPort(s); err == nil {
+		return ap.Addr().Unmap(), true
+	}
+
+	addr, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+// normalizeASN formats 7922, as7922 and AS7922 as AS7922, 0 is not an autonomous system
+func normalizeASN(asn string) string {
+	asn = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(asn)), "AS")
+	if n, err := strconv.ParseUint(asn, 10, 32); err != nil || n == 0 {
+		return ""
+	}
+
+	return "AS" + asn
+}
+
+// asnString formats a decoded autonomous system number
+func asnString(v any) string {
+	switch n := v.(type) {
+	case string:
+		return normalizeASN(n)
+	case uint64, uint32, uint16, int, int32, int64:
+		return normalizeASN(fmt.Sprint(n))
+	}
+
+	return ""
+}
@@ -0,0 +1,215 @@
+package ipdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestCSV(t *testing.T) {
+	is := is.New(t)
+	dir := t.TempDir()
+
+	for name, data := range map[string]string{
+		// geolite2 asn blocks
+		"geolite.csv": "network,autonomous_system_number,autonomous_system_organization\n" +
+			"203.0.113.0/24,7922,Comcast Cable\n" +
+			"2001:db8::/32,7922,Comcast Cable\n",
+		// ipinfo asn ranges
+		"ipinfo.csv": "start_ip,end_ip,asn,name,domain\n" +
+			"203.0.113.0,203.0.113.255,AS7922,Comcast Cable,comcast.net\n" +
+			"2001:db8::,2001:db8:ffff:ffff:ffff:ffff:ffff:ffff,AS7922,Comcast Cable,comcast.net\n",
+		// iptoasn.com without a header
+		"iptoasn.tsv": "198.51.100.0\t198.51.100.255\t0\tNone\tNot routed\n" +
+			"203.0.113.0\t203.0.113.255\t7922\tUS\tComcast Cable\n" +
+			"2001:db8::\t2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\t7922\tUS\tComcast Cable\n",
+	} {
+		path := filepath.Join(dir, name)
+		is.NoErr(os.WriteFile(path, []byte(data), 0o600))
+
+		db, err := Open(path)
+		is.NoErr(err)
+
+		for _, ip := range []string{"203.0.113.0", "203.0.113.77", "203.0.113.255", "::ffff:203.0.113.9", "2001:db8::1"} {
+			info, ok := db.Lookup(netip.MustParseAddr(ip))
+			is.True(ok) // address is in the database
+			is.Equal("AS7922", info.ASN)
+			is.Equal("Comcast Cable", info.Organization)
+		}
+
+		for _, ip := range []string{"203.0.112.255", "203.0.114.0", "198.51.100.7", "2001:db9::1"} {
+			_, ok := db.Lookup(netip.MustParseAddr(ip))
+			is.True(!ok) // address is not in the database
+		}
+		is.NoErr(db.Close())
+	}
+
+	path := filepath.Join(dir, "empty.csv")
+	is.NoErr(os.WriteFile(path, []byte("network,asn\n"), 0o600))
+	_, err := Open(path)
+	is.True(err != nil) // no networks
+
+	is.NoErr(os.WriteFile(path, []byte("network,asn\nnope,7922\n"), 0o600))
+	_, err = Open(path)
+	is.True(err != nil) // invalid network
+}
+
+func TestMMDB(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "asn.mmdb")
+	is.NoErr(os.WriteFile(path, testMMDB(map[string]any{
+		"autonomous_system_number":       uint32(7922),
+		"autonomous_system_organization": "Comcast Cable",
+		"country":                        map[string]any{"iso_code": "US"},
+	}), 0o600))
+
+	db, err := Open(path)
+	is.NoErr(err)
+	defer db.Close()
+
+	info, ok := db.Lookup(netip.MustParseAddr("203.0.113.7"))
+	is.True(ok)
+	is.Equal(Info{ASN: "AS7922", Organization: "Comcast Cable", Country: "US"}, info)
+
+	_, ok = db.Lookup(netip.MustParseAddr("198.51.100.7"))
+	is.True(!ok)
+
+	_, ok = db.Lookup(netip.MustParseAddr("2001:db8::1"))
+	is.True(!ok) // not an ipv6 database
+}
+
+func TestRecordInfo(t *testing.T) {
+	is := is.New(t)
+
+	// ipinfo
+	is.Equal(Info{ASN: "AS7922", Organization: "Comcast Cable", Country: "US"},
+		recordInfo(map[string]any{"asn": "AS7922", "as_name": "Comcast Cable", "country": "US"}))
+
+	// geoip2 isp
+	is.Equal(Info{ASN: "AS7922", Organization: "Comcast", Country: ""},
+		recordInfo(map[string]any{"autonomous_system_number": uint64(7922), "isp": "Comcast"}))
+
+	is.True(recordInfo(map[string]any{"asn": "0"}).Empty())
+}
+
+func TestFile(t *testing.T) {
+	is := is.New(t)
+
+	interval := checkInterval
+	checkInterval = 0
+	defer func() { checkInterval = interval }()
+
+	path := filepath.Join(t.TempDir(), "asn.csv")
+	f := NewFile(path)
+	defer f.Close()
+
+	_, ok := f.Lookup("203.0.113.7")
+	is.True(!ok) // no file yet
+
+	is.NoErr(os.WriteFile(path, []byte("network,asn,org\n203.0.113.0/24,7922,Comcast Cable\n"), 0o600))
+	info, ok := f.Lookup("203.0.113.7:443")
+	is.True(ok)
+	is.Equal("AS7922", info.ASN)
+
+	// an updated database is opened again
+	is.NoErr(os.WriteFile(path, []byte("network,asn,org\n203.0.113.0/24,701,Verizon Business\n"), 0o600))
+	is.NoErr(os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	info, ok = f.Lookup("[::ffff:203.0.113.7]:443")
+	is.True(ok)
+	is.Equal("AS701", info.ASN)
+
+	// a broken update keeps the previous database
+	is.NoErr(os.WriteFile(path, []byte("network,asn\nnope,1\n"), 0o600))
+	is.NoErr(os.Chtimes(path, time.Now().Add(2*time.Minute), time.Now().Add(2*time.Minute)))
+	info, ok = f.Lookup("203.0.113.7")
+	is.True(ok)
+	is.Equal("AS701", info.ASN)
+
+	_, ok = f.Lookup("not an address")
+	is.True(!ok)
+}
+
+// testMMDB returns an ipv4 MaxMind DB in which 203.0.113.0/24 has record
+func testMMDB(record map[string]any) []byte {
+	const prefix, bits = 0xcb007100, 24
+	nodeCount := uint32(bits)
+
+	// one node per bit of the prefix, the other branch of each node is empty
+	tree := []byte{}
+	for n := uint32(0); n < bits; n++ {
+		next := n + 1
+		if n == bits-1 {
+			next = nodeCount + 16 // the record at offset 0 of the data section
+		}
+
+		left, right := nodeCount, next
+		if prefix&(1<<(31-n)) == 0 {
+			left, right = next, nodeCount
+		}
+		tree = append(tree, byte(left>>16), byte(left>>8), byte(left), byte(right>>16), byte(right>>8), byte(right))
+	}
+
+	b := bytes.Buffer{}
+	b.Write(tree)
+	b.Write(make([]byte, 16))
+	b.Write(encodeMMDB(record))
+	b.WriteString("\xAB\xCD\xEFMaxMind.com")
+	b.Write(encodeMMDB(map[string]any{
+		"node_count":                  nodeCount,
+		"record_size":                 uint16(24),
+		"ip_version":                  uint16(4),
+		"database_type":               "Test-ASN",
+		"binary_format_major_version": uint16(2),
+	}))
+
+	return b.Bytes()
+}
+
+// encodeMMDB encodes the maps, strings and unsigned integers of the MaxMind DB data
+// section, sizes are limited to 284 bytes
+func encodeMMDB(v any) []byte {
+	control := func(kind, size int) []byte {
+		if size < 29 {
+			return []byte{byte(kind<<5 | size)}
+		}
+		return []byte{byte(kind<<5 | 29), byte(size - 29)}
+	}
+	uint := func(kind int, n uint64, size int) []byte {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint64(b, n)
+		b = bytes.TrimLeft(b[8-size:], "\x00")
+		return append(control(kind, len(b)), b...)
+	}
+
+	switch v := v.(type) {
+	case string:
+		return append(control(2, len(v)), v...)
+	case uint16:
+		return uint(5, uint64(v), 2)
+	case uint32:
+		return uint(6, uint64(v), 4)
+	case map[string]any:
+		keys := []string{}
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b := control(7, len(v))
+		for _, k := range keys {
+			b = append(b, encodeMMDB(k)...)
+			b = append(b, encodeMMDB(v[k])...)
+		}
+		return b
+	}
+
+	panic("unsupported type")
+}
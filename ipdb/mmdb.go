@@ -0,0 +1,72 @@
+package ipdb
+
+import (
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdb is a MaxMind DB, the GeoLite2 and GeoIP2 ASN, ISP and country databases as well
+// as databases in the same format from other providers are understood
+type mmdb struct {
+	reader *maxminddb.Reader
+}
+
+func openMMDB(path string) (Database, error) {
+	r, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mmdb{reader: r}, nil
+}
+
+// Lookup returns who addr belongs to
+func (m *mmdb) Lookup(addr netip.Addr) (Info, bool) {
+	record := map[string]any{}
+	if err := m.reader.Lookup(addr.AsSlice(), &record); err != nil || len(record) == 0 {
+		return Info{}, false
+	}
+
+	info := recordInfo(record)
+	return info, !info.Empty()
+}
+
+// Close unmaps the database
+func (m *mmdb) Close() error {
+	return m.reader.Close()
+}
+
+// recordInfo reads the fields of the databases that are in common use
+//
+//	maxmind:       autonomous_system_number, autonomous_system_organization, isp, country.iso_code
+//	ipinfo:        asn, as_name or name, country
+//	others:        as_number, as_org or organization, country_code
+func recordInfo(record map[string]any) Info {
+	info := Info{}
+	for _, key := range []string{"autonomous_system_number", "asn", "as_number"} {
+		if info.ASN = asnString(record[key]); info.ASN != "" {
+			break
+		}
+	}
+
+	for _, key := range []string{"autonomous_system_organization", "isp", "organization", "as_name", "as_org", "name"} {
+		if s, ok := record[key].(string); ok && s != "" {
+			info.Organization = s
+			break
+		}
+	}
+
+	switch c := record["country"].(type) {
+	case string:
+		info.Country = c
+	case map[string]any:
+		info.Country, _ = c["iso_code"].(string)
+	}
+
+	if info.Country == "" {
+		info.Country, _ = record["country_code"].(string)
+	}
+
+	return info
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/imup-io/client/ipdb"
+	"github.com/imup-io/client/speedtesting"
+)
+
+// asnDatabase is the database of autonomous systems at the configured path, it is
+// opened again when the path is reloaded
+type asnDatabase struct {
+	mu   sync.Mutex
+	file *ipdb.File
+}
+
+// lookup returns who address belongs to in the database at path, nothing is known
+// when no database is configured
+func (d *asnDatabase) lookup(path, address string) (ipdb.Info, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file != nil && d.file.Path() != path {
+		d.file.Close()
+		d.file = nil
+	}
+
+	if path == "" {
+		return ipdb.Info{}, false
+	}
+
+	if d.file == nil {
+		d.file = ipdb.NewFile(path)
+	}
+
+	return d.file.Lookup(address)
+}
+
+// owner returns who a public address belongs to
+func (i *imup) owner(address string) ipdb.Info {
+	info, _ := i.asns.lookup(i.cfg.ASNDatabase(), address)
+	return info
+}
+
+// ownerASN returns the autonomous system of the first public address that is known
+func (i *imup) ownerASN(publicIPs []string) string {
+	for _, ip := range publicIPs {
+		if asn := i.owner(ip).ASN; asn != "" {
+			return asn
+		}
+	}
+
+	return ""
+}
+
+// enrichSpeedTest records who the client and server addresses of a speed test belong
+// to, e.g. Metadata["Client ASN"] and Metadata["Server Organization"]
+func (i *imup) enrichSpeedTest(result *speedtesting.SpeedTestResult) {
+	if result == nil || result.Metadata == nil {
+		return
+	}
+
+	for _, end := range []string{"Client", "Server"} {
+		info, ok := i.asns.lookup(i.cfg.ASNDatabase(), result.Metadata[end+" IP"])
+		if !ok {
+			continue
+		}
+
+		for key, value := range map[string]string{"ASN": info.ASN, "Organization": info.Organization, "Country": info.Country} {
+			if value != "" {
+				result.Metadata[end+" "+key] = value
+			}
+		}
+	}
+}
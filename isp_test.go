@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/imup-io/client/speedtesting"
+	"github.com/matryer/is"
+)
+
+func TestEnrichSpeedTest(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "isp-api-key")
+	os.Setenv("HOST_ID", "isp-host")
+
+	path := filepath.Join(t.TempDir(), "asn.csv")
+	is.NoErr(os.WriteFile(path, []byte("network,asn,org,country\n"+
+		"203.0.113.0/24,7922,Comcast Cable,US\n"+
+		"2001:db8::/32,13335,Cloudflare,\n"), 0o600))
+
+	result := func() *speedtesting.SpeedTestResult {
+		return &speedtesting.SpeedTestResult{Metadata: map[string]string{
+			"Client IP": "203.0.113.7:52012",
+			"Server IP": "[2001:db8::1]:443",
+		}}
+	}
+
+	// nothing is looked up without a database
+	imup := newApp()
+	r := result()
+	imup.enrichSpeedTest(r)
+	is.Equal(2, len(r.Metadata))
+
+	os.Setenv("ASN_DATABASE", path)
+	imup = newApp()
+	r = result()
+	imup.enrichSpeedTest(r)
+	is.Equal("AS7922", r.Metadata["Client ASN"])
+	is.Equal("Comcast Cable", r.Metadata["Client Organization"])
+	is.Equal("US", r.Metadata["Client Country"])
+	is.Equal("AS13335", r.Metadata["Server ASN"])
+	is.Equal("Cloudflare", r.Metadata["Server Organization"])
+	_, ok := r.Metadata["Server Country"]
+	is.True(!ok) // unknown fields are left out
+
+	// the public ip identifies the autonomous system of the network
+	is.Equal("AS7922", imup.ownerASN([]string{"198.51.100.1", "203.0.113.7"}))
+	is.Equal("AS7922", imup.detectOptions().ASN("203.0.113.7"))
+
+	imup.enrichSpeedTest(nil)
+}
@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// field is a flattened attribute, groups are joined to the key with a dot
+type field struct {
+	key   string
+	value string
+}
+
+// transport delivers a single formatted record
+type transport interface {
+	io.Closer
+	write(level log.Level, t time.Time, msg string, fields []field) error
+}
+
+// Handler is a log.Handler that flattens attributes into key value fields
+// and writes each record to a syslog server or journald
+type Handler struct {
+	level  log.Leveler
+	t      transport
+	fields []field
+	prefix string
+}
+
+func newHandler(t transport, opts *log.HandlerOptions) *Handler {
+	h := &Handler{t: t, level: log.LevelInfo}
+	if opts != nil && opts.Level != nil {
+		h.level = opts.Level
+	}
+
+	return h
+}
+
+// Enabled reports whether level is at or above the configured level
+func (h *Handler) Enabled(_ context.Context, level log.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle writes r with every attribute added to the handler and the record
+func (h *Handler) Handle(_ context.Context, r log.Record) error {
+	fields := make([]field, len(h.fields), len(h.fields)+r.NumAttrs())
+	copy(fields, h.fields)
+
+	r.Attrs(func(a log.Attr) bool {
+		fields = appendAttr(fields, h.prefix, a)
+		return true
+	})
+
+	return h.t.write(r.Level, r.Time, r.Message, fields)
+}
+
+// WithAttrs returns a handler that includes attrs in every record
+func (h *Handler) WithAttrs(attrs []log.Attr) log.Handler {
+	fields := make([]field, len(h.fields), len(h.fields)+len(attrs))
+	copy(fields, h.fields)
+
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.prefix, a)
+	}
+
+	return &Handler{t: h.t, level: h.level, fields: fields, prefix: h.prefix}
+}
+
+// WithGroup returns a handler that prefixes the keys of subsequent attributes with name
+func (h *Handler) WithGroup(name string) log.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &Handler{t: h.t, level: h.level, fields: h.fields, prefix: h.prefix + name + "."}
+}
+
+// Close closes the connection shared by the handler and every handler derived from it
+func (h *Handler) Close() error {
+	return h.t.Close()
+}
+
+func appendAttr(fields []field, prefix string, a log.Attr) []field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(log.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == log.KindGroup {
+		// attributes of a group without a key are inlined
+		if a.Key != "" {
+			prefix = prefix + a.Key + "."
+		}
+
+		for _, ga := range a.Value.Group() {
+			fields = appendAttr(fields, prefix, ga)
+		}
+
+		return fields
+	}
+
+	return append(fields, field{key: prefix + a.Key, value: value(a.Value)})
+}
+
+func value(v log.Value) string {
+	switch v.Kind() {
+	case log.KindString:
+		return v.String()
+	case log.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case log.KindDuration:
+		return v.Duration().String()
+	case log.KindFloat64:
+		return strconv.FormatFloat(v.Float64(), 'g', -1, 64)
+	case log.KindAny:
+		switch a := v.Any().(type) {
+		case error:
+			return a.Error()
+		case json.RawMessage:
+			return string(a)
+		case []byte:
+			return string(a)
+		}
+
+		if b, err := json.Marshal(v.Any()); err == nil {
+			return string(b)
+		}
+	}
+
+	return v.String()
+}
+
+// severity maps a log level to a syslog severity, journald uses the same values
+func severity(level log.Level) int {
+	switch {
+	case level >= log.LevelError:
+		return 3
+	case level >= log.LevelWarn:
+		return 4
+	case level >= log.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// journalSocket is where journald accepts entries in its native protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+// NewJournald returns a handler that writes records to journald with each attribute as a journal field
+func NewJournald(opts *log.HandlerOptions) (*Handler, error) {
+	return newJournald(journalSocket, opts)
+}
+
+func newJournald(path string, opts *log.HandlerOptions) (*Handler, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to journald: %v", err)
+	}
+
+	return newHandler(&journaldTransport{conn: conn}, opts), nil
+}
+
+// journaldTransport sends an entry per datagram, see https://systemd.io/JOURNAL_NATIVE_PROTOCOL/
+type journaldTransport struct {
+	conn *net.UnixConn
+}
+
+func (j *journaldTransport) write(level log.Level, t time.Time, msg string, fields []field) error {
+	b := &bytes.Buffer{}
+	writeJournalField(b, "MESSAGE", msg)
+	writeJournalField(b, "PRIORITY", strconv.Itoa(severity(level)))
+	writeJournalField(b, "SYSLOG_IDENTIFIER", appName)
+	if !t.IsZero() {
+		writeJournalField(b, "IMUP_TIMESTAMP", t.Format(time.RFC3339Nano))
+	}
+
+	for _, f := range fields {
+		writeJournalField(b, journalName(f.key), f.value)
+	}
+
+	// a datagram is sent atomically, there is no need to lock
+	_, err := j.conn.Write(b.Bytes())
+	return err
+}
+
+func (j *journaldTransport) Close() error {
+	return j.conn.Close()
+}
+
+// writeJournalField writes a field, values spanning lines are length prefixed
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalName returns key as a valid journal field name, upper case letters, digits and
+// underscores that does not begin with an underscore or digit, attributes never override
+// the fields set by the client
+func journalName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, key)
+
+	switch name {
+	case "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER", "IMUP_TIMESTAMP":
+		name = "IMUP_" + name
+	}
+
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "IMUP_" + name
+	}
+
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return name
+}
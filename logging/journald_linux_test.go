@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	log "golang.org/x/exp/slog"
+)
+
+func TestJournald(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "socket")
+	ln, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	is.NoErr(err)
+	defer ln.Close()
+
+	h, err := newJournald(path, nil)
+	is.NoErr(err)
+	defer h.Close()
+
+	log.New(h).Error("cannot send", "error", "line 1\nline 2", "message", "attribute", "_private", "x")
+
+	buf := make([]byte, 4096)
+	n, err := ln.Read(buf)
+	is.NoErr(err)
+
+	fields := parseJournal(t, buf[:n])
+	is.Equal("cannot send", fields["MESSAGE"])
+	is.Equal("3", fields["PRIORITY"])
+	is.Equal("imup", fields["SYSLOG_IDENTIFIER"])
+	is.Equal("line 1\nline 2", fields["ERROR"])
+	is.Equal("attribute", fields["IMUP_MESSAGE"]) // attributes never override client fields
+	is.Equal("x", fields["IMUP__PRIVATE"])        // fields beginning with an underscore are trusted
+}
+
+func parseJournal(t *testing.T, b []byte) map[string]string {
+	t.Helper()
+
+	fields := map[string]string{}
+	for len(b) > 0 {
+		line := b[:bytes.IndexByte(b, '\n')]
+		b = b[len(line)+1:]
+
+		if i := bytes.IndexByte(line, '='); i >= 0 {
+			fields[string(line[:i])] = string(line[i+1:])
+			continue
+		}
+
+		size := binary.LittleEndian.Uint64(b)
+		fields[string(line)] = string(b[8 : 8+size])
+		b = b[8+size+1:]
+	}
+
+	return fields
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package logging
+
+import (
+	"fmt"
+
+	log "golang.org/x/exp/slog"
+)
+
+// NewJournald fails, journald is only available on linux
+func NewJournald(opts *log.HandlerOptions) (*Handler, error) {
+	return nil, fmt.Errorf("journald is only supported on linux")
+}
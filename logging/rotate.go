@@ -0,0 +1,287 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTime is the timestamp added to the name of a rotated file, it sorts chronologically
+const backupTime = "2006-01-02T15-04-05.000"
+
+// RotateOptions controls when a log file is rotated and how many rotated files are kept
+type RotateOptions struct {
+	// MaxSize is the size in bytes a file grows to before it is rotated, 0 disables size based rotation
+	MaxSize int64
+	// MaxAge is how long a file is written to before it is rotated, 0 disables age based rotation
+	MaxAge time.Duration
+	// MaxBackups is the number of rotated files kept, 0 keeps every rotated file
+	MaxBackups int
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// RotatingFile is an io.Writer that appends to a file and rotates it by size and age.
+// Rotated files are renamed to <name>-<timestamp><ext> in the same directory.
+type RotatingFile struct {
+	mu   sync.Mutex
+	path string
+	opts RotateOptions
+
+	file *os.File
+	info os.FileInfo
+	size int64
+	// startedAt is when the file was started, its age is measured from it
+	startedAt time.Time
+}
+
+// NewRotatingFile opens path for appending, creating it and its directory if needed
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	r := &RotatingFile{path: path, opts: opts}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Path is the file logs are written to, rotated files are kept next to it
+func (r *RotatingFile) Path() string {
+	return r.path
+}
+
+// Write appends p to the file, rotating it first when p would exceed the max size or the file is too old
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.shouldRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Reopen closes and reopens the file, for use with external tools such as logrotate
+// that move the file aside and signal the client with SIGHUP
+func (r *RotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+
+	return r.open()
+}
+
+// Close closes the file, a subsequent write reopens it
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return fmt.Errorf("cannot create log directory: %v", err)
+	}
+
+	f, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return fmt.Errorf("cannot open log file: %v", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("cannot stat log file: %v", err)
+	}
+
+	r.file, r.size, r.startedAt = f, info.Size(), r.started(info)
+	r.info = info
+	return nil
+}
+
+// started returns when the file was started so that a restart does not reset its age: now for an
+// empty file, otherwise when the previous file was rotated, which is in the name of the newest backup.
+// A reopened file keeps its time and a file without backups is at least as old as its last write.
+func (r *RotatingFile) started(info os.FileInfo) time.Time {
+	if info.Size() == 0 {
+		return time.Now()
+	}
+
+	if r.info != nil && os.SameFile(r.info, info) {
+		return r.startedAt
+	}
+
+	if backups, err := r.backups(); err == nil && len(backups) > 0 {
+		if t, ok := r.backupTime(backups[0]); ok && !t.After(info.ModTime()) {
+			return t
+		}
+	}
+
+	return info.ModTime()
+}
+
+func (r *RotatingFile) shouldRotate(n int) bool {
+	if r.size == 0 {
+		return false
+	}
+
+	if r.opts.MaxSize > 0 && r.size+int64(n) > r.opts.MaxSize {
+		return true
+	}
+
+	return r.opts.MaxAge > 0 && time.Since(r.startedAt) >= r.opts.MaxAge
+}
+
+func (r *RotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	backup := r.backupName(time.Now())
+	if err := os.Rename(r.path, backup); err != nil {
+		return fmt.Errorf("cannot rotate log file: %v", err)
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	// a failure to compress or remove old files must not stop logging
+	if r.opts.Compress {
+		if err := compress(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "cannot compress rotated log file %s: %v\n", backup, err)
+		}
+	}
+
+	if err := r.prune(); err != nil {
+		fmt.Fprintf(os.Stderr, "cannot remove rotated log files: %v\n", err)
+	}
+
+	return nil
+}
+
+func (r *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(r.path, ext), t.Format(backupTime), ext)
+}
+
+// backupTime returns the time a file was rotated at from its name, ok is false for other files
+func (r *RotatingFile) backupTime(name string) (time.Time, bool) {
+	ext := filepath.Ext(r.path)
+	prefix := strings.TrimSuffix(filepath.Base(r.path), ext) + "-"
+
+	name = strings.TrimSuffix(filepath.Base(name), ".gz")
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return time.Time{}, false
+	}
+
+	t, err := time.ParseInLocation(backupTime, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext), time.Local)
+	return t, err == nil
+}
+
+// backups returns the rotated files of r, newest first
+func (r *RotatingFile) backups() ([]string, error) {
+	dir := filepath.Dir(r.path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		if _, ok := r.backupTime(e.Name()); e.IsDir() || !ok {
+			continue
+		}
+
+		backups = append(backups, e.Name())
+	}
+
+	// the timestamp sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	for i := range backups {
+		backups[i] = filepath.Join(dir, backups[i])
+	}
+
+	return backups, nil
+}
+
+func (r *RotatingFile) prune() error {
+	if r.opts.MaxBackups <= 0 {
+		return nil
+	}
+
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+
+	for i := r.opts.MaxBackups; i < len(backups); i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,148 @@
+package logging_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/logging"
+	"github.com/matryer/is"
+)
+
+func TestRotatingFile(t *testing.T) {
+	is := is.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "imup.log")
+
+	f, err := logging.NewRotatingFile(path, logging.RotateOptions{MaxSize: 10, MaxBackups: 2, Compress: true})
+	is.NoErr(err)
+	defer f.Close()
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		_, err := f.Write([]byte(line))
+		is.NoErr(err)
+		// rotated files are named by the millisecond
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	b, err := os.ReadFile(path)
+	is.NoErr(err)
+	is.Equal("fourth\n", string(b))
+
+	backups, err := filepath.Glob(filepath.Join(dir, "logs", "imup-*.log.gz"))
+	is.NoErr(err)
+	is.Equal(2, len(backups)) // only the newest backups are kept
+
+	gz, err := os.Open(backups[1])
+	is.NoErr(err)
+	defer gz.Close()
+	r, err := gzip.NewReader(gz)
+	is.NoErr(err)
+	b, err = io.ReadAll(r)
+	is.NoErr(err)
+	is.Equal("third\n", string(b))
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "imup.log")
+	f, err := logging.NewRotatingFile(path, logging.RotateOptions{MaxAge: 10 * time.Millisecond})
+	is.NoErr(err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("old\n"))
+	is.NoErr(err)
+	time.Sleep(20 * time.Millisecond)
+	_, err = f.Write([]byte("new\n"))
+	is.NoErr(err)
+
+	b, err := os.ReadFile(path)
+	is.NoErr(err)
+	is.Equal("new\n", string(b))
+
+	backups, err := filepath.Glob(strings.TrimSuffix(path, ".log") + "-*.log")
+	is.NoErr(err)
+	is.Equal(1, len(backups))
+}
+
+func TestRotatingFileAgeRestart(t *testing.T) {
+	is := is.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "imup.log")
+
+	// the file was started when the previous one was rotated two hours ago
+	rotated := time.Now().Add(-2 * time.Hour)
+	is.NoErr(os.WriteFile(filepath.Join(dir, "imup-"+rotated.Format("2006-01-02T15-04-05.000")+".log"), []byte("older\n"), 0644))
+	is.NoErr(os.WriteFile(path, []byte("old\n"), 0644))
+
+	f, err := logging.NewRotatingFile(path, logging.RotateOptions{MaxAge: time.Hour})
+	is.NoErr(err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("new\n"))
+	is.NoErr(err)
+
+	b, err := os.ReadFile(path)
+	is.NoErr(err)
+	is.Equal("new\n", string(b))
+
+	backups, err := filepath.Glob(filepath.Join(dir, "imup-*.log"))
+	is.NoErr(err)
+	is.Equal(2, len(backups))
+
+	// reopening the same file keeps its age
+	f, err = logging.NewRotatingFile(filepath.Join(t.TempDir(), "imup.log"), logging.RotateOptions{MaxAge: 30 * time.Millisecond})
+	is.NoErr(err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("old\n"))
+	is.NoErr(err)
+	time.Sleep(20 * time.Millisecond)
+	is.NoErr(f.Reopen())
+	time.Sleep(20 * time.Millisecond)
+	_, err = f.Write([]byte("new\n"))
+	is.NoErr(err)
+
+	b, err = os.ReadFile(f.Path())
+	is.NoErr(err)
+	is.Equal("new\n", string(b))
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("an open file cannot be renamed on windows")
+	}
+
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "imup.log")
+	f, err := logging.NewRotatingFile(path, logging.RotateOptions{})
+	is.NoErr(err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("before\n"))
+	is.NoErr(err)
+
+	// logrotate moves the file aside before signaling the client
+	is.NoErr(os.Rename(path, path+".1"))
+	is.NoErr(f.Reopen())
+
+	_, err = f.Write([]byte("after\n"))
+	is.NoErr(err)
+
+	b, err := os.ReadFile(path)
+	is.NoErr(err)
+	is.Equal("after\n", string(b))
+
+	b, err = os.ReadFile(path + ".1")
+	is.NoErr(err)
+	is.Equal("before\n", string(b))
+}
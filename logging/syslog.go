@@ -0,0 +1,191 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+const (
+	// logs are sent with the daemon facility
+	facility = 3
+	// appName identifies the client in syslog and journald
+	appName = "imup"
+	// sdID is the id of the structured data element holding log attributes,
+	// 32473 is the private enterprise number reserved for documentation and examples
+	sdID = "imup@32473"
+	// rfc5424Time is a timestamp with the maximum precision allowed by rfc 5424
+	rfc5424Time = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// NewSyslog returns a handler that writes rfc 5424 formatted records to a syslog server.
+// address is a url with a udp, tcp or unix scheme, e.g. udp://localhost:514,
+// an empty address uses the local syslog socket.
+func NewSyslog(address string, opts *log.HandlerOptions) (*Handler, error) {
+	network, addr := "", ""
+	if address != "" {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("url.Parse: %v", err)
+		}
+
+		switch u.Scheme {
+		case "udp", "tcp":
+			network, addr = u.Scheme, u.Host
+		case "unix", "unixgram":
+			network, addr = u.Scheme, u.Path
+		default:
+			return nil, fmt.Errorf("syslog address must be a udp, tcp or unix url, e.g. udp://localhost:514: %s", address)
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	s := &syslogTransport{network: network, addr: addr, hostname: hostname, pid: os.Getpid()}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+
+	return newHandler(s, opts), nil
+}
+
+// syslogTransport writes to a syslog server and reconnects when a write fails
+type syslogTransport struct {
+	mu   sync.Mutex
+	conn net.Conn
+
+	network  string
+	addr     string
+	hostname string
+	pid      int
+}
+
+func (s *syslogTransport) connect() error {
+	if s.network == "" {
+		conn, err := dialLocal()
+		if err != nil {
+			return err
+		}
+
+		s.conn = conn
+		return nil
+	}
+
+	conn, err := net.DialTimeout(s.network, s.addr, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("cannot connect to syslog: %v", err)
+	}
+
+	s.conn = conn
+	return nil
+}
+
+func (s *syslogTransport) write(level log.Level, t time.Time, msg string, fields []field) error {
+	b := s.format(level, t, msg, fields)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		if _, err := s.conn.Write(frame(s.conn, b)); err == nil {
+			return nil
+		}
+
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	// the server may have restarted, try once more with a new connection
+	if err := s.connect(); err != nil {
+		return err
+	}
+
+	_, err := s.conn.Write(frame(s.conn, b))
+	return err
+}
+
+// frame delimits messages sent over a stream, datagrams are sent as is
+func frame(conn net.Conn, b []byte) []byte {
+	switch conn.RemoteAddr().Network() {
+	case "tcp":
+		// octet counting (rfc 6587)
+		return append([]byte(fmt.Sprintf("%d ", len(b))), b...)
+	case "unix":
+		// local syslog daemons read a message per line
+		return append(b, '\n')
+	}
+
+	return b
+}
+
+// format returns msg as an rfc 5424 message with fields as structured data
+func (s *syslogTransport) format(level log.Level, t time.Time, msg string, fields []field) []byte {
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "<%d>1 %s %s %s %d - ", facility*8+severity(level), t.Format(rfc5424Time), nilValue(s.hostname), appName, s.pid)
+
+	if len(fields) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + sdID)
+		for _, f := range fields {
+			fmt.Fprintf(b, " %s=\"%s\"", sdName(f.key), sdEscaper.Replace(f.value))
+		}
+		b.WriteString("]")
+	}
+
+	if msg != "" {
+		b.WriteString(" " + msg)
+	}
+
+	return b.Bytes()
+}
+
+func (s *syslogTransport) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// sdEscaper escapes the characters rfc 5424 requires to be escaped in a param value
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// sdName returns key as a valid rfc 5424 param name, printable ascii without '=', ' ', ']' or '"'
+func sdName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+
+	if len(name) > 32 {
+		name = name[:32]
+	}
+
+	return nilValue(name)
+}
+
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
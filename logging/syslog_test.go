@@ -0,0 +1,83 @@
+package logging_test
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"testing"
+
+	"github.com/imup-io/client/logging"
+	"github.com/matryer/is"
+	log "golang.org/x/exp/slog"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	is := is.New(t)
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	is.NoErr(err)
+	defer pc.Close()
+
+	h, err := logging.NewSyslog("udp://"+pc.LocalAddr().String(), &log.HandlerOptions{Level: log.LevelInfo})
+	is.NoErr(err)
+	defer h.Close()
+
+	logger := log.New(h).With("hostId", "host1").WithGroup("speedtest")
+	logger.Debug("not sent")
+	logger.Warn("speed test failed", "error", errors.New(`bad "response"`), log.Group("result", "mbps", 1.5))
+
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	is.NoErr(err)
+
+	// <daemon.warning>1 timestamp hostname app procid msgid [structured data] message
+	expected := regexp.MustCompile(`^<28>1 \d{4}-\d{2}-\d{2}T\S+ \S+ imup \d+ - \[imup@32473 hostId="host1" speedtest\.error="bad \\"response\\"" speedtest\.result\.mbps="1\.5"\] speed test failed$`)
+	is.True(expected.Match(buf[:n])) // message is rfc 5424 formatted
+}
+
+func TestSyslogTCP(t *testing.T) {
+	is := is.New(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	defer ln.Close()
+
+	h, err := logging.NewSyslog("tcp://"+ln.Addr().String(), nil)
+	is.NoErr(err)
+	defer h.Close()
+
+	conn, err := ln.Accept()
+	is.NoErr(err)
+	defer conn.Close()
+
+	log.New(h).Info("first")
+	log.New(h).Error("second")
+
+	r := bufio.NewReader(conn)
+	for _, expected := range []string{`^<30>1 .* - - first$`, `^<27>1 .* - - second$`} {
+		// octet counting, the message length is followed by a space
+		var length int
+		for {
+			c, err := r.ReadByte()
+			is.NoErr(err)
+			if c == ' ' {
+				break
+			}
+			length = length*10 + int(c-'0')
+		}
+
+		msg := make([]byte, length)
+		_, err := io.ReadFull(r, msg)
+		is.NoErr(err)
+		is.True(regexp.MustCompile(expected).Match(msg))
+	}
+}
+
+func TestSyslogAddress(t *testing.T) {
+	is := is.New(t)
+
+	_, err := logging.NewSyslog("http://localhost:514", nil)
+	is.True(err != nil) // only udp, tcp and unix urls are supported
+}
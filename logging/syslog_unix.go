@@ -0,0 +1,22 @@
+//go:build !windows
+
+package logging
+
+import (
+	"fmt"
+	"net"
+)
+
+// dialLocal connects to the local syslog socket, like log/syslog it tries
+// datagram and stream sockets at the well known paths
+func dialLocal() (net.Conn, error) {
+	for _, network := range []string{"unixgram", "unix"} {
+		for _, path := range []string{"/dev/log", "/var/run/syslog", "/var/run/log"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				return conn, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("cannot connect to the local syslog socket")
+}
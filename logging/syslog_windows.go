@@ -0,0 +1,13 @@
+//go:build windows
+
+package logging
+
+import (
+	"fmt"
+	"net"
+)
+
+// dialLocal fails, windows has no local syslog socket
+func dialLocal() (net.Conn, error) {
+	return nil, fmt.Errorf("local syslog is not supported on windows, configure a udp or tcp syslog address")
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -11,6 +14,42 @@ import (
 )
 
 func main() {
+	name, args := parseCommand(os.Args[1:])
+	if name == "help" {
+		usage()
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %s\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	flag.Usage = usage
+	args, err := commandFlags(name, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		os.Exit(2)
+	}
+
+	// the remaining arguments are parsed as flags along with the configuration
+	os.Args = append([]string{os.Args[0]}, args...)
+
+	if cmd.run != nil {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := cmd.run(ctx, os.Stdout); err != nil {
+			log.Error(name, "error", err)
+			stop()
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Perform the startup and shutdown sequence.
 	// create a channel to listen for an interrupt or terminate signal from the OS.
 	shutdown := make(chan os.Signal, 1)
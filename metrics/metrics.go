@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "imup"
+
+// registry is intentionally separate from the prometheus default registry
+// so that only imup metrics (and the go runtime) are exposed
+var registry = prometheus.NewRegistry()
+
+var (
+	mu             sync.RWMutex
+	queueDepthFunc func() int
+)
+
+var (
+	rtt = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connectivity_rtt_seconds",
+		Help:      "Round trip time of the last connectivity test per target.",
+	}, []string{"address", "endpoint_type", "stat"})
+
+	packetLoss = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connectivity_packet_loss_ratio",
+		Help:      "Packet loss of the last connectivity test per target, between 0 and 1.",
+	}, []string{"address", "endpoint_type"})
+
+	success = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "connectivity_success",
+		Help:      "Whether the last connectivity test per target succeeded (1) or not (0).",
+	}, []string{"address", "endpoint_type"})
+
+	downtime = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downtime_seconds_total",
+		Help:      "Seconds of detected internet downtime.",
+	})
+
+	speed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "speedtest_mbps",
+		Help:      "Throughput of the last speed test.",
+	}, []string{"direction"})
+
+	retrans = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "speedtest_retransmission_ratio",
+		Help:      "Retransmission rate of the last speed test.",
+	}, []string{"direction"})
+
+	minRTT = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "speedtest_min_rtt_seconds",
+		Help:      "Minimum round trip time observed during the last speed test.",
+	}, []string{"direction"})
+
+	speedTests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "speedtests_total",
+		Help:      "Speed tests run by the client.",
+	}, []string{"on_demand", "result"})
+
+	sends = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sends_total",
+		Help:      "Jobs sent to the imup api.",
+	}, []string{"result"})
+
+	sendRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "send_retries_total",
+		Help:      "Retried attempts to send a job to the imup api.",
+	})
+
+	queueDepth = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Jobs waiting to be sent to the imup api.",
+	}, currentQueueDepth)
+
+	configReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "config_reloads_total",
+		Help:      "Remote configuration reloads.",
+	}, []string{"result"})
+
+	networkChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "network_changes_total",
+		Help:      "Changes of the network the host is connected to.",
+	}, []string{"scope"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		rtt, packetLoss, success, downtime,
+		speed, retrans, minRTT, speedTests,
+		sends, sendRetries, queueDepth, configReloads,
+		networkChanges,
+	)
+}
+
+// Handler serves all registered metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// SetQueueDepth reports the number of jobs waiting to be sent using depth
+func SetQueueDepth(depth func() int) {
+	mu.Lock()
+	defer mu.Unlock()
+	queueDepthFunc = depth
+}
+
+func currentQueueDepth() float64 {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if queueDepthFunc == nil {
+		return 0
+	}
+
+	return float64(queueDepthFunc())
+}
+
+// ObserveStatistics records the rtt, loss and success of collected connectivity statistics
+func ObserveStatistics(stats []connectivity.Statistics) {
+	for _, s := range stats {
+		if s.PingAddress == "" {
+			continue
+		}
+
+		endpoint := s.EndpointType
+		if endpoint == "" {
+			endpoint = "external"
+		}
+
+		rtt.WithLabelValues(s.PingAddress, endpoint, "min").Set(s.MinRtt.Seconds())
+		rtt.WithLabelValues(s.PingAddress, endpoint, "avg").Set(s.AvgRtt.Seconds())
+		rtt.WithLabelValues(s.PingAddress, endpoint, "max").Set(s.MaxRtt.Seconds())
+		rtt.WithLabelValues(s.PingAddress, endpoint, "stddev").Set(s.StdDevRtt.Seconds())
+		packetLoss.WithLabelValues(s.PingAddress, endpoint).Set(s.PacketLoss / 100)
+		success.WithLabelValues(s.PingAddress, endpoint).Set(boolToFloat(s.Success))
+		otelStatistics(s, endpoint)
+	}
+}
+
+// AddDowntime increments the total detected downtime
+func AddDowntime(d time.Duration) {
+	if d > 0 {
+		downtime.Add(d.Seconds())
+		otelDowntime.Add(context.Background(), d.Seconds())
+	}
+}
+
+// ObserveSpeedTest records the outcome of a speed test, result is nil when the test failed
+func ObserveSpeedTest(result *speedtesting.SpeedTestResult, onDemand bool) {
+	otelSpeedTest(result, onDemand)
+
+	if result == nil {
+		speedTests.WithLabelValues(boolToLabel(onDemand), "failure").Inc()
+		return
+	}
+
+	speedTests.WithLabelValues(boolToLabel(onDemand), "success").Inc()
+
+	speed.WithLabelValues("download").Set(result.DownloadMbps)
+	speed.WithLabelValues("upload").Set(result.UploadMbps)
+	// ndt7 reports retransmissions as a percentage
+	retrans.WithLabelValues("download").Set(result.DownloadRetrans / 100)
+	retrans.WithLabelValues("upload").Set(result.UploadRetrans / 100)
+
+	// ndt7 reports min rtt in milliseconds
+	minRTT.WithLabelValues("download").Set(result.DownloadMinRtt / 1000)
+	minRTT.WithLabelValues("upload").Set(result.UploadMinRTT / 1000)
+}
+
+// ObserveSend records the outcome of sending a job to the imup api
+func ObserveSend(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	sends.WithLabelValues(result).Inc()
+	otelSend(result)
+}
+
+// ObserveSendRetry records a retried attempt to send a job to the imup api
+func ObserveSendRetry() {
+	sendRetries.Inc()
+	otelSendRetries.Add(context.Background(), 1)
+}
+
+// ObserveConfigReload records the outcome of a remote configuration reload
+func ObserveConfigReload(err error) {
+	if err != nil {
+		configReloads.WithLabelValues("failure").Inc()
+	} else {
+		configReloads.WithLabelValues("success").Inc()
+	}
+}
+
+// ObserveNetworkChange records a change of the network, local is false when only the
+// public ip changed
+func ObserveNetworkChange(local bool) {
+	if local {
+		networkChanges.WithLabelValues("local").Inc()
+	} else {
+		networkChanges.WithLabelValues("public").Inc()
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func boolToLabel(b bool) string {
+	if b {
+		return "true"
+	}
+
+	return "false"
+}
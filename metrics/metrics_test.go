@@ -0,0 +1,99 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/metrics"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/matryer/is"
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestMetrics(t *testing.T) {
+	is := is.New(t)
+
+	metrics.SetQueueDepth(func() int { return 7 })
+	metrics.ObserveStatistics([]connectivity.Statistics{
+		{PingAddress: "1.1.1.1", EndpointType: "external", Success: true, AvgRtt: 20 * time.Millisecond, PacketLoss: 50},
+	})
+	metrics.AddDowntime(2 * time.Minute)
+	metrics.ObserveSpeedTest(&speedtesting.SpeedTestResult{DownloadMbps: 123.4, UploadMbps: 12.3, DownloadRetrans: 1, DownloadMinRtt: 15}, false)
+	metrics.ObserveSpeedTest(nil, true)
+	metrics.ObserveSend(nil)
+	metrics.ObserveSend(errors.New("failed"))
+	metrics.ObserveSendRetry()
+	metrics.ObserveConfigReload(nil)
+	metrics.ObserveNetworkChange(true)
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	b, err := io.ReadAll(rec.Body)
+	is.NoErr(err)
+	out := string(b)
+
+	for _, expected := range []string{
+		`imup_connectivity_rtt_seconds{address="1.1.1.1",endpoint_type="external",stat="avg"} 0.02`,
+		`imup_connectivity_packet_loss_ratio{address="1.1.1.1",endpoint_type="external"} 0.5`,
+		`imup_connectivity_success{address="1.1.1.1",endpoint_type="external"} 1`,
+		`imup_downtime_seconds_total 120`,
+		`imup_speedtest_mbps{direction="download"} 123.4`,
+		`imup_speedtest_retransmission_ratio{direction="download"} 0.01`,
+		`imup_speedtest_min_rtt_seconds{direction="download"} 0.015`,
+		`imup_speedtests_total{on_demand="false",result="success"} 1`,
+		`imup_speedtests_total{on_demand="true",result="failure"} 1`,
+		`imup_sends_total{result="failure"} 1`,
+		`imup_sends_total{result="success"} 1`,
+		`imup_send_retries_total 1`,
+		`imup_queue_depth 7`,
+		`imup_config_reloads_total{result="success"} 1`,
+		`imup_network_changes_total{scope="local"} 1`,
+	} {
+		is.True(strings.Contains(out, expected))
+	}
+}
+
+func TestOTelMetrics(t *testing.T) {
+	is := is.New(t)
+
+	// instruments created before a meter provider is registered delegate to it
+	reader := sdkmetric.NewManualReader()
+	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)))
+
+	metrics.ObserveStatistics([]connectivity.Statistics{
+		{PingAddress: "1.1.1.1", EndpointType: "external", Success: true, AvgRtt: 20 * time.Millisecond},
+	})
+	metrics.AddDowntime(time.Minute)
+	metrics.ObserveSpeedTest(&speedtesting.SpeedTestResult{DownloadMbps: 100, UploadMbps: 10}, true)
+	metrics.ObserveSend(nil)
+
+	rm := metricdata.ResourceMetrics{}
+	is.NoErr(reader.Collect(context.Background(), &rm))
+
+	names := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			names[m.Name] = true
+		}
+	}
+
+	for _, expected := range []string{
+		"imup.connectivity.rtt",
+		"imup.connectivity.packet_loss",
+		"imup.connectivity.tests",
+		"imup.downtime",
+		"imup.speedtest.throughput",
+		"imup.speedtests",
+		"imup.sends",
+	} {
+		is.True(names[expected]) // missing otel metric
+	}
+}
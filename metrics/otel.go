@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/speedtesting"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// otel instruments mirror the prometheus metrics and are exported over otlp
+// once telemetry.Setup registers a meter provider, until then they are a no-op
+var (
+	otelRTT          metric.Float64Histogram
+	otelPacketLoss   metric.Float64Histogram
+	otelTests        metric.Int64Counter
+	otelDowntime     metric.Float64Counter
+	otelThroughput   metric.Float64Histogram
+	otelSpeedTestRTT metric.Float64Histogram
+	otelSpeedTests   metric.Int64Counter
+	otelSends        metric.Int64Counter
+	otelSendRetries  metric.Int64Counter
+)
+
+func init() {
+	meter := otel.Meter("github.com/imup-io/client")
+
+	// instruments from the global meter never fail, the errors only report invalid names
+	otelRTT, _ = meter.Float64Histogram("imup.connectivity.rtt", metric.WithUnit("s"),
+		metric.WithDescription("Average round trip time of a connectivity test per target."))
+	otelPacketLoss, _ = meter.Float64Histogram("imup.connectivity.packet_loss", metric.WithUnit("1"),
+		metric.WithDescription("Packet loss of a connectivity test per target, between 0 and 1."))
+	otelTests, _ = meter.Int64Counter("imup.connectivity.tests",
+		metric.WithDescription("Connectivity tests per target and outcome."))
+	otelDowntime, _ = meter.Float64Counter("imup.downtime", metric.WithUnit("s"),
+		metric.WithDescription("Detected internet downtime."))
+	otelThroughput, _ = meter.Float64Histogram("imup.speedtest.throughput", metric.WithUnit("Mbit/s"),
+		metric.WithDescription("Throughput of a speed test."))
+	otelSpeedTestRTT, _ = meter.Float64Histogram("imup.speedtest.min_rtt", metric.WithUnit("s"),
+		metric.WithDescription("Minimum round trip time observed during a speed test."))
+	otelSpeedTests, _ = meter.Int64Counter("imup.speedtests",
+		metric.WithDescription("Speed tests run by the client."))
+	otelSends, _ = meter.Int64Counter("imup.sends",
+		metric.WithDescription("Jobs sent to the imup api."))
+	otelSendRetries, _ = meter.Int64Counter("imup.send.retries",
+		metric.WithDescription("Retried attempts to send a job to the imup api."))
+}
+
+func otelStatistics(s connectivity.Statistics, endpoint string) {
+	ctx := context.Background()
+	target := metric.WithAttributes(attribute.String("address", s.PingAddress), attribute.String("endpoint_type", endpoint))
+
+	otelRTT.Record(ctx, s.AvgRtt.Seconds(), target)
+	otelPacketLoss.Record(ctx, s.PacketLoss/100, target)
+	otelTests.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("address", s.PingAddress),
+		attribute.String("endpoint_type", endpoint),
+		attribute.Bool("success", s.Success),
+	))
+}
+
+func otelSpeedTest(result *speedtesting.SpeedTestResult, onDemand bool) {
+	ctx := context.Background()
+	outcome := "success"
+	if result == nil {
+		outcome = "failure"
+	}
+
+	otelSpeedTests.Add(ctx, 1, metric.WithAttributes(attribute.Bool("on_demand", onDemand), attribute.String("result", outcome)))
+	if result == nil {
+		return
+	}
+
+	download := metric.WithAttributes(attribute.String("direction", "download"))
+	upload := metric.WithAttributes(attribute.String("direction", "upload"))
+
+	otelThroughput.Record(ctx, result.DownloadMbps, download)
+	otelThroughput.Record(ctx, result.UploadMbps, upload)
+	otelSpeedTestRTT.Record(ctx, result.DownloadMinRtt/1000, download)
+	otelSpeedTestRTT.Record(ctx, result.UploadMinRTT/1000, upload)
+}
+
+func otelSend(outcome string) {
+	otelSends.Add(context.Background(), 1, metric.WithAttributes(attribute.String("result", outcome)))
+}
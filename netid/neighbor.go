@@ -0,0 +1,64 @@
+package netid
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// parseARPOutput finds ip in the output of the arp command
+//
+//	macos:   ? (192.168.1.1) at aa:bb:cc:d:e:f on en0 ifscope [ethernet]
+//	windows: 192.168.1.1           aa-bb-cc-dd-ee-ff     dynamic
+func parseARPOutput(out string, ip net.IP) (net.HardwareAddr, error) {
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+
+		found := false
+		for _, f := range fields {
+			if net.ParseIP(strings.Trim(f, "()")).Equal(ip) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			continue
+		}
+
+		for _, f := range fields {
+			if mac, err := parseMAC(f); err == nil {
+				return mac, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("%s is not in the arp table", ip)
+}
+
+// parseMAC parses a mac address separated by colons or dashes, the arp command
+// on macos omits leading zeros of each octet
+func parseMAC(s string) (net.HardwareAddr, error) {
+	octets := strings.FieldsFunc(s, func(r rune) bool { return r == ':' || r == '-' })
+	if len(octets) != 6 {
+		return nil, fmt.Errorf("invalid mac address %q", s)
+	}
+
+	for n, o := range octets {
+		if len(o) == 1 {
+			octets[n] = "0" + o
+		}
+	}
+
+	mac, err := net.ParseMAC(strings.Join(octets, ":"))
+	if err != nil {
+		return nil, err
+	}
+
+	// incomplete entries are listed with an empty address
+	if strings.Trim(mac.String(), "0:") == "" {
+		return nil, fmt.Errorf("incomplete mac address %q", s)
+	}
+
+	return mac, nil
+}
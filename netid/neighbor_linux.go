@@ -0,0 +1,40 @@
+package netid
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// lookupNeighbor reads the mac address of ip from the kernel arp table
+func lookupNeighbor(ip net.IP) (net.HardwareAddr, error) {
+	b, err := os.ReadFile("/proc/net/arp")
+	if err != nil {
+		return nil, fmt.Errorf("os.ReadFile: %v", err)
+	}
+
+	return parseProcARP(string(b), ip)
+}
+
+// parseProcARP finds ip in the format of /proc/net/arp
+//
+//	IP address       HW type     Flags       HW address            Mask     Device
+//	192.168.1.1      0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+func parseProcARP(table string, ip net.IP) (net.HardwareAddr, error) {
+	for _, line := range strings.Split(table, "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !net.ParseIP(fields[0]).Equal(ip) {
+			continue
+		}
+
+		// incomplete entries have no address
+		if fields[2] == "0x0" {
+			continue
+		}
+
+		return parseMAC(fields[3])
+	}
+
+	return nil, fmt.Errorf("%s is not in the arp table", ip)
+}
@@ -0,0 +1,26 @@
+package netid
+
+import (
+	"net"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestParseProcARP(t *testing.T) {
+	is := is.New(t)
+
+	table := `IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.50     0x1         0x0         00:00:00:00:00:00     *        eth0
+192.168.1.1      0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0
+`
+	mac, err := parseProcARP(table, net.ParseIP("192.168.1.1"))
+	is.NoErr(err)
+	is.Equal("aa:bb:cc:dd:ee:ff", mac.String())
+
+	_, err = parseProcARP(table, net.ParseIP("192.168.1.50")) // incomplete
+	is.True(err != nil)
+
+	_, err = parseProcARP(table, net.ParseIP("192.168.1.2"))
+	is.True(err != nil)
+}
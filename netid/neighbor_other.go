@@ -0,0 +1,31 @@
+//go:build !linux
+
+package netid
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// lookupNeighbor asks the arp command for the mac address of ip
+func lookupNeighbor(ip net.IP) (net.HardwareAddr, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// windows lists an address with -a, the bsds and macos resolve names without -n
+	args := []string{"-n", ip.String()}
+	if runtime.GOOS == "windows" {
+		args = []string{"-a", ip.String()}
+	}
+
+	out, err := exec.CommandContext(ctx, "arp", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("arp: %v", err)
+	}
+
+	return parseARPOutput(string(out), ip)
+}
@@ -0,0 +1,194 @@
+// Package netid identifies the network a host is connected to by its default gateway,
+// local subnet and public address, so that monitoring can depend on the network rather
+// than on a public ip address that changes with every lease.
+package netid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/netip"
+	"strings"
+
+	gw "github.com/jackpal/gateway"
+	log "golang.org/x/exp/slog"
+)
+
+// Identity describes the network a host is connected to, fields that cannot be
+// detected are empty
+type Identity struct {
+	// ID is a fingerprint of the local network, the gateway mac address when it is
+	// known and otherwise the gateway and subnet. It does not change with the public ip.
+	ID         string   `json:"id,omitempty"`
+	GatewayIP  string   `json:"gatewayIP,omitempty"`
+	GatewayMAC string   `json:"gatewayMAC,omitempty"`
+	Subnet     string   `json:"subnet,omitempty"`
+	Interface  string   `json:"interface,omitempty"`
+	LocalIP    string   `json:"localIP,omitempty"`
+	PublicIPs  []string `json:"publicIPs,omitempty"`
+	ASN        string   `json:"asn,omitempty"`
+
+	// Tunnel is the vpn or tunnel interface traffic to the internet is routed through
+	Tunnel string `json:"tunnel,omitempty"`
+}
+
+// Options for detecting the network identity
+type Options struct {
+	// PublicIPs are the public addresses of the host, they are looked up by the caller
+	PublicIPs []string
+	// ASN optionally returns the autonomous system of a public address
+	ASN func(publicIP string) string
+	// TunnelInterfaces are name prefixes of interfaces that are vpns or tunnels in
+	// addition to the known ones
+	TunnelInterfaces []string
+}
+
+// detection hooks, replaced by tests
+var (
+	discoverGateway   = gw.DiscoverGateway
+	discoverInterface = gw.DiscoverInterface
+	interfaceAddrs    = localInterfaceAddrs
+	neighbor          = lookupNeighbor
+	outboundAddrs     = routedAddrs
+)
+
+// Detect returns the identity of the network the host is connected to
+func Detect(opts Options) Identity {
+	id := Identity{PublicIPs: opts.PublicIPs}
+
+	if opts.ASN != nil {
+		for _, ip := range opts.PublicIPs {
+			if id.ASN = normalizeASN(opts.ASN(ip)); id.ASN != "" {
+				break
+			}
+		}
+	}
+
+	// a vpn often leaves the default route alone and routes the internet through
+	// more specific routes, so it is detected apart from the gateway
+	id.Tunnel = tunnel(opts.TunnelInterfaces)
+
+	gateway, err := discoverGateway()
+	if err != nil {
+		log.Debug("cannot discover default gateway", "error", err)
+		id.ID = fingerprint(id)
+		return id
+	}
+	id.GatewayIP = gateway.String()
+
+	if mac, err := neighbor(gateway); err != nil {
+		log.Debug("cannot find gateway mac address", "gateway", id.GatewayIP, "error", err)
+	} else {
+		id.GatewayMAC = mac.String()
+	}
+
+	if local, err := discoverInterface(); err == nil {
+		id.LocalIP = local.String()
+	}
+
+	id.Interface, id.Subnet = subnet(gateway, id.LocalIP)
+	id.ID = fingerprint(id)
+
+	return id
+}
+
+// interfaceAddr is an address of a named local interface
+type interfaceAddr struct {
+	name  string
+	flags net.Flags
+	addr  net.Addr
+}
+
+func localInterfaceAddrs() ([]interfaceAddr, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []interfaceAddr{}
+	for _, iface := range ifaces {
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, a := range ifaceAddrs {
+			addrs = append(addrs, interfaceAddr{iface.Name, iface.Flags, a})
+		}
+	}
+
+	return addrs, nil
+}
+
+// subnet returns the interface and prefix that contains the gateway, or those of
+// the local address of the default route
+func subnet(gateway net.IP, local string) (string, string) {
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return "", ""
+	}
+
+	var name, fallback string
+	for _, a := range addrs {
+		ipNet, ok := a.addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		prefix, ok := netipPrefix(ipNet)
+		if !ok {
+			continue
+		}
+
+		if ipNet.Contains(gateway) {
+			return a.name, prefix.String()
+		}
+
+		if ipNet.IP.String() == local {
+			name, fallback = a.name, prefix.String()
+		}
+	}
+
+	return name, fallback
+}
+
+func netipPrefix(ipNet *net.IPNet) (netip.Prefix, bool) {
+	addr, ok := netip.AddrFromSlice(ipNet.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+
+	ones, _ := ipNet.Mask.Size()
+	addr = addr.Unmap()
+	if addr.Is4() && ones > 32 {
+		ones -= 96
+	}
+
+	return netip.PrefixFrom(addr, ones).Masked(), true
+}
+
+// fingerprint identifies the local network without the public address
+func fingerprint(id Identity) string {
+	var key string
+	switch {
+	case id.GatewayMAC != "":
+		key = "mac:" + id.GatewayMAC
+	case id.GatewayIP != "":
+		key = "gateway:" + id.GatewayIP + "," + id.Subnet
+	default:
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizeASN formats 7922 and as7922 as AS7922
+func normalizeASN(asn string) string {
+	asn = strings.ToUpper(strings.TrimSpace(asn))
+	if asn == "" {
+		return ""
+	}
+
+	return "AS" + strings.TrimPrefix(asn, "AS")
+}
@@ -0,0 +1,256 @@
+package netid
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestDetect(t *testing.T) {
+	is := is.New(t)
+
+	restore := []any{discoverGateway, discoverInterface, interfaceAddrs, neighbor, outboundAddrs}
+	defer func() {
+		discoverGateway = restore[0].(func() (net.IP, error))
+		discoverInterface = restore[1].(func() (net.IP, error))
+		interfaceAddrs = restore[2].(func() ([]interfaceAddr, error))
+		neighbor = restore[3].(func(net.IP) (net.HardwareAddr, error))
+		outboundAddrs = restore[4].(func() []net.IP)
+	}()
+
+	discoverGateway = func() (net.IP, error) { return net.ParseIP("192.168.1.1"), nil }
+	discoverInterface = func() (net.IP, error) { return net.ParseIP("192.168.1.23"), nil }
+	interfaceAddrs = func() ([]interfaceAddr, error) {
+		return []interfaceAddr{
+			{"lo", net.FlagLoopback, &net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}},
+			{"eth0", net.FlagUp, &net.IPNet{IP: net.ParseIP("192.168.1.23"), Mask: net.CIDRMask(24, 32)}},
+			{"tun0", net.FlagUp | net.FlagPointToPoint, &net.IPNet{IP: net.ParseIP("10.8.0.2"), Mask: net.CIDRMask(24, 32)}},
+			{"corp0", net.FlagUp, &net.IPNet{IP: net.ParseIP("10.9.0.2"), Mask: net.CIDRMask(24, 32)}},
+		}, nil
+	}
+	neighbor = func(net.IP) (net.HardwareAddr, error) { return net.ParseMAC("aa:bb:cc:dd:ee:ff") }
+	outboundAddrs = func() []net.IP { return []net.IP{net.ParseIP("192.168.1.23")} }
+
+	id := Detect(Options{PublicIPs: []string{"203.0.113.1"}, ASN: func(string) string { return "7922" }})
+	is.Equal("192.168.1.1", id.GatewayIP)
+	is.Equal("aa:bb:cc:dd:ee:ff", id.GatewayMAC)
+	is.Equal("192.168.1.0/24", id.Subnet)
+	is.Equal("eth0", id.Interface)
+	is.Equal("192.168.1.23", id.LocalIP)
+	is.Equal([]string{"203.0.113.1"}, id.PublicIPs)
+	is.Equal("AS7922", id.ASN)
+	is.Equal("", id.Tunnel)
+	is.True(id.ID != "")
+
+	// a vpn routes the internet through its own interface and keeps the network
+	outboundAddrs = func() []net.IP { return []net.IP{net.ParseIP("10.8.0.2")} }
+	vpn := Detect(Options{})
+	is.Equal("tun0", vpn.Tunnel)
+	is.Equal("eth0", vpn.Interface)
+	is.Equal(id.ID, vpn.ID)
+
+	// interfaces that are not known tunnels can be configured
+	outboundAddrs = func() []net.IP { return []net.IP{net.ParseIP("10.9.0.2")} }
+	is.Equal("", Detect(Options{}).Tunnel)
+	is.Equal("corp0", Detect(Options{TunnelInterfaces: []string{"corp"}}).Tunnel)
+	outboundAddrs = func() []net.IP { return []net.IP{net.ParseIP("192.168.1.23")} }
+
+	// the fingerprint follows the gateway mac address, not the public ip
+	moved := Detect(Options{PublicIPs: []string{"198.51.100.7"}})
+	is.Equal(id.ID, moved.ID)
+
+	// without the mac address the gateway and subnet identify the network
+	neighbor = func(net.IP) (net.HardwareAddr, error) { return nil, errors.New("not found") }
+	id = Detect(Options{})
+	is.Equal("", id.GatewayMAC)
+	is.True(id.ID != "" && id.ID != moved.ID)
+
+	discoverGateway = func() (net.IP, error) { return nil, errors.New("no gateway") }
+	id = Detect(Options{PublicIPs: []string{"203.0.113.1"}})
+	is.Equal("", id.GatewayIP)
+	is.Equal("", id.ID)
+}
+
+func TestRules(t *testing.T) {
+	is := is.New(t)
+
+	home := Identity{ID: "3f2a9c01d4e5b6a7", GatewayIP: "192.168.1.1", GatewayMAC: "aa:bb:cc:dd:ee:ff", Subnet: "192.168.1.0/24", PublicIPs: []string{"203.0.113.9"}, ASN: "AS7922"}
+	office := Identity{GatewayIP: "10.0.0.1", GatewayMAC: "00:11:22:33:44:55", Subnet: "10.0.0.0/16", PublicIPs: []string{"198.51.100.7", "2001:db8::7"}}
+
+	for _, entry := range []string{
+		"gateway-mac:AA-BB-CC-DD-EE-FF",
+		"gateway:192.168.1.0/24",
+		"subnet:192.168.1.0/24",
+		"asn:7922",
+		"network:3f2a9c01d4e5b6a7",
+		"203.0.113.0/24",
+	} {
+		r := NewRules([]string{entry})
+		is.True(r.Match(home))    // entry matches home
+		is.True(!r.Match(office)) // entry does not match office
+	}
+
+	// any public ip of a dual stack host matches
+	is.True(NewRules([]string{"2001:db8::/32"}).Match(office))
+
+	// exclusions
+	r := NewRules([]string{"192.168.0.0/16", "!gateway-mac:aa:bb:cc:dd:ee:ff", "subnet:10.0.0.0/16"})
+	is.True(!r.Match(home))
+	is.True(r.Match(office))
+	is.True(NewRules([]string{"!asn:AS7922"}).Match(office))
+
+	// invalid entries configure rules that match nothing
+	r = NewRules([]string{"gateway-mac:nope", "subnet:nope"})
+	is.True(!r.Empty())
+	is.True(!r.Match(home))
+
+	is.True(NewRules([]string{""}).Empty())
+	is.True(!NewRules(nil).Match(home))
+
+	is.True(Monitored(home, nil, nil))
+	is.True(Monitored(home, NewRules([]string{"gateway-mac:aa:bb:cc:dd:ee:ff"}), nil))
+	is.True(!Monitored(office, NewRules([]string{"gateway-mac:aa:bb:cc:dd:ee:ff"}), nil))
+	is.True(!Monitored(home, nil, NewRules([]string{"asn:AS7922"})))
+
+	vpn := home
+	vpn.Tunnel = "wg0"
+	is.True(NewRules([]string{"tunnel:*"}).Match(vpn))
+	is.True(NewRules([]string{"tunnel:wg"}).Match(vpn))
+	is.True(!NewRules([]string{"tunnel:tun"}).Match(vpn))
+	is.True(!NewRules([]string{"tunnel:*"}).Match(home))
+	is.True(NewRules([]string{"!tunnel:*"}).Match(home))
+}
+
+func TestIsTunnel(t *testing.T) {
+	is := is.New(t)
+
+	for _, name := range []string{"tun0", "tap1", "wg0", "utun3", "ppp0", "tailscale0", "ProtonVPN", "OpenVPN Data Channel Offload", "WireGuard Tunnel"} {
+		is.True(IsTunnel(name, net.FlagUp, nil)) // a known tunnel name
+	}
+
+	for _, name := range []string{"eth0", "en0", "wlan0", "Wi-Fi", "Ethernet 2"} {
+		is.True(!IsTunnel(name, net.FlagUp, nil)) // not a tunnel
+	}
+
+	is.True(IsTunnel("vti0", net.FlagUp|net.FlagPointToPoint, nil))
+	is.True(IsTunnel("Ethernet 2", net.FlagUp, []string{"ethernet 2"}))
+	is.True(!IsTunnel("lo", net.FlagLoopback|net.FlagPointToPoint, []string{"lo"}))
+}
+
+func TestProfiles(t *testing.T) {
+	is := is.New(t)
+
+	profiles, err := ParseProfiles(`[
+		{"name": "metered", "match": ["gateway-mac:aa:bb:cc:dd:ee:ff"], "speedTests": false},
+		{"name": "any", "match": ["!subnet:10.0.0.0/8"], "monitor": true}
+	]`)
+	is.NoErr(err)
+
+	p := NewProfiles(profiles)
+	is.True(!p.Empty())
+	is.Equal("metered", p.Select(Identity{GatewayMAC: "aa:bb:cc:dd:ee:ff"}).Name)
+	is.Equal("any", p.Select(Identity{Subnet: "192.168.1.0/24"}).Name)
+	is.Equal(nil, p.Select(Identity{Subnet: "10.0.0.0/8"}))
+
+	profiles, err = ParseProfiles("")
+	is.NoErr(err)
+	is.True(NewProfiles(profiles).Empty())
+
+	_, err = ParseProfiles("{")
+	is.True(err != nil)
+}
+
+func TestNeighbor(t *testing.T) {
+	is := is.New(t)
+	gateway := net.ParseIP("192.168.1.1")
+
+	mac, err := parseARPOutput("? (192.168.1.1) at aa:bb:cc:d:e:f on en0 ifscope [ethernet]\n", gateway)
+	is.NoErr(err)
+	is.Equal("aa:bb:cc:0d:0e:0f", mac.String())
+
+	mac, err = parseARPOutput("\nInterface: 192.168.1.23 --- 0x4\n  Internet Address      Physical Address      Type\n  192.168.1.1           aa-bb-cc-dd-ee-ff     dynamic\n", gateway)
+	is.NoErr(err)
+	is.Equal("aa:bb:cc:dd:ee:ff", mac.String())
+
+	_, err = parseARPOutput("? (192.168.1.1) at (incomplete) on en0 ifscope [ethernet]\n", gateway)
+	is.True(err != nil)
+}
+
+func TestCompare(t *testing.T) {
+	is := is.New(t)
+
+	home := Identity{GatewayIP: "192.168.1.1", GatewayMAC: "aa:bb:cc:dd:ee:ff", Interface: "eth0", Subnet: "192.168.1.0/24", PublicIPs: []string{"203.0.113.7", "2001:db8::1"}}
+	is.Equal([]string{}, Compare(home, home))
+
+	reordered := home
+	reordered.PublicIPs = []string{"2001:db8::1", "203.0.113.7"}
+	is.Equal([]string{}, Compare(home, reordered))
+
+	// a failed public ip lookup is not a change
+	unknown := home
+	unknown.PublicIPs = nil
+	is.Equal([]string{}, Compare(home, unknown))
+
+	lease := home
+	lease.PublicIPs = []string{"203.0.113.8"}
+	is.Equal([]string{"publicIP"}, Compare(home, lease))
+	is.True(!Change{Changed: Compare(home, lease)}.Local())
+
+	office := Identity{GatewayIP: "10.0.0.1", GatewayMAC: "11:22:33:44:55:66", Interface: "wlan0", Subnet: "10.0.0.0/16", PublicIPs: home.PublicIPs}
+	is.Equal([]string{"gateway", "gatewayMAC", "interface", "subnet"}, Compare(home, office))
+	is.True(Change{Changed: Compare(home, office)}.Local())
+
+	vpn := home
+	vpn.Tunnel = "wg0"
+	is.Equal([]string{"tunnel"}, Compare(home, vpn))
+}
+
+func TestWatch(t *testing.T) {
+	is := is.New(t)
+
+	home := Identity{ID: "home", GatewayIP: "192.168.1.1", Subnet: "192.168.1.0/24"}
+	office := Identity{ID: "office", GatewayIP: "10.0.0.1", Subnet: "10.0.0.0/16"}
+
+	detected := []Identity{home, office, office, home}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan Change, len(detected))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Watch(ctx, WatchOptions{
+			Initial:  home,
+			Interval: 10 * time.Millisecond,
+			Detect: func() Identity {
+				if len(detected) == 0 {
+					cancel()
+					return home
+				}
+				id := detected[0]
+				detected = detected[1:]
+				return id
+			},
+			OnChange: func(c Change) { changes <- c },
+		})
+	}()
+
+	<-done
+	close(changes)
+
+	got := []Change{}
+	for c := range changes {
+		got = append(got, c)
+	}
+
+	is.Equal(2, len(got))
+	is.Equal("home", got[0].Previous.ID)
+	is.Equal("office", got[0].Current.ID)
+	is.Equal([]string{"gateway", "subnet"}, got[0].Changed)
+	is.Equal("office", got[1].Previous.ID)
+	is.Equal("home", got[1].Current.ID)
+}
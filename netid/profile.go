@@ -0,0 +1,66 @@
+package netid
+
+import "encoding/json"
+
+// Profile changes monitoring on the networks it matches, unset fields keep the
+// configured behavior
+type Profile struct {
+	Name string `json:"name"`
+	// Match are rules in the format of NewRules
+	Match []string `json:"match"`
+	// Monitor overrides the allow and block lists
+	Monitor *bool `json:"monitor,omitempty"`
+	// SpeedTests overrides whether scheduled speed tests run
+	SpeedTests *bool `json:"speedTests,omitempty"`
+}
+
+// ParseProfiles decodes a json list of profiles
+func ParseProfiles(data string) ([]Profile, error) {
+	profiles := []Profile{}
+	if data == "" {
+		return profiles, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &profiles); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// Profiles are compiled profiles
+type Profiles struct {
+	profiles []Profile
+	rules    []*Rules
+}
+
+// NewProfiles compiles the rules of profiles once
+func NewProfiles(profiles []Profile) *Profiles {
+	p := &Profiles{profiles: profiles}
+	for _, profile := range profiles {
+		p.rules = append(p.rules, NewRules(profile.Match))
+	}
+
+	return p
+}
+
+// Select returns the first profile that matches id, or nil
+func (p *Profiles) Select(id Identity) *Profile {
+	if p == nil {
+		return nil
+	}
+
+	for n, rules := range p.rules {
+		if rules.Match(id) {
+			profile := p.profiles[n]
+			return &profile
+		}
+	}
+
+	return nil
+}
+
+// Empty reports whether no profiles are configured
+func (p *Profiles) Empty() bool {
+	return p == nil || len(p.profiles) == 0
+}
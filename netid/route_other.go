@@ -0,0 +1,10 @@
+//go:build !linux
+
+package netid
+
+import "context"
+
+// subscribe returns no events, the network is polled on other platforms
+func subscribe(ctx context.Context) (<-chan struct{}, error) {
+	return nil, nil
+}
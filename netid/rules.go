@@ -0,0 +1,165 @@
+package netid
+
+import (
+	"net/netip"
+	"strings"
+
+	"github.com/imup-io/client/util"
+	log "golang.org/x/exp/slog"
+)
+
+// Rules match a network identity against a list of entries. An entry is a public ip
+// address, prefix or range as accepted by util.NewIPMatcher, or one of
+//
+//	gateway:192.168.1.1            the default gateway, a prefix or range also works
+//	gateway-mac:aa:bb:cc:dd:ee:ff  the mac address of the default gateway
+//	subnet:192.168.1.0/24          the local subnet of the default route
+//	asn:AS7922                     the autonomous system of the public ip
+//	network:3f2a9c01d4e5b6a7       the identity fingerprint
+//	tunnel:wg                      a vpn or tunnel interface by name prefix, tunnel:* any
+//
+// Any entry prefixed with ! is an exclusion. An identity matches when it matches an
+// entry and no exclusion, a list of only exclusions matches every other identity.
+type Rules struct {
+	configured bool
+	include    []rule
+	exclude    []rule
+}
+
+// rule matches one attribute of an identity
+type rule func(id Identity) bool
+
+// NewRules compiles entries, empty entries are ignored and invalid entries are
+// logged and never match
+func NewRules(entries []string) *Rules {
+	r := &Rules{}
+	includeIPs, excludeIPs := []string{}, []string{}
+	for _, e := range entries {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+
+		// an invalid entry still configures the rules, so that an allowlist of
+		// only invalid entries allows nothing
+		r.configured = true
+
+		exclude := strings.HasPrefix(e, "!")
+		entry := strings.TrimSpace(strings.TrimPrefix(e, "!"))
+
+		kind, value, _ := strings.Cut(entry, ":")
+		match := parseRule(strings.ToLower(kind), strings.TrimSpace(value))
+		if match == nil {
+			// public ip entries are compiled together
+			if exclude {
+				excludeIPs = append(excludeIPs, entry)
+			} else {
+				includeIPs = append(includeIPs, entry)
+			}
+			continue
+		}
+
+		if exclude {
+			r.exclude = append(r.exclude, match)
+		} else {
+			r.include = append(r.include, match)
+		}
+	}
+
+	if len(includeIPs) > 0 {
+		r.include = append(r.include, publicIPRule(util.NewIPMatcher(includeIPs)))
+	}
+
+	if len(excludeIPs) > 0 {
+		r.exclude = append(r.exclude, publicIPRule(util.NewIPMatcher(excludeIPs)))
+	}
+
+	return r
+}
+
+// parseRule returns the rule of a qualified entry, or nil for a public ip entry
+func parseRule(kind, value string) rule {
+	switch kind {
+	case "gateway":
+		m := util.NewIPMatcher([]string{value})
+		return func(id Identity) bool { return m.Contains(id.GatewayIP) }
+	case "gateway-mac":
+		mac, err := parseMAC(value)
+		if err != nil {
+			log.Warn("ignoring invalid gateway mac address entry", "entry", value, "error", err)
+			return never
+		}
+		return func(id Identity) bool { return id.GatewayMAC == mac.String() }
+	case "subnet":
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			log.Warn("ignoring invalid subnet entry", "entry", value, "error", err)
+			return never
+		}
+		subnet := prefix.Masked().String()
+		return func(id Identity) bool { return id.Subnet == subnet }
+	case "asn":
+		asn := normalizeASN(value)
+		return func(id Identity) bool { return asn != "" && id.ASN == asn }
+	case "network":
+		return func(id Identity) bool { return value != "" && id.ID == value }
+	case "tunnel":
+		if value == "*" {
+			return func(id Identity) bool { return id.Tunnel != "" }
+		}
+		return func(id Identity) bool { return value != "" && strings.HasPrefix(id.Tunnel, value) }
+	}
+
+	return nil
+}
+
+// publicIPRule matches when any public address of the identity is in m
+func publicIPRule(m *util.IPMatcher) rule {
+	return func(id Identity) bool {
+		for _, ip := range id.PublicIPs {
+			if m.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func never(Identity) bool {
+	return false
+}
+
+// Empty reports whether no entries are configured
+func (r *Rules) Empty() bool {
+	return r == nil || !r.configured
+}
+
+// Match reports whether id matches the rules, empty rules match nothing
+func (r *Rules) Match(id Identity) bool {
+	if r.Empty() {
+		return false
+	}
+
+	for _, m := range r.exclude {
+		if m(id) {
+			return false
+		}
+	}
+
+	if len(r.include) == 0 {
+		return len(r.exclude) > 0
+	}
+
+	for _, m := range r.include {
+		if m(id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Monitored reports whether a host on network id is monitored, it is when the allow rules
+// are empty or match and the block rules do not match
+func Monitored(id Identity, allowed, blocked *Rules) bool {
+	return (allowed.Empty() || allowed.Match(id)) && !blocked.Match(id)
+}
@@ -0,0 +1,89 @@
+package netid
+
+import (
+	"net"
+	"strings"
+)
+
+// knownTunnels are name prefixes of vpn and tunnel interfaces on linux, macos and the bsds
+var knownTunnels = []string{
+	"tun", "tap", "wg", "utun", "ipsec", "ppp", "gpd", "cscotun", "tailscale", "zt", "nordlynx",
+}
+
+// tunnelKeywords identify vpn adapters by the descriptive names they have on windows
+var tunnelKeywords = []string{"vpn", "tunnel", "wireguard", "openvpn", "tap-windows", "anyconnect"}
+
+// IsTunnel reports whether an interface is a vpn or tunnel, by a known or configured
+// name prefix or because it is a point to point link
+func IsTunnel(name string, flags net.Flags, configured []string) bool {
+	if flags&net.FlagLoopback != 0 {
+		return false
+	}
+
+	if flags&net.FlagPointToPoint != 0 {
+		return true
+	}
+
+	lower := strings.ToLower(name)
+	for _, prefix := range append(knownTunnels, configured...) {
+		if prefix = strings.ToLower(strings.TrimSpace(prefix)); prefix != "" && strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+
+	for _, keyword := range tunnelKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// tunnel returns the vpn or tunnel interface that has a local address the host sends
+// internet traffic from, or an empty string when traffic is not tunneled
+func tunnel(configured []string) string {
+	local := outboundAddrs()
+	if len(local) == 0 {
+		return ""
+	}
+
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return ""
+	}
+
+	for _, a := range addrs {
+		ipNet, ok := a.addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		for _, ip := range local {
+			if ipNet.IP.Equal(ip) && IsTunnel(a.name, a.flags, configured) {
+				return a.name
+			}
+		}
+	}
+
+	return ""
+}
+
+// routedAddrs returns the local addresses the routing table picks for traffic to the
+// internet, connecting a udp socket selects a route without sending anything
+func routedAddrs() []net.IP {
+	local := []net.IP{}
+	for network, address := range map[string]string{"udp4": "1.1.1.1:53", "udp6": "[2606:4700:4700::1111]:53"} {
+		conn, err := net.Dial(network, address)
+		if err != nil {
+			continue
+		}
+
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			local = append(local, addr.IP)
+		}
+		conn.Close()
+	}
+
+	return local
+}
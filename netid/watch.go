@@ -0,0 +1,150 @@
+package netid
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// Change describes a move of the host from one network to another
+type Change struct {
+	Previous Identity `json:"previous"`
+	Current  Identity `json:"current"`
+	// Changed lists the attributes that differ: gateway, gatewayMAC, interface,
+	// subnet, tunnel and publicIP
+	Changed []string  `json:"changed"`
+	At      time.Time `json:"at"`
+}
+
+// Local reports whether the local network changed, rather than only the public address
+func (c Change) Local() bool {
+	for _, attr := range c.Changed {
+		if attr != "publicIP" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Compare returns the attributes that differ between two identities
+func Compare(previous, current Identity) []string {
+	changed := []string{}
+	if previous.GatewayIP != current.GatewayIP {
+		changed = append(changed, "gateway")
+	}
+
+	if previous.GatewayMAC != current.GatewayMAC {
+		changed = append(changed, "gatewayMAC")
+	}
+
+	if previous.Interface != current.Interface {
+		changed = append(changed, "interface")
+	}
+
+	if previous.Subnet != current.Subnet {
+		changed = append(changed, "subnet")
+	}
+
+	if previous.Tunnel != current.Tunnel {
+		changed = append(changed, "tunnel")
+	}
+
+	if !samePublicIPs(previous.PublicIPs, current.PublicIPs) {
+		changed = append(changed, "publicIP")
+	}
+
+	return changed
+}
+
+// samePublicIPs compares addresses in any order, an address that could not be looked
+// up is not a change
+func samePublicIPs(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+
+	return strings.Join(a, ",") == strings.Join(b, ",")
+}
+
+// WatchOptions configure Watch
+type WatchOptions struct {
+	// Initial is the identity to compare the first detection against
+	Initial Identity
+	// Detect identifies the network, it is called on every poll and route event
+	Detect func() Identity
+	// OnChange is called with every change of the network
+	OnChange func(Change)
+	// Interval between polls, defaults to 30 seconds
+	Interval time.Duration
+	// Settle is how long to wait for route events to stop before detecting, defaults
+	// to 2 seconds
+	Settle time.Duration
+}
+
+// Watch detects the network on route events where the platform supports them and on
+// every interval otherwise, until ctx is done
+func Watch(ctx context.Context, opts WatchOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+
+	if opts.Settle <= 0 {
+		opts.Settle = 2 * time.Second
+	}
+
+	events, err := subscribe(ctx)
+	if err != nil {
+		log.Warn("cannot subscribe to route changes, polling the network instead", "error", err)
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	previous := opts.Initial
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-events:
+			// a move between networks is a burst of link, address and route events
+			if !settle(ctx, events, opts.Settle) {
+				return
+			}
+		}
+
+		current := opts.Detect()
+		if changed := Compare(previous, current); len(changed) > 0 {
+			opts.OnChange(Change{Previous: previous, Current: current, Changed: changed, At: time.Now()})
+		}
+		previous = current
+	}
+}
+
+// settle waits until no event arrived for d, it returns false when ctx is done
+func settle(ctx context.Context, events <-chan struct{}, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-timer.C:
+			return true
+		case <-events:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(d)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/imup-io/client/config"
+	"github.com/imup-io/client/metrics"
+	"github.com/imup-io/client/netid"
+	"github.com/imup-io/client/push"
+	"github.com/imup-io/client/speedtesting"
+	log "golang.org/x/exp/slog"
+)
+
+// how often the public ip is looked up when no rules depend on it, and when allow or block
+// rules or network profiles do, unless the gateway or interface changes before that
+const (
+	publicIPInterval         = 10 * time.Minute
+	publicIPRequiredInterval = time.Minute
+)
+
+// detectNetwork identifies the network the client is connected to and keeps it
+// for monitoring decisions and the status report
+func (i *imup) detectNetwork() netid.Identity {
+	id := netid.Detect(i.detectOptions())
+	if previous := i.State.recordNetwork(id); previous.ID != id.ID {
+		log.Info("network identified", "id", id.ID, "gateway", id.GatewayIP, "gatewayMAC", id.GatewayMAC, "subnet", id.Subnet, "tunnel", id.Tunnel)
+	}
+
+	return id
+}
+
+// detectOptions are the options the network is detected with
+func (i *imup) detectOptions() netid.Options {
+	return netid.Options{
+		PublicIPs:        i.cfg.PublicIPs(),
+		ASN:              func(ip string) string { return i.owner(ip).ASN },
+		TunnelInterfaces: i.cfg.TunnelInterfaces(),
+	}
+}
+
+// watchNetwork detects the network on route changes and every 30 seconds until ctx is done,
+// the public ip is looked up after the gateway or interface changed and otherwise every
+// minute when allow or block rules or network profiles depend on it and every 10 minutes when not
+func (i *imup) watchNetwork(ctx context.Context) {
+	refreshedAt := time.Now()
+
+	netid.Watch(ctx, netid.WatchOptions{
+		Initial: i.network(),
+		Detect: func() netid.Identity {
+			previous := i.State.network()
+			id := netid.Detect(i.detectOptions())
+
+			if i.publicIPStale(previous, id, refreshedAt) {
+				refreshedAt = time.Now()
+				i.cfg.RefreshPublicIP()
+				i.persistPublicIP()
+				id.PublicIPs = i.cfg.PublicIPs()
+				id.ASN = i.ownerASN(id.PublicIPs)
+			}
+
+			i.State.recordNetwork(id)
+			return id
+		},
+		OnChange: i.networkChanged,
+	})
+}
+
+// publicIPStale reports whether the public ip is looked up again after id was detected
+func (i *imup) publicIPStale(previous, id netid.Identity, refreshedAt time.Time) bool {
+	if id.ID != previous.ID || id.Interface != previous.Interface {
+		return true
+	}
+
+	interval := publicIPInterval
+	if i.publicIPRequired() {
+		interval = publicIPRequiredInterval
+	}
+
+	return time.Since(refreshedAt) >= interval
+}
+
+// publicIPRequired reports whether monitoring decisions depend on the public ip
+func (i *imup) publicIPRequired() bool {
+	return !i.cfg.AllowRules().Empty() || !i.cfg.BlockRules().Empty() || !i.cfg.NetworkProfiles().Empty()
+}
+
+// networkChanged re-baselines monitoring after the host moved to another network, the
+// gateway is discovered again and the collector restarts without its avoid list
+func (i *imup) networkChanged(c netid.Change) {
+	log.Info("network changed", "changed", c.Changed, "id", c.Current.ID, "previousID", c.Previous.ID,
+		"gateway", c.Current.GatewayIP, "interface", c.Current.Interface, "tunnel", c.Current.Tunnel, "publicIPs", c.Current.PublicIPs)
+
+	metrics.ObserveNetworkChange(c.Local())
+	i.State.recordNetworkChange(c)
+
+	if c.Local() {
+		i.cfg.RefreshGateway()
+
+		select {
+		case i.rebaseline <- struct{}{}:
+		default:
+		}
+	}
+
+	if i.Push.Connected() {
+		if err := i.Push.Send(push.TypeNetwork, c); err != nil {
+			log.Debug("cannot report network change over push channel", "error", err)
+		}
+	}
+}
+
+// network returns the identity of the network detected last with the current public ips
+func (i *imup) network() netid.Identity {
+	id := i.State.network()
+	id.PublicIPs = i.cfg.PublicIPs()
+
+	return id
+}
+
+// profile returns the monitoring profile of the current network, nil when none matches
+func (i *imup) profile() *netid.Profile {
+	return i.cfg.NetworkProfiles().Select(i.network())
+}
+
+// speedTests determines if scheduled speed tests run on the current network, a profile
+// overrides the configuration and they can be paused while routed through a vpn
+func (i *imup) speedTests() bool {
+	if p := i.profile(); p != nil && p.SpeedTests != nil {
+		return *p.SpeedTests
+	}
+
+	if i.State.network().Tunnel != "" && i.cfg.TunnelSpeedTests() == config.TunnelSpeedTestsPause {
+		return false
+	}
+
+	return i.cfg.SpeedTests()
+}
+
+// throughTunnel records the vpn or tunnel the host is routed through on speed test opts
+func (i *imup) throughTunnel(opts speedtesting.Options) speedtesting.Options {
+	opts.Tunnel = i.State.network().Tunnel
+	return opts
+}
+
+// aroundTunnel returns opts for a second speed test that leaves through the interface of
+// the default gateway instead of the tunnel, when configured and the interface is known
+func (i *imup) aroundTunnel(opts speedtesting.Options) (speedtesting.Options, bool) {
+	id := i.State.network()
+	if id.Tunnel == "" || i.cfg.TunnelSpeedTests() != config.TunnelSpeedTestsBoth {
+		return opts, false
+	}
+
+	if id.Interface == "" || id.Interface == id.Tunnel {
+		log.Info("cannot run speed test around tunnel, the interface of the default gateway is unknown", "tunnel", id.Tunnel)
+		return opts, false
+	}
+
+	opts.Tunnel = id.Tunnel
+	opts.Interface = id.Interface
+	return opts, true
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/netid"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/imup-io/client/state"
+	"github.com/matryer/is"
+)
+
+func TestNetworkProfiles(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "network-api-key")
+	os.Setenv("HOST_ID", "network-host")
+	os.Setenv("ALLOWLISTED_IPS", "192.0.2.1,gateway-mac:aa:bb:cc:dd:ee:ff")
+	os.Setenv("NETWORK_PROFILES", `[
+		{"name": "metered", "match": ["gateway-mac:00:11:22:33:44:55"], "speedTests": false},
+		{"name": "travel", "match": ["subnet:172.20.0.0/16"], "monitor": true}
+	]`)
+
+	imup := newApp()
+	imup.cfg.SetPublicIP("198.51.100.7")
+
+	// the home network is allowed by its gateway although the public ip changed
+	imup.State.recordNetwork(netid.Identity{ID: "home", GatewayMAC: "aa:bb:cc:dd:ee:ff"})
+	is.True(imup.monitoring())
+	is.True(imup.speedTests())
+	is.Equal("", imup.health().NetworkProfile)
+	is.Equal("home", imup.health().NetworkID)
+
+	// a profile overrides the configured speed tests
+	imup.State.recordNetwork(netid.Identity{GatewayMAC: "00:11:22:33:44:55"})
+	is.True(!imup.monitoring()) // not allowlisted
+	is.True(!imup.speedTests())
+	is.Equal("metered", imup.health().NetworkProfile)
+
+	// and the allow list
+	imup.State.recordNetwork(netid.Identity{Subnet: "172.20.0.0/16"})
+	is.True(imup.monitoring())
+	is.True(imup.speedTests())
+
+	is.Equal([]string{"198.51.100.7"}, imup.status().Network.PublicIPs)
+}
+
+func TestPublicIPStale(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "network-api-key")
+	os.Setenv("HOST_ID", "network-host")
+
+	home := netid.Identity{ID: "home", Interface: "eth0"}
+	recent := time.Now().Add(-40 * time.Second)
+
+	// without rules the public ip is looked up every 10 minutes
+	imup := newApp()
+	is.True(!imup.publicIPStale(home, home, time.Now().Add(-5*time.Minute)))
+	is.True(imup.publicIPStale(home, home, time.Now().Add(-publicIPInterval)))
+
+	// a new gateway or interface is looked up right away
+	is.True(imup.publicIPStale(home, netid.Identity{ID: "cafe", Interface: "eth0"}, recent))
+	is.True(imup.publicIPStale(home, netid.Identity{ID: "home", Interface: "wlan0"}, recent))
+
+	// rules that depend on it look it up every minute, not on every poll
+	os.Setenv("ALLOWLISTED_IPS", "192.0.2.1")
+	imup = newApp()
+	is.True(!imup.publicIPStale(home, home, recent))
+	is.True(imup.publicIPStale(home, home, time.Now().Add(-publicIPRequiredInterval)))
+}
+
+func TestNetworkChanged(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "network-api-key")
+	os.Setenv("HOST_ID", "network-host")
+	os.Setenv("PING_ADDRESS_INTERNAL", "10.0.0.1")
+	os.Setenv("STATE_FILE", filepath.Join(t.TempDir(), "client.json"))
+
+	imup := newApp()
+	imup.openStore()
+	is.NoErr(imup.Store.Update(func(st *state.State) { st.AvoidAddresses = []string{"192.0.2.10"} }))
+
+	home := netid.Identity{ID: "home", GatewayIP: "192.168.1.1", Subnet: "192.168.1.0/24", PublicIPs: []string{"198.51.100.7"}}
+	office := netid.Identity{ID: "office", GatewayIP: "10.0.0.1", Subnet: "10.0.0.0/16", PublicIPs: []string{"203.0.113.9"}}
+
+	// a new public ip alone does not restart monitoring
+	lease := home
+	lease.PublicIPs = []string{"198.51.100.8"}
+	imup.networkChanged(netid.Change{Previous: home, Current: lease, Changed: netid.Compare(home, lease)})
+	is.Equal(0, len(imup.rebaseline))
+	is.Equal([]string{"publicIP"}, imup.status().LastNetworkChange.Changed)
+
+	// a move to another network does
+	imup.networkChanged(netid.Change{Previous: lease, Current: office, Changed: netid.Compare(lease, office)})
+	is.Equal(1, len(imup.rebaseline))
+	is.Equal("office", imup.status().LastNetworkChange.Current.ID)
+	is.Equal("10.0.0.1", imup.cfg.InternalPingAddress()) // configured, not discovered
+
+	// signals do not pile up while the collector is busy
+	imup.networkChanged(netid.Change{Previous: office, Current: home, Changed: netid.Compare(office, home)})
+	is.Equal(1, len(imup.rebaseline))
+
+	imup.resetAvoidAddresses()
+	is.Equal(nil, imup.avoidAddresses())
+
+	// statistics are tagged with the network they were collected on
+	imup.State.recordNetwork(office)
+	collected := imup.collect(context.Background(), &fixedCollector{stats: []connectivity.Statistics{{Success: true}}})
+	is.Equal("office", collected[0].NetworkID)
+	is.Equal("office", imup.State.lastStatistics[0].NetworkID)
+}
+
+// fixedCollector returns the same statistics from every test
+type fixedCollector struct {
+	avoidingCollector
+	stats []connectivity.Statistics
+}
+
+func (c *fixedCollector) Interval() time.Duration { return time.Minute }
+
+func (c *fixedCollector) Collect(context.Context, []string) []connectivity.Statistics {
+	return append([]connectivity.Statistics{}, c.stats...)
+}
+
+func (c *fixedCollector) DetectDowntime([]connectivity.Statistics) (bool, int) { return false, 0 }
+
+func TestTunnel(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "network-api-key")
+	os.Setenv("HOST_ID", "network-host")
+	os.Setenv("TUNNEL_SPEED_TESTS", "pause")
+
+	home := netid.Identity{ID: "home", GatewayIP: "192.168.1.1", Interface: "eth0"}
+	vpn := home
+	vpn.Tunnel = "wg0"
+
+	imup := newApp()
+	imup.State.recordNetwork(home)
+	is.True(imup.speedTests())
+	is.Equal("", imup.throughTunnel(speedtesting.Options{}).Tunnel)
+
+	// scheduled speed tests pause while routed through the vpn
+	imup.State.recordNetwork(vpn)
+	is.True(!imup.speedTests())
+	is.Equal("wg0", imup.throughTunnel(speedtesting.Options{}).Tunnel)
+	_, ok := imup.aroundTunnel(speedtesting.Options{})
+	is.True(!ok)
+
+	collected := imup.collect(context.Background(), &fixedCollector{stats: []connectivity.Statistics{{Success: true}}})
+	is.Equal("wg0", collected[0].Tunnel)
+
+	// or run through and around it
+	os.Setenv("TUNNEL_SPEED_TESTS", "both")
+	imup = newApp()
+	imup.State.recordNetwork(vpn)
+	is.True(imup.speedTests())
+	around, ok := imup.aroundTunnel(imup.throughTunnel(speedtesting.Options{ClientVersion: "test"}))
+	is.True(ok)
+	is.Equal("eth0", around.Interface)
+	is.Equal("wg0", around.Tunnel)
+	is.Equal("test", around.ClientVersion)
+
+	// not when the default gateway is on the tunnel itself
+	vpn.Interface = "wg0"
+	imup.State.recordNetwork(vpn)
+	_, ok = imup.aroundTunnel(speedtesting.Options{})
+	is.True(!ok)
+}
@@ -0,0 +1,89 @@
+package publicip
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// dnsProvider asks a name server that answers a special name with the address of the caller
+type dnsProvider struct {
+	name   string
+	host   string
+	txt    bool
+	v4, v6 string
+}
+
+// DNSAddress returns a provider that resolves host, e.g. myip.opendns.com, to the address
+// of the caller by asking the name server v4Server over ipv4 and v6Server over ipv6
+func DNSAddress(name, host, v4Server, v6Server string) Provider {
+	return &dnsProvider{name: name, host: host, v4: v4Server, v6: v6Server}
+}
+
+// DNSText returns a provider like DNSAddress for name servers that answer with
+// a TXT record, e.g. o-o.myaddr.l.google.com
+func DNSText(name, host, v4Server, v6Server string) Provider {
+	return &dnsProvider{name: name, host: host, txt: true, v4: v4Server, v6: v6Server}
+}
+
+func (p *dnsProvider) Name() string {
+	return p.name
+}
+
+func (p *dnsProvider) Lookup(ctx context.Context, family Family) (netip.Addr, error) {
+	server := p.v4
+	if family == IPv6 {
+		server = p.v6
+	}
+
+	if server == "" {
+		return netip.Addr{}, errUnsupported
+	}
+
+	dialer := &net.Dialer{}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, family.network(network), server)
+		},
+	}
+
+	if p.txt {
+		records, err := resolver.LookupTXT(ctx, p.host)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("LookupTXT: %v", err)
+		}
+
+		return parseTXT(records)
+	}
+
+	network := "ip4"
+	if family == IPv6 {
+		network = "ip6"
+	}
+
+	addrs, err := resolver.LookupNetIP(ctx, network, p.host)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("LookupNetIP: %v", err)
+	}
+
+	if len(addrs) == 0 {
+		return netip.Addr{}, fmt.Errorf("no address for %s", p.host)
+	}
+
+	return addrs[0], nil
+}
+
+// parseTXT returns the first record that is an address, name servers may add
+// records that describe the query, e.g. its edns client subnet
+func parseTXT(records []string) (netip.Addr, error) {
+	for _, r := range records {
+		if addr, err := netip.ParseAddr(strings.TrimSpace(r)); err == nil {
+			return addr, nil
+		}
+	}
+
+	return netip.Addr{}, fmt.Errorf("no address in %q", records)
+}
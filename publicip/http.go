@@ -0,0 +1,94 @@
+package publicip
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// httpProvider asks a web service that echoes the address of the caller, in plain text
+// or as json with an ip field
+type httpProvider struct {
+	name   string
+	v4, v6 string
+}
+
+// HTTP returns a provider that requests v4URL and v6URL over ipv4 and ipv6 respectively,
+// an empty url leaves that family unsupported. Both can be the same dual stack url.
+func HTTP(name, v4URL, v6URL string) Provider {
+	return &httpProvider{name: name, v4: v4URL, v6: v6URL}
+}
+
+func (p *httpProvider) Name() string {
+	return p.name
+}
+
+func (p *httpProvider) Lookup(ctx context.Context, family Family) (netip.Addr, error) {
+	url := p.v4
+	if family == IPv6 {
+		url = p.v6
+	}
+
+	if url == "" {
+		return netip.Addr{}, errUnsupported
+	}
+
+	// the connection is made over family so that a dual stack host learns both addresses
+	dialer := &net.Dialer{}
+	client := &http.Client{Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialContext(ctx, family.network(network), addr)
+		},
+		DisableKeepAlives: true,
+	}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("http.NewRequest: %v", err)
+	}
+	req.Header.Set("User-Agent", "imup-client")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("client.Do: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("io.ReadAll: %v", err)
+	}
+
+	return parseBody(b)
+}
+
+// parseBody reads an address from a plain text or json response
+func parseBody(b []byte) (netip.Addr, error) {
+	body := strings.TrimSpace(string(b))
+	if strings.HasPrefix(body, "{") {
+		ip := struct {
+			IP string `json:"ip"`
+		}{}
+		if err := json.Unmarshal([]byte(body), &ip); err != nil {
+			return netip.Addr{}, fmt.Errorf("json.Unmarshal: %v", err)
+		}
+		body = ip.IP
+	}
+
+	addr, err := netip.ParseAddr(body)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("netip.ParseAddr: %v", err)
+	}
+
+	return addr, nil
+}
@@ -0,0 +1,229 @@
+// Package publicip discovers the public ip addresses of a host. Several providers are asked
+// for each address family, an address is accepted once enough of them agree on it.
+package publicip
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// Family is an ip address family
+type Family int
+
+// address families
+const (
+	IPv4 Family = 4
+	IPv6 Family = 6
+)
+
+func (f Family) String() string {
+	return fmt.Sprintf("ipv%d", f)
+}
+
+// matches reports whether addr belongs to f
+func (f Family) matches(addr netip.Addr) bool {
+	if f == IPv4 {
+		return addr.Unmap().Is4()
+	}
+
+	return addr.Is6() && !addr.Is4In6()
+}
+
+// network returns network, e.g. tcp, restricted to f, e.g. tcp4
+func (f Family) network(network string) string {
+	return fmt.Sprintf("%s%d", strings.TrimRight(network, "46"), f)
+}
+
+// errUnsupported is returned by providers that cannot look up an address family
+var errUnsupported = errors.New("address family is not supported by this provider")
+
+// Provider looks up the public address of the host in one address family
+type Provider interface {
+	Name() string
+	Lookup(ctx context.Context, family Family) (netip.Addr, error)
+}
+
+// Result are the public addresses of the host, an address is empty when the host
+// has no public address in that family or it could not be discovered
+type Result struct {
+	IPv4 string
+	IPv6 string
+}
+
+// Options configures a resolver
+type Options struct {
+	// Providers are asked at once, the first listed wins a tie, default is DefaultProviders
+	Providers []Provider
+	// Timeout bounds a lookup, default 5s
+	Timeout time.Duration
+	// Quorum is the number of providers that must agree on an address, default 2.
+	// Without a quorum the address most providers agree on is used.
+	Quorum int
+	// CacheTTL is how long a result is reused, default 30s
+	CacheTTL time.Duration
+}
+
+// Resolver discovers public addresses with several providers and caches the result
+type Resolver struct {
+	opts Options
+	now  func() time.Time
+
+	mu        sync.Mutex
+	cached    Result
+	cachedErr error
+	cachedAt  time.Time
+}
+
+// DefaultProviders are public http, dns and stun services, so that a quorum is reached
+// when one protocol is blocked
+func DefaultProviders() []Provider {
+	return []Provider{
+		HTTP("ipify", "https://api.ipify.org?format=json", "https://api6.ipify.org?format=json"),
+		DNSAddress("opendns", "myip.opendns.com", "208.67.222.222:53", "[2620:119:35::35]:53"),
+		STUN("google-stun", "stun.l.google.com:19302"),
+		HTTP("icanhazip", "https://ipv4.icanhazip.com", "https://ipv6.icanhazip.com"),
+		DNSText("google-dns", "o-o.myaddr.l.google.com", "216.239.32.10:53", "[2001:4860:4802:32::a]:53"),
+		STUN("cloudflare-stun", "stun.cloudflare.com:3478"),
+	}
+}
+
+// New returns a resolver for opts
+func New(opts Options) *Resolver {
+	if len(opts.Providers) == 0 {
+		opts.Providers = DefaultProviders()
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	if opts.Quorum <= 0 {
+		opts.Quorum = 2
+	}
+
+	if opts.Quorum > len(opts.Providers) {
+		opts.Quorum = len(opts.Providers)
+	}
+
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = 30 * time.Second
+	}
+
+	return &Resolver{opts: opts, now: time.Now}
+}
+
+// Lookup returns the public ipv4 and ipv6 addresses of the host, it fails only when
+// neither can be discovered. Failures are cached like results so that a host without
+// connectivity does not wait for every provider on every lookup.
+func (r *Resolver) Lookup(ctx context.Context) (Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.cachedAt.IsZero() && r.now().Sub(r.cachedAt) < r.opts.CacheTTL {
+		return r.cached, r.cachedErr
+	}
+
+	var v4, v6 netip.Addr
+	var err4, err6 error
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		v4, err4 = r.lookup(ctx, IPv4)
+	}()
+	go func() {
+		defer wg.Done()
+		v6, err6 = r.lookup(ctx, IPv6)
+	}()
+	wg.Wait()
+
+	result, err := Result{}, error(nil)
+	if err4 == nil {
+		result.IPv4 = v4.String()
+	}
+
+	if err6 == nil {
+		result.IPv6 = v6.String()
+	}
+
+	if err4 != nil && err6 != nil {
+		err = fmt.Errorf("cannot discover public ip: %v, %v", err4, err6)
+	}
+
+	r.cached, r.cachedErr, r.cachedAt = result, err, r.now()
+	return result, err
+}
+
+// answer is the address a provider returned
+type answer struct {
+	provider int
+	addr     netip.Addr
+	err      error
+}
+
+// lookup asks all providers for the address in family at once and returns as soon as
+// a quorum agrees on one, otherwise the address most providers agree on once all answered
+func (r *Resolver) lookup(ctx context.Context, family Family) (netip.Addr, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+	defer cancel()
+
+	answers := make(chan answer, len(r.opts.Providers))
+	for p := range r.opts.Providers {
+		go func(p int) {
+			addr, err := r.opts.Providers[p].Lookup(ctx, family)
+			if err == nil && !family.matches(addr) {
+				err = fmt.Errorf("returned %s", addr)
+			}
+			answers <- answer{p, addr.Unmap(), err}
+		}(p)
+	}
+
+	votes := map[netip.Addr]int{}
+	first := map[netip.Addr]int{}
+	errs := []string{}
+	for range r.opts.Providers {
+		a := <-answers
+		if a.err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", r.opts.Providers[a.provider].Name(), a.err))
+			continue
+		}
+
+		if _, ok := first[a.addr]; !ok || a.provider < first[a.addr] {
+			first[a.addr] = a.provider
+		}
+
+		if votes[a.addr]++; votes[a.addr] >= r.opts.Quorum {
+			r.warnDisagreement(family, a.addr, votes)
+			return a.addr, nil
+		}
+	}
+
+	var addr netip.Addr
+	n := 0
+	for a, v := range votes {
+		// ties go to the address returned by the provider listed first
+		if v > n || v == n && first[a] < first[addr] {
+			addr, n = a, v
+		}
+	}
+
+	if n == 0 {
+		return netip.Addr{}, fmt.Errorf("no %s address: %s", family, strings.Join(errs, "; "))
+	}
+
+	r.warnDisagreement(family, addr, votes)
+	return addr, nil
+}
+
+func (r *Resolver) warnDisagreement(family Family, addr netip.Addr, votes map[netip.Addr]int) {
+	if len(votes) > 1 {
+		log.Warn("public ip providers disagree", "family", family.String(), "address", addr, "votes", votes[addr], "answers", len(votes))
+	}
+}
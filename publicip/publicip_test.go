@@ -0,0 +1,212 @@
+package publicip
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+// fakeProvider answers with fixed addresses and counts lookups
+type fakeProvider struct {
+	name    string
+	v4, v6  string
+	lookups atomic.Int32
+}
+
+func (p *fakeProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeProvider) Lookup(_ context.Context, family Family) (netip.Addr, error) {
+	p.lookups.Add(1)
+
+	ip := p.v4
+	if family == IPv6 {
+		ip = p.v6
+	}
+
+	if ip == "" {
+		return netip.Addr{}, errors.New("unavailable")
+	}
+
+	return netip.ParseAddr(ip)
+}
+
+func providers(fakes ...*fakeProvider) []Provider {
+	ps := []Provider{}
+	for _, f := range fakes {
+		ps = append(ps, f)
+	}
+	return ps
+}
+
+func TestResolver(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	a := &fakeProvider{name: "a", v4: "203.0.113.1", v6: "2001:db8::1"}
+	b := &fakeProvider{name: "b", v4: "203.0.113.1", v6: "2001:db8::1"}
+	c := &fakeProvider{name: "c", v4: "203.0.113.1", v6: "2001:db8::1"}
+
+	r := New(Options{Providers: providers(a, b, c)})
+	result, err := r.Lookup(ctx)
+	is.NoErr(err)
+	is.Equal(Result{IPv4: "203.0.113.1", IPv6: "2001:db8::1"}, result)
+
+	// results are cached
+	_, err = r.Lookup(ctx)
+	is.NoErr(err)
+	is.Equal(int32(2), a.lookups.Load())
+
+	now := time.Now()
+	r.now = func() time.Time { return now.Add(time.Minute) }
+	_, err = r.Lookup(ctx)
+	is.NoErr(err)
+	is.Equal(int32(4), a.lookups.Load())
+}
+
+func TestResolverConsensus(t *testing.T) {
+	is := is.New(t)
+	ctx := context.Background()
+
+	// a wrong answer is outvoted
+	wrong := &fakeProvider{name: "wrong", v4: "198.51.100.7"}
+	a := &fakeProvider{name: "a", v4: "203.0.113.1"}
+	b := &fakeProvider{name: "b", v4: "203.0.113.1"}
+	result, err := New(Options{Providers: providers(wrong, a, b)}).Lookup(ctx)
+	is.NoErr(err)
+	is.Equal("203.0.113.1", result.IPv4)
+	is.Equal("", result.IPv6) // no provider has ipv6
+
+	// failing providers are skipped
+	down := &fakeProvider{name: "down"}
+	result, err = New(Options{Providers: providers(down, down, a, b)}).Lookup(ctx)
+	is.NoErr(err)
+	is.Equal("203.0.113.1", result.IPv4)
+
+	// without a quorum the provider listed first wins a tie
+	result, err = New(Options{Providers: providers(a, wrong, down)}).Lookup(ctx)
+	is.NoErr(err)
+	is.Equal("203.0.113.1", result.IPv4)
+
+	// a single answer is used when no other provider answers
+	result, err = New(Options{Providers: providers(down, wrong)}).Lookup(ctx)
+	is.NoErr(err)
+	is.Equal("198.51.100.7", result.IPv4)
+
+	// an address of the wrong family is not counted
+	mixed := &fakeProvider{name: "mixed", v4: "2001:db8::1", v6: "203.0.113.1"}
+	_, err = New(Options{Providers: providers(mixed)}).Lookup(ctx)
+	is.True(err != nil)
+
+	// failures are cached as well
+	offline := &fakeProvider{name: "offline"}
+	r := New(Options{Providers: providers(offline)})
+	_, err = r.Lookup(ctx)
+	is.True(err != nil)
+	_, err = r.Lookup(ctx)
+	is.True(err != nil)
+	is.Equal(int32(2), offline.lookups.Load())
+}
+
+func TestHTTP(t *testing.T) {
+	is := is.New(t)
+
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			fmt.Fprint(w, `{"ip":"203.0.113.1"}`)
+		case "/text":
+			fmt.Fprint(w, "203.0.113.2\n")
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer s.Close()
+
+	ctx := context.Background()
+	addr, err := HTTP("json", s.URL+"/json", "").Lookup(ctx, IPv4)
+	is.NoErr(err)
+	is.Equal("203.0.113.1", addr.String())
+
+	addr, err = HTTP("text", s.URL+"/text", "").Lookup(ctx, IPv4)
+	is.NoErr(err)
+	is.Equal("203.0.113.2", addr.String())
+
+	_, err = HTTP("down", s.URL+"/down", "").Lookup(ctx, IPv4)
+	is.True(err != nil)
+
+	_, err = HTTP("v4only", s.URL+"/json", "").Lookup(ctx, IPv6)
+	is.Equal(errUnsupported, err)
+
+	_, err = parseBody([]byte("<html>blocked</html>"))
+	is.True(err != nil)
+}
+
+func TestParseTXT(t *testing.T) {
+	is := is.New(t)
+
+	addr, err := parseTXT([]string{"edns0-client-subnet 198.51.100.0/24", "203.0.113.1"})
+	is.NoErr(err)
+	is.Equal("203.0.113.1", addr.String())
+
+	_, err = parseTXT([]string{"not an address"})
+	is.True(err != nil)
+}
+
+func TestSTUN(t *testing.T) {
+	is := is.New(t)
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	is.NoErr(err)
+	defer conn.Close()
+
+	// answer binding requests with the xor mapped address of the client
+	go func() {
+		b := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			if n < stunHeaderSize {
+				continue
+			}
+
+			ip := from.(*net.UDPAddr).IP.To4()
+			attr := make([]byte, 12)
+			binary.BigEndian.PutUint16(attr[0:], stunXorMappedAddress)
+			binary.BigEndian.PutUint16(attr[2:], 8)
+			attr[5] = 0x01
+			binary.BigEndian.PutUint16(attr[6:], uint16(from.(*net.UDPAddr).Port)^uint16(stunMagicCookie>>16))
+			for i := range ip {
+				attr[8+i] = ip[i] ^ b[4+i]
+			}
+
+			resp := append([]byte{}, b[:stunHeaderSize]...)
+			binary.BigEndian.PutUint16(resp[0:], stunBindingResponse)
+			binary.BigEndian.PutUint16(resp[2:], uint16(len(attr)))
+			conn.WriteTo(append(resp, attr...), from)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	addr, err := STUN("local", conn.LocalAddr().String()).Lookup(ctx, IPv4)
+	is.NoErr(err)
+	is.Equal("127.0.0.1", addr.String())
+
+	_, err = parseBindingResponse([]byte("not stun"), nil)
+	is.True(err != nil)
+}
@@ -0,0 +1,168 @@
+package publicip
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// stun message fields, see rfc 5389
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunMagicCookie     = 0x2112a442
+	stunHeaderSize      = 20
+
+	stunMappedAddress    = 0x0001
+	stunXorMappedAddress = 0x0020
+)
+
+// stunProvider sends a stun binding request, the server answers with the address it
+// received the request from
+type stunProvider struct {
+	name   string
+	server string
+}
+
+// STUN returns a provider that asks the stun server, e.g. stun.l.google.com:19302, over udp
+func STUN(name, server string) Provider {
+	return &stunProvider{name: name, server: server}
+}
+
+func (p *stunProvider) Name() string {
+	return p.name
+}
+
+func (p *stunProvider) Lookup(ctx context.Context, family Family) (netip.Addr, error) {
+	dialer := &net.Dialer{}
+	conn, err := dialer.DialContext(ctx, family.network("udp"), p.server)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+	conn.SetDeadline(deadline)
+
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(req[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	if _, err := rand.Read(req[8:stunHeaderSize]); err != nil {
+		return netip.Addr{}, fmt.Errorf("transaction id: %v", err)
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		return netip.Addr{}, fmt.Errorf("write: %v", err)
+	}
+
+	resp := make([]byte, 1500)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("read: %v", err)
+		}
+
+		addr, err := parseBindingResponse(resp[:n], req[8:stunHeaderSize])
+		if errors.Is(err, errOtherTransaction) {
+			continue
+		}
+
+		return addr, err
+	}
+}
+
+// errOtherTransaction is returned for a response to a different request, e.g. a late retransmit
+var errOtherTransaction = errors.New("response to another transaction")
+
+// parseBindingResponse returns the mapped address of a binding response to transaction id
+func parseBindingResponse(b, id []byte) (netip.Addr, error) {
+	if len(b) < stunHeaderSize || binary.BigEndian.Uint32(b[4:]) != stunMagicCookie {
+		return netip.Addr{}, errors.New("not a stun message")
+	}
+
+	if string(b[8:stunHeaderSize]) != string(id) {
+		return netip.Addr{}, errOtherTransaction
+	}
+
+	if t := binary.BigEndian.Uint16(b[0:]); t != stunBindingResponse {
+		return netip.Addr{}, fmt.Errorf("unexpected message type %#04x", t)
+	}
+
+	attrs := b[stunHeaderSize:]
+	if l := int(binary.BigEndian.Uint16(b[2:])); l < len(attrs) {
+		attrs = attrs[:l]
+	}
+
+	var mapped netip.Addr
+	for len(attrs) >= 4 {
+		t, l := binary.BigEndian.Uint16(attrs[0:]), int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+l {
+			break
+		}
+		value := attrs[4 : 4+l]
+
+		switch t {
+		case stunXorMappedAddress:
+			// the address is xor'd with the magic cookie followed by the transaction id
+			if addr, ok := parseAddressAttribute(value, b[4:stunHeaderSize]); ok {
+				return addr, nil
+			}
+		case stunMappedAddress:
+			if addr, ok := parseAddressAttribute(value, nil); ok {
+				mapped = addr
+			}
+		}
+
+		// attributes are padded to a multiple of four bytes
+		l = (l + 3) &^ 3
+		if len(attrs) < 4+l {
+			break
+		}
+		attrs = attrs[4+l:]
+	}
+
+	if mapped.IsValid() {
+		return mapped, nil
+	}
+
+	return netip.Addr{}, errors.New("no mapped address in response")
+}
+
+// parseAddressAttribute decodes a mapped address attribute, xor'd with key when it is not nil
+func parseAddressAttribute(value, key []byte) (netip.Addr, bool) {
+	if len(value) < 4 {
+		return netip.Addr{}, false
+	}
+
+	size := 0
+	switch value[1] {
+	case 0x01:
+		size = 4
+	case 0x02:
+		size = 16
+	default:
+		return netip.Addr{}, false
+	}
+
+	if len(value) < 4+size {
+		return netip.Addr{}, false
+	}
+
+	ip := make([]byte, size)
+	copy(ip, value[4:4+size])
+	for n := range ip {
+		if key != nil {
+			ip[n] ^= key[n]
+		}
+	}
+
+	return netip.AddrFromSlice(ip)
+}
@@ -0,0 +1,279 @@
+// Package push keeps a websocket open to the imup api. The api pushes realtime commands
+// and configuration changes over it and the client sends liveness and status, which
+// replaces polling the realtime endpoints while the socket is connected.
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "golang.org/x/exp/slog"
+)
+
+// message types sent by the client
+const (
+	// TypeHello is the first message of every connection and identifies the client
+	TypeHello = "hello"
+	// TypeLiveness replaces the liveness check in
+	TypeLiveness = "liveness"
+	// TypeStatus is the state of the client as reported by its status server
+	TypeStatus = "status"
+	// TypeNetwork reports that the host moved to another network
+	TypeNetwork = "network"
+)
+
+// message types sent by the api
+const (
+	// TypeSpeedTest asks the client to run an on demand speed test
+	TypeSpeedTest = "speedtest"
+	// TypeConfig carries a remote configuration in the same format as the realtime config endpoint
+	TypeConfig = "config"
+	// TypeCommand asks the client to run a diagnostic command
+	TypeCommand = "command"
+)
+
+// ErrNotConnected is returned when sending while the socket is not connected
+var ErrNotConnected = errors.New("push channel is not connected")
+
+// Message is sent in either direction over the socket
+type Message struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// Options configures a push client
+type Options struct {
+	// URL of the push endpoint, http and https are dialed as ws and wss
+	URL string
+	// Enabled is checked before every connection attempt and while connected,
+	// the socket is closed while it returns false
+	Enabled func() bool
+	// Hello is the data of the hello message sent on every connection
+	Hello func() any
+	// Handler is called for every message from the api, it is called on the
+	// reading goroutine and must not block
+	Handler func(ctx context.Context, m Message)
+
+	// MinBackoff and MaxBackoff bound the wait between connection attempts, default 1s and 5m
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// PingInterval is how often the connection is checked, default 30s
+	PingInterval time.Duration
+}
+
+// Client keeps a push connection open and reconnects with backoff when it is lost
+type Client struct {
+	opts   Options
+	dialer *websocket.Dialer
+
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+// New returns a client for opts, Run connects it
+func New(opts Options) *Client {
+	if opts.Enabled == nil {
+		opts.Enabled = func() bool { return true }
+	}
+
+	if opts.Hello == nil {
+		opts.Hello = func() any { return nil }
+	}
+
+	if opts.Handler == nil {
+		opts.Handler = func(context.Context, Message) {}
+	}
+
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = 30 * time.Second
+	}
+
+	return &Client{
+		opts:   opts,
+		dialer: &websocket.Dialer{HandshakeTimeout: 30 * time.Second},
+	}
+}
+
+// Connected reports whether the socket is currently connected
+func (c *Client) Connected() bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.conn != nil
+}
+
+// Send writes a message of type typ with data to the api
+func (c *Client) Send(typ string, data any) error {
+	if c == nil {
+		return ErrNotConnected
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.PingInterval))
+	if err := c.conn.WriteJSON(Message{Type: typ, Data: b}); err != nil {
+		return fmt.Errorf("WriteJSON: %v", err)
+	}
+
+	return nil
+}
+
+// Run connects to the api and reconnects until ctx is done
+func (c *Client) Run(ctx context.Context) {
+	backoff := c.opts.MinBackoff
+	for {
+		if c.opts.Enabled() {
+			started := time.Now()
+			err := c.serve(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			// a connection that was up for a while starts over with a short wait
+			if time.Since(started) > c.opts.MaxBackoff {
+				backoff = c.opts.MinBackoff
+			}
+
+			log.Warn("push channel unavailable, polling for realtime updates", "error", err, "retry", backoff)
+		}
+
+		// wait up to half again as long so that clients do not reconnect at once
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+// serve connects once and reads messages until the connection is lost
+func (c *Client) serve(ctx context.Context) error {
+	conn, resp, err := c.dialer.DialContext(ctx, wsURL(c.opts.URL), nil)
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("dial: %v: %s", err, resp.Status)
+		}
+
+		return fmt.Errorf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	hello, err := json.Marshal(c.opts.Hello())
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(c.opts.PingInterval))
+	if err := conn.WriteJSON(Message{Type: TypeHello, Data: hello}); err != nil {
+		return fmt.Errorf("hello: %v", err)
+	}
+
+	// a connection that misses two pings is considered lost
+	conn.SetReadLimit(1 << 20)
+	conn.SetReadDeadline(time.Now().Add(2 * c.opts.PingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * c.opts.PingInterval))
+	})
+
+	c.setConn(conn)
+	defer c.setConn(nil)
+	log.Info("push channel connected", "url", c.opts.URL)
+
+	done := make(chan struct{})
+	defer close(done)
+	go c.keepalive(ctx, conn, done)
+
+	for {
+		m := Message{}
+		if err := conn.ReadJSON(&m); err != nil {
+			return fmt.Errorf("read: %v", err)
+		}
+
+		c.opts.Handler(ctx, m)
+	}
+}
+
+// keepalive pings conn and closes it on shutdown or when push is disabled, which ends the read loop
+func (c *Client) keepalive(ctx context.Context, conn *websocket.Conn, done chan struct{}) {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !c.opts.Enabled() {
+				c.close(conn)
+				return
+			}
+
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.opts.PingInterval)); err != nil {
+				log.Debug("push channel ping failed", "error", err)
+			}
+		case <-ctx.Done():
+			c.close(conn)
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+func (c *Client) close(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.Close()
+}
+
+func (c *Client) setConn(conn *websocket.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn = conn
+}
+
+// wsURL dials http addresses as websockets
+func wsURL(addr string) string {
+	if strings.HasPrefix(addr, "https://") {
+		return "wss://" + strings.TrimPrefix(addr, "https://")
+	}
+
+	if strings.HasPrefix(addr, "http://") {
+		return "ws://" + strings.TrimPrefix(addr, "http://")
+	}
+
+	return addr
+}
@@ -0,0 +1,130 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/matryer/is"
+)
+
+// testServer accepts push connections and hands them to the test
+func testServer(t *testing.T) (*httptest.Server, chan *websocket.Conn) {
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		conns <- conn
+	}))
+
+	return s, conns
+}
+
+func TestClient(t *testing.T) {
+	is := is.New(t)
+
+	s, conns := testServer(t)
+	defer s.Close()
+
+	received := make(chan Message, 1)
+	c := New(Options{
+		URL:        s.URL,
+		Hello:      func() any { return map[string]string{"hostId": "push-host"} },
+		Handler:    func(_ context.Context, m Message) { received <- m },
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+	is.True(!c.Connected())
+	is.Equal(ErrNotConnected, c.Send(TypeLiveness, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(stopped)
+	}()
+
+	conn := <-conns
+	m := Message{}
+	is.NoErr(conn.ReadJSON(&m))
+	is.Equal(TypeHello, m.Type)
+	is.Equal(`{"hostId":"push-host"}`, string(m.Data))
+
+	// the api pushes commands
+	is.NoErr(conn.WriteJSON(Message{Type: TypeSpeedTest}))
+	is.Equal(TypeSpeedTest, (<-received).Type)
+	is.True(c.Connected())
+
+	// the client sends liveness
+	is.NoErr(c.Send(TypeLiveness, map[string]string{"hostId": "push-host"}))
+	is.NoErr(conn.ReadJSON(&m))
+	is.Equal(TypeLiveness, m.Type)
+
+	// a lost connection is reestablished
+	conn.Close()
+	conn = <-conns
+	is.NoErr(conn.ReadJSON(&m))
+	is.Equal(TypeHello, m.Type)
+
+	cancel()
+	<-stopped
+	is.True(!c.Connected())
+}
+
+func TestClientUnavailable(t *testing.T) {
+	is := is.New(t)
+
+	s := httptest.NewServer(http.NotFoundHandler())
+	defer s.Close()
+
+	attempts := 0
+	c := New(Options{
+		URL: s.URL,
+		Enabled: func() bool {
+			attempts++
+			return true
+		},
+		MinBackoff: 10 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	c.Run(ctx)
+
+	is.True(attempts > 1) // retried with backoff
+	is.True(!c.Connected())
+
+	err := c.serve(context.Background())
+	is.True(err != nil)
+	is.Equal("dial: websocket: bad handshake: 404 Not Found", err.Error())
+
+	var nilClient *Client
+	is.True(!nilClient.Connected())
+}
+
+func TestWSURL(t *testing.T) {
+	is := is.New(t)
+
+	is.Equal("wss://api.imup.io/v1/realtime/push", wsURL("https://api.imup.io/v1/realtime/push"))
+	is.Equal("ws://localhost:8080/push", wsURL("http://localhost:8080/push"))
+	is.Equal("ws://localhost:8080/push", wsURL("ws://localhost:8080/push"))
+}
+
+func TestMessage(t *testing.T) {
+	is := is.New(t)
+
+	m := Message{}
+	is.NoErr(json.Unmarshal([]byte(`{"type": "config", "data": {"config": {"version": "v2"}}}`), &m))
+	is.Equal(TypeConfig, m.Type)
+	is.Equal(`{"config": {"version": "v2"}}`, string(m.Data))
+}
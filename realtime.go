@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/imup-io/client/diagnostics"
+	"github.com/imup-io/client/push"
 	"github.com/imup-io/client/speedtesting"
 	log "golang.org/x/exp/slog"
 )
@@ -23,12 +25,16 @@ type realtimeApiPayload struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-func (i *imup) sendClientHealthy(ctx context.Context) error {
-	data := &realtimeApiPayload{
+// livenessPayload identifies the client and carries its health
+func (i *imup) livenessPayload() *realtimeApiPayload {
+	return &realtimeApiPayload{
 		ID: i.cfg.HostID(), Key: i.cfg.APIKey(), Email: i.cfg.EmailAddress(), GroupID: i.cfg.GroupID(),
+		Version: i.cfg.Version(), Data: i.health(),
 	}
+}
 
-	b, err := json.Marshal(data)
+func (i *imup) sendClientHealthy(ctx context.Context) error {
+	b, err := json.Marshal(i.livenessPayload())
 	if err != nil {
 		return fmt.Errorf("json.Marshal: %v", err)
 	}
@@ -36,6 +42,20 @@ func (i *imup) sendClientHealthy(ctx context.Context) error {
 	return sendRealtimeData(ctx, bytes.NewBuffer(b), i.cfg.LivenessCheckInURL())
 }
 
+// sendLiveness checks in over the push channel while it is connected and over http otherwise
+func (i *imup) sendLiveness(ctx context.Context) error {
+	if i.Push.Connected() {
+		err := i.Push.Send(push.TypeLiveness, i.livenessPayload())
+		if err == nil {
+			return nil
+		}
+
+		log.Debug("cannot check in over push channel", "error", err)
+	}
+
+	return i.sendClientHealthy(ctx)
+}
+
 func sendRealtimeData(ctx context.Context, b *bytes.Buffer, addr string) error {
 	req, err := retryablehttp.NewRequest("POST", addr, b)
 	req = req.WithContext(ctx)
@@ -191,7 +211,7 @@ func (i *imup) remoteConfigReload(ctx context.Context) error {
 		if data, err := io.ReadAll(resp.Body); err != nil {
 			return fmt.Errorf("cannot read raw remote config from api %s", err)
 		} else {
-			i.reloadConfig(data)
+			i.reloadConfig(ctx, data)
 		}
 	} else if retcode == http.StatusNoContent {
 		log.Debug("config has not changed")
@@ -201,3 +221,154 @@ func (i *imup) remoteConfigReload(ctx context.Context) error {
 
 	return nil
 }
+
+// postCommandResult posts the result of a diagnostic command
+func (i *imup) postCommandResult(ctx context.Context, result diagnostics.Result) error {
+	data := &realtimeApiPayload{
+		ID: i.cfg.HostID(), Key: i.cfg.APIKey(), Email: i.cfg.EmailAddress(), GroupID: i.cfg.GroupID(), Data: result,
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	return sendRealtimeData(ctx, bytes.NewBuffer(b), i.cfg.CommandResultsURL())
+}
+
+// acknowledgeConfig reports the version of the configuration applied by the client
+func (i *imup) acknowledgeConfig(ctx context.Context) error {
+	data := &realtimeApiPayload{
+		ID:      i.cfg.HostID(),
+		Email:   i.cfg.EmailAddress(),
+		GroupID: i.cfg.GroupID(),
+		Key:     i.cfg.APIKey(),
+		Version: i.cfg.Version(),
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	return sendRealtimeData(ctx, bytes.NewBuffer(b), i.cfg.RealtimeConfigAckURL())
+}
+
+// newPush returns a client for the push channel, while it is connected the api pushes
+// on demand speed tests and configuration changes instead of the client polling for them
+func (i *imup) newPush() *push.Client {
+	return push.New(push.Options{
+		URL:     i.cfg.PushURL(),
+		Enabled: func() bool { return i.cfg.Realtime() },
+		Hello: func() any {
+			// the config version lets the api push a configuration the client has not applied
+			return &realtimeApiPayload{
+				ID:      i.cfg.HostID(),
+				Email:   i.cfg.EmailAddress(),
+				GroupID: i.cfg.GroupID(),
+				Key:     i.cfg.APIKey(),
+				Version: i.cfg.Version(),
+			}
+		},
+		Handler: i.handlePush,
+	})
+}
+
+// handlePush acts on a message pushed by the api, work is done on its own goroutine
+// so that the push channel keeps reading
+func (i *imup) handlePush(ctx context.Context, m push.Message) {
+	switch m.Type {
+	case push.TypeSpeedTest:
+		go i.runOnDemandSpeedTest(ctx, context.Background())
+	case push.TypeConfig:
+		go i.reloadConfig(ctx, m.Data)
+	case push.TypeCommand:
+		go i.runDiagnosticCommand(ctx, m.Data)
+	default:
+		log.Debug("unknown push message", "type", m.Type)
+	}
+}
+
+// runOnDemandSpeedTest runs a speed test requested by the api and reports its progress,
+// statusCtx outlives ctx so that a failed test is reported during shutdown
+// pollOnDemandSpeedTest asks the api whether to run an on demand speed test and runs it,
+// unless the push channel delivers them
+func (i *imup) pollOnDemandSpeedTest(ctx, statusCtx context.Context) {
+	if i.Push.Connected() {
+		// a poll that failed before the push channel took over is over
+		i.Errors.resolve("ShouldRunSpeedtest")
+		return
+	}
+
+	if !i.cfg.Realtime() {
+		return
+	}
+
+	ok, err := i.shouldRunSpeedtest(ctx)
+	if err != nil {
+		log.Error("failed on-demand speed test check", "error", err)
+		i.Errors.write("ShouldRunSpeedtest", err)
+		return
+	}
+
+	i.Errors.resolve("ShouldRunSpeedtest")
+	if ok {
+		i.runOnDemandSpeedTest(ctx, statusCtx)
+	}
+}
+
+func (i *imup) runOnDemandSpeedTest(ctx, statusCtx context.Context) {
+	if !i.SpeedTestLock.TryLock() {
+		log.Info("on-demand speed test already running")
+		return
+	}
+	defer i.SpeedTestLock.Unlock()
+
+	// post on demand speed test status
+	if err := i.postSpeedTestRealtimeStatus(ctx, "running"); err != nil {
+		log.Error("failed to post realtime speedtest", "error", err)
+		i.Errors.write("PostSpeedTestStatus", err)
+	}
+
+	// run an on demand speed test
+	opts := i.throughTunnel(speedtesting.Options{
+		Insecure:      i.cfg.InsecureSpeedTests(),
+		OnDemand:      true,
+		ClientVersion: ClientVersion,
+	})
+	result, err := i.runSpeedTest(ctx, opts)
+	if err != nil {
+		// async post on demand speed test status
+		if err := i.postSpeedTestRealtimeStatus(statusCtx, "error"); err != nil {
+			log.Error("failed to update on-demand speed test status", "error", err)
+		}
+
+		log.Error("failed to run on-demand speed test", "error", err)
+		i.Errors.write("RunSpeedTestOnce", err)
+		return
+	}
+
+	i.recordSpeedTest(result)
+
+	// async post on demand speed test result
+	go func() {
+		if err := i.postSpeedTestRealtimeResults(statusCtx, "complete", result); err != nil {
+			log.Error("failed to update on-demand speed test status", "error", err)
+		}
+	}()
+
+	i.Errors.resolve("PostSpeedTestStatus")
+	i.Errors.resolve("RunSpeedTestOnce")
+
+	// enqueue a job
+	i.publish(sendDataJob{
+		IMUPAddress: i.cfg.PostSpeedTestData(),
+		IMUPData: &imupData{
+			Email:    i.cfg.EmailAddress(),
+			ID:       i.cfg.HostID(),
+			Key:      i.cfg.APIKey(),
+			GroupID:  i.cfg.GroupID(),
+			IMUPData: result,
+		},
+	})
+}
@@ -10,8 +10,13 @@ import (
 	"net/url"
 	"os"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/push"
 	"github.com/imup-io/client/speedtesting"
 	"github.com/matryer/is"
 )
@@ -248,3 +253,147 @@ func testRemoteUpdate(name, version string) func(t *testing.T) {
 		}
 	}
 }
+
+func TestPush(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+
+	conns := make(chan *websocket.Conn, 1)
+	upgrader := websocket.Upgrader{}
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !websocket.IsWebSocketUpgrade(r) {
+			w.WriteHeader(http.StatusOK) // config acknowledgement
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	defer s.Close()
+
+	os.Setenv("API_KEY", "push-api-key")
+	os.Setenv("HOST_ID", "push-host")
+	os.Setenv("IMUP_PUSH_ADDRESS", s.URL)
+	os.Setenv("IMUP_REALTIME_CONFIG_ACK", s.URL)
+	os.Setenv("IMUP_LIVENESS_CHECKIN_ADDRESS", s.URL)
+
+	imup := newApp()
+	imup.Push = imup.newPush()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go imup.Push.Run(ctx)
+
+	conn := <-conns
+	defer conn.Close()
+
+	m := push.Message{}
+	is.NoErr(conn.ReadJSON(&m))
+	is.Equal(push.TypeHello, m.Type)
+	hello := realtimeApiPayload{}
+	is.NoErr(json.Unmarshal(m.Data, &hello))
+	is.Equal("push-host", hello.ID)
+	is.Equal("dev-preview", hello.Version)
+
+	// liveness is sent over the push channel while it is connected
+	is.NoErr(imup.sendLiveness(ctx))
+	is.NoErr(conn.ReadJSON(&m))
+	is.Equal(push.TypeLiveness, m.Type)
+
+	// pushed configuration is applied
+	is.NoErr(conn.WriteJSON(push.Message{Type: push.TypeConfig, Data: json.RawMessage(`{"config": {"version": "push-v1", "realtimeEnabled": true}}`)}))
+	for deadline := time.Now().Add(5 * time.Second); imup.cfg.Version() != "push-v1"; {
+		is.True(time.Now().Before(deadline)) // configuration was not applied
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// without the push channel liveness is checked in over http
+	cancel()
+	for imup.Push.Connected() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	is.NoErr(imup.sendLiveness(context.Background()))
+}
+
+func TestLivenessPayload(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	testCache(t)
+
+	received := make(chan clientHealth, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := struct {
+			ID      string       `json:"hostId"`
+			Version string       `json:"version"`
+			Data    clientHealth `json:"data"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		if payload.ID != "liveness-host" || payload.Version != "dev-preview" {
+			t.Errorf("unexpected payload: %+v", payload)
+		}
+		received <- payload.Data
+	}))
+	defer s.Close()
+
+	os.Setenv("API_KEY", "liveness-api-key")
+	os.Setenv("HOST_ID", "liveness-host")
+	os.Setenv("IMUP_LIVENESS_CHECKIN_ADDRESS", s.URL)
+
+	imup := newApp()
+	imup.cfg.SetPublicIP("192.0.2.1")
+	imup.State.recordStatistics([]connectivity.Statistics{{EndpointType: "external", Success: false}})
+	toUserCache(sendDataJob{IMUPAddress: s.URL, IMUPData: imupData{ID: "liveness-host"}})
+
+	is.NoErr(imup.sendClientHealthy(context.Background()))
+	h := <-received
+
+	// the check in carries the same health as the status report
+	is.Equal(healthSchemaVersion, h.SchemaVersion)
+	is.Equal(ClientVersion, h.ClientVersion)
+	is.Equal("dev-preview", h.ConfigVersion)
+	is.Equal(verdictDown, h.Verdict)
+	is.Equal(1, h.CachedJobs)
+	is.Equal("192.0.2.1", h.PublicIP)
+	is.True(h.OutageStartedAt != nil)
+	is.True(h.LastSentAt == nil)
+	is.True(h.OS != "" && h.Arch != "")
+}
+
+func TestPollOnDemandSpeedTest(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+
+	fail := atomic.Bool{}
+	fail.Store(true)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.Write([]byte("not json"))
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":false}`))
+	}))
+	defer s.Close()
+
+	os.Setenv("API_KEY", "poll-api-key")
+	os.Setenv("HOST_ID", "poll-host")
+	os.Setenv("IMUP_SHOULD_RUN_SPEEDTEST_ADDRESS", s.URL)
+
+	imup := newApp()
+	imup.Errors = NewErrMap(make(channelReporter, 1))
+
+	imup.pollOnDemandSpeedTest(context.Background(), context.Background())
+	_, failing := imup.Errors.read("ShouldRunSpeedtest")
+	is.True(failing)
+
+	// a successful poll that runs no speed test recovers the operation
+	fail.Store(false)
+	imup.pollOnDemandSpeedTest(context.Background(), context.Background())
+	_, failing = imup.Errors.read("ShouldRunSpeedtest")
+	is.True(!failing)
+}
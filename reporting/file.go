@@ -0,0 +1,59 @@
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileReporter appends each report as a json line to a local file,
+// errors stay on the host for deployments that must not send them anywhere
+type fileReporter struct {
+	mu   sync.Mutex
+	path string
+	host Host
+}
+
+// NewFile returns a reporter that appends each report as a json line to path
+func NewFile(path string, host Host) ErrorReporter {
+	return &fileReporter{path: path, host: host}
+}
+
+// Name identifies the reporter in logs
+func (f *fileReporter) Name() string {
+	return "file"
+}
+
+// fileReport is a single line of the error file
+type fileReport struct {
+	Timestamp time.Time `json:"timestamp"`
+	Class     string    `json:"class"`
+	Message   string    `json:"message"`
+	Host      Host      `json:"host"`
+}
+
+// Report appends the error to the file
+func (f *fileReporter) Report(_ context.Context, class, message string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %v", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open file: %v", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(fileReport{Timestamp: time.Now(), Class: class, Message: message, Host: f.host}); err != nil {
+		return fmt.Errorf("cannot write report: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package reporting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/honeybadger-io/honeybadger-go"
+)
+
+// honeybadgerReporter notifies honeybadger.io with a client of its own,
+// the global honeybadger configuration is left untouched
+type honeybadgerReporter struct {
+	client *honeybadger.Client
+	host   Host
+}
+
+// NewHoneybadger returns a reporter that notifies honeybadger.io
+func NewHoneybadger(apiKey string, host Host) ErrorReporter {
+	return newHoneybadger(apiKey, "", host)
+}
+
+// newHoneybadger notifies endpoint instead of the honeybadger api when it is set
+func newHoneybadger(apiKey, endpoint string, host Host) *honeybadgerReporter {
+	return &honeybadgerReporter{
+		client: honeybadger.New(honeybadger.Configuration{
+			APIKey:   apiKey,
+			Endpoint: endpoint,
+			Env:      host.Version,
+			// earlier clients identified the host this way, keep it so existing faults group the same
+			Hostname: fmt.Sprintf("%s os: %s version: %s", host.ID, host.OS, host.Version),
+			// reports are already sent off the caller's goroutine, a synchronous client returns delivery errors
+			Sync: true,
+		}),
+		host: host,
+	}
+}
+
+// Name identifies the reporter in logs
+func (h *honeybadgerReporter) Name() string {
+	return "honeybadger"
+}
+
+// Report notifies honeybadger of the error with the host as context
+func (h *honeybadgerReporter) Report(_ context.Context, class, message string) error {
+	ctx := honeybadger.Context{}
+	for k, v := range h.host.tags() {
+		ctx[k] = v
+	}
+
+	_, err := h.client.Notify(errors.New(message), honeybadger.ErrorClass{Name: class}, ctx)
+	return err
+}
@@ -0,0 +1,151 @@
+package reporting
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Host identifies the client in every report, each reporter attaches it the same way
+type Host struct {
+	ID      string `json:"hostId"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	Version string `json:"version"`
+}
+
+// tags returns the host context as the key value pairs attached to a report
+func (h Host) tags() map[string]string {
+	return map[string]string{
+		"host_id": h.ID,
+		"os":      h.OS,
+		"arch":    h.Arch,
+		"version": h.Version,
+	}
+}
+
+// ErrorReporter delivers errors to whoever maintains the client.
+// class groups errors raised by the same operation, e.g. SendClientHealthy,
+// message must already be free of secrets.
+type ErrorReporter interface {
+	Name() string
+	Report(ctx context.Context, class, message string) error
+}
+
+// noop discards every report
+type noop struct{}
+
+// NewNoop returns a reporter that discards every report
+func NewNoop() ErrorReporter {
+	return noop{}
+}
+
+// Name identifies the reporter in logs
+func (noop) Name() string {
+	return "none"
+}
+
+// Report discards the error
+func (noop) Report(context.Context, string, string) error {
+	return nil
+}
+
+// LimitOptions controls how often errors are reported
+type LimitOptions struct {
+	// Burst is the number of reports sent per class and interval, 0 disables rate limiting
+	Burst int
+	// Interval is the period Burst applies to
+	Interval time.Duration
+	// DedupWindow is how long a report with the same dedup key is suppressed after it was sent,
+	// 0 disables deduplication
+	DedupWindow time.Duration
+	// DedupKey returns the key reports are deduplicated by, default is the class and message
+	DedupKey func(class, message string) string
+}
+
+// limited rate limits and deduplicates reports before they reach a reporter
+type limited struct {
+	mu       sync.Mutex
+	reporter ErrorReporter
+	opts     LimitOptions
+	now      func() time.Time
+
+	windows map[string]*window
+	sent    map[string]time.Time
+}
+
+// window counts the reports of a class since start
+type window struct {
+	start time.Time
+	count int
+}
+
+// NewLimited returns a reporter that sends at most opts.Burst reports per class and interval
+// to r, and drops a report with the dedup key of one sent within opts.DedupWindow
+func NewLimited(r ErrorReporter, opts LimitOptions) ErrorReporter {
+	if opts.DedupKey == nil {
+		opts.DedupKey = func(class, message string) string { return class + "\x00" + message }
+	}
+
+	return &limited{
+		reporter: r,
+		opts:     opts,
+		now:      time.Now,
+		windows:  map[string]*window{},
+		sent:     map[string]time.Time{},
+	}
+}
+
+// Name is the name of the wrapped reporter
+func (l *limited) Name() string {
+	return l.reporter.Name()
+}
+
+// Report sends the error unless it is rate limited or a duplicate, a dropped report is not an error
+func (l *limited) Report(ctx context.Context, class, message string) error {
+	if !l.allow(class, message) {
+		return nil
+	}
+
+	return l.reporter.Report(ctx, class, message)
+}
+
+func (l *limited) allow(class, message string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	key := l.opts.DedupKey(class, message)
+
+	if l.opts.DedupWindow > 0 {
+		// forget reports that can no longer suppress anything, the map stays small
+		for k, t := range l.sent {
+			if now.Sub(t) >= l.opts.DedupWindow {
+				delete(l.sent, k)
+			}
+		}
+
+		if _, ok := l.sent[key]; ok {
+			return false
+		}
+	}
+
+	if l.opts.Burst > 0 {
+		w, ok := l.windows[class]
+		if !ok || now.Sub(w.start) >= l.opts.Interval {
+			w = &window{start: now}
+			l.windows[class] = w
+		}
+
+		if w.count >= l.opts.Burst {
+			return false
+		}
+		w.count++
+	}
+
+	if l.opts.DedupWindow > 0 {
+		l.sent[key] = now
+	}
+
+	return true
+}
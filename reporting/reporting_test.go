@@ -0,0 +1,182 @@
+package reporting
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+var testHost = Host{ID: "host 1", OS: "linux", Arch: "amd64", Version: "v1.2.3"}
+
+type recordingReporter struct {
+	mu      sync.Mutex
+	reports []string
+}
+
+func (r *recordingReporter) Name() string { return "recording" }
+
+func (r *recordingReporter) Report(_ context.Context, class, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reports = append(r.reports, class+": "+message)
+	return nil
+}
+
+func TestLimited(t *testing.T) {
+	is := is.New(t)
+
+	r := &recordingReporter{}
+	l := NewLimited(r, LimitOptions{Burst: 2, Interval: time.Hour, DedupWindow: 24 * time.Hour}).(*limited)
+	is.Equal("recording", l.Name())
+
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "timeout"))
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "timeout")) // duplicate
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "connection refused"))
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "no route to host")) // over the burst
+	is.NoErr(l.Report(ctx, "RemoteConfigReload", "timeout"))         // classes are limited separately
+	is.Equal([]string{"SendClientHealthy: timeout", "SendClientHealthy: connection refused", "RemoteConfigReload: timeout"}, r.reports)
+
+	// a new interval allows new errors, duplicates stay suppressed for the dedup window
+	now = now.Add(time.Hour)
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "timeout"))
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "no route to host"))
+	is.Equal(4, len(r.reports))
+
+	now = now.Add(24 * time.Hour)
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "timeout"))
+	is.Equal("SendClientHealthy: timeout", r.reports[4])
+	is.Equal(1, len(l.sent)) // expired reports are forgotten
+}
+
+func TestLimitedDedupKey(t *testing.T) {
+	is := is.New(t)
+
+	r := &recordingReporter{}
+	l := NewLimited(r, LimitOptions{DedupWindow: time.Hour, DedupKey: func(class, _ string) string { return class }})
+
+	ctx := context.Background()
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "3 errors"))
+	is.NoErr(l.Report(ctx, "SendClientHealthy", "4 errors")) // same key
+	is.NoErr(l.Report(ctx, "RemoteConfigReload", "3 errors"))
+	is.Equal([]string{"SendClientHealthy: 3 errors", "RemoteConfigReload: 3 errors"}, r.reports)
+}
+
+func TestNoop(t *testing.T) {
+	is := is.New(t)
+
+	r := NewNoop()
+	is.Equal("none", r.Name())
+	is.NoErr(r.Report(context.Background(), "SendClientHealthy", "timeout"))
+}
+
+func TestFile(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "errors", "errors.log")
+	r := NewFile(path, testHost)
+	is.Equal("file", r.Name())
+
+	is.NoErr(r.Report(context.Background(), "SendClientHealthy", "timeout"))
+	is.NoErr(r.Report(context.Background(), "RemoteConfigReload", "bad config"))
+
+	f, err := os.Open(path)
+	is.NoErr(err)
+	defer f.Close()
+
+	reports := []fileReport{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		report := fileReport{}
+		is.NoErr(json.Unmarshal(s.Bytes(), &report))
+		reports = append(reports, report)
+	}
+
+	is.Equal(2, len(reports))
+	is.Equal("SendClientHealthy", reports[0].Class)
+	is.Equal("timeout", reports[0].Message)
+	is.Equal(testHost, reports[0].Host)
+	is.Equal("RemoteConfigReload", reports[1].Class)
+}
+
+func TestSentry(t *testing.T) {
+	is := is.New(t)
+
+	events := make(chan sentryEvent, 1)
+	var auth, path string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, path = r.Header.Get("X-Sentry-Auth"), r.URL.Path
+
+		e := sentryEvent{}
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		events <- e
+	}))
+	defer s.Close()
+
+	dsn := strings.Replace(s.URL, "http://", "http://public-key@", 1) + "/sentry/42"
+	r, err := NewSentry(dsn, testHost)
+	is.NoErr(err)
+	is.Equal("sentry", r.Name())
+
+	is.NoErr(r.Report(context.Background(), "SendClientHealthy", "timeout"))
+	e := <-events
+
+	is.Equal("/sentry/api/42/store/", path)
+	is.True(strings.Contains(auth, "sentry_key=public-key"))
+	is.Equal(32, len(e.EventID))
+	is.Equal("host 1", e.ServerName)
+	is.Equal("v1.2.3", e.Release)
+	is.Equal("linux", e.Tags["os"])
+	is.Equal("SendClientHealthy", e.Tags["class"])
+	is.Equal("timeout", e.Exception.Values[0].Value)
+
+	_, err = NewSentry("https://sentry.example.com/42", testHost)
+	is.True(err != nil) // no key
+	_, err = NewSentry("https://key@sentry.example.com", testHost)
+	is.True(err != nil) // no project
+}
+
+func TestHoneybadger(t *testing.T) {
+	is := is.New(t)
+
+	notices := make(chan map[string]any, 1)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		notice := map[string]any{}
+		if err := json.Unmarshal(b, &notice); err != nil {
+			t.Error(err)
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		notices <- notice
+	}))
+	defer s.Close()
+
+	r := newHoneybadger("badger-key", s.URL, testHost)
+	is.Equal("honeybadger", r.Name())
+	is.NoErr(r.Report(context.Background(), "SendClientHealthy", "timeout"))
+
+	notice := <-notices
+	b, err := json.Marshal(notice)
+	is.NoErr(err)
+	is.True(strings.Contains(string(b), `"class":"SendClientHealthy"`))
+	is.True(strings.Contains(string(b), `"host_id":"host 1"`))
+	is.True(strings.Contains(string(b), `"hostname":"host 1 os: linux version: v1.2.3"`))
+}
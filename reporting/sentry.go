@@ -0,0 +1,135 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// sentryTimeout bounds a single attempt to deliver an event
+const sentryTimeout = 30 * time.Second
+
+// sentryReporter sends events to the store endpoint of sentry or a compatible service such as glitchtip
+type sentryReporter struct {
+	store  string
+	auth   string
+	host   Host
+	client *http.Client
+}
+
+// NewSentry returns a reporter that sends events to the project identified by dsn,
+// e.g. https://<key>@sentry.example.com/<project>
+func NewSentry(dsn string, host Host) (ErrorReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("url.Parse: %v", err)
+	}
+
+	// the project id is the last element of the path, anything before it is a path prefix
+	prefix, project := "", strings.Trim(u.Path, "/")
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		prefix, project = "/"+project[:i], project[i+1:]
+	}
+
+	if u.Scheme == "" || u.Host == "" || u.User == nil || u.User.Username() == "" || project == "" {
+		return nil, fmt.Errorf("sentry dsn must be a url with a key and project, e.g. https://<key>@sentry.example.com/<project>")
+	}
+
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=imup/%s, sentry_key=%s", host.Version, u.User.Username())
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+
+	return &sentryReporter{
+		store:  fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, prefix, project),
+		auth:   auth,
+		host:   host,
+		client: &http.Client{Timeout: sentryTimeout},
+	}, nil
+}
+
+// Name identifies the reporter in logs
+func (s *sentryReporter) Name() string {
+	return "sentry"
+}
+
+// sentryEvent is the subset of the sentry event payload the client fills in
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	ServerName  string            `json:"server_name"`
+	Release     string            `json:"release"`
+	Tags        map[string]string `json:"tags"`
+	Fingerprint []string          `json:"fingerprint"`
+	Exception   sentryExceptions  `json:"exception"`
+}
+
+type sentryExceptions struct {
+	Values []sentryException `json:"values"`
+}
+
+type sentryException struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Report sends the error as an event tagged with the host
+func (s *sentryReporter) Report(ctx context.Context, class, message string) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("cannot generate event id: %v", err)
+	}
+
+	tags := s.host.tags()
+	tags["class"] = class
+
+	b, err := json.Marshal(sentryEvent{
+		EventID:    hex.EncodeToString(id),
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		Level:      "error",
+		Platform:   "go",
+		Logger:     "imup",
+		ServerName: s.host.ID,
+		Release:    s.host.Version,
+		Tags:       tags,
+		// group by operation like honeybadger error classes, messages often hold addresses and ports
+		Fingerprint: []string{class},
+		Exception:   sentryExceptions{Values: []sentryException{{Type: class, Value: message}}},
+	})
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.store, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("NewRequest: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client.Do: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
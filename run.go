@@ -6,56 +6,106 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/imup-io/client/config"
 	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/metrics"
+	"github.com/imup-io/client/push"
 	"github.com/imup-io/client/speedtesting"
-	"github.com/imup-io/client/util"
+	"github.com/imup-io/client/telemetry"
 	log "golang.org/x/exp/slog"
 )
 
 func run(ctx context.Context, shutdown chan os.Signal) error {
 	imup := newApp()
+	imup.openStore()
+	imup.persistPublicIP()
 
 	log.Info("Starting Client", "Version", ClientVersion)
-	imup.Errors = NewErrMap(imup.cfg.HostID())
-
-	log.Info("imup setup", "client", fmt.Sprintf("imup: %+v", imup))
-	log.Info("imup config", "config", fmt.Sprintf("config: %+v", imup.cfg))
+	imup.Errors = NewErrMap(newErrorReporter(imup.cfg))
+
+	log.Info("imup config", "config", imup.cfg.Redacted())
+
+	// optionally export traces and metrics to an otlp collector
+	shutdownTelemetry, err := telemetry.Setup(ctx, telemetry.Options{
+		Endpoint: imup.cfg.OTLPEndpoint(),
+		HostID:   imup.cfg.HostID(),
+		Version:  ClientVersion,
+	})
+	if err != nil {
+		return fmt.Errorf("telemetry.Setup: %v", err)
+	}
 
 	// define a context with cancel to coordinate shutdown behavior
 	cctx, cancel := context.WithCancel(ctx)
 
-	// sendDataWorker listens for imup data
-	go sendDataWorker(cctx, imup.ChannelImupData)
+	// the imup api and additional sinks each send data on their own queue,
+	// at shutdown their queues are drained before the client exits
+	sinksDone := make(chan struct{})
+	go func() {
+		defer close(sinksDone)
+		imup.Sinks.Run(cctx)
+	}()
+
+	metrics.SetQueueDepth(imup.queueDepth)
+
+	// optionally report client state and metrics on a local http listener
+	if imup.cfg.StatusServer() || imup.cfg.Metrics() {
+		go imup.serveStatus(cctx, imup.cfg.StatusAddress())
+	}
 
 	// check for and send data from local user cache
-	if cachedJobs, ok := fromCacheDir(); ok {
-		for _, job := range cachedJobs {
-			imup.ChannelImupData <- job
+	imup.sendCachedJobs()
+
+	// ======================================================================
+	// Network Changes
+	//
+	// watch for changes of the default route, gateway, interface and public ip, after a
+	// move to another network the gateway is discovered again and monitoring re-baselines
+
+	go imup.watchNetwork(cctx)
+
+	// ======================================================================
+	// Reopen Logs
+	//
+	// reopen the log file on SIGHUP so external tools such as logrotate can rotate it
+
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+
+		for {
+			select {
+			case <-hup:
+				if err := config.ReopenLogs(); err != nil {
+					log.Error("cannot reopen log file", "error", err)
+				} else {
+					log.Info("log file reopened")
+				}
+			case <-cctx.Done():
+				return
+			}
 		}
-		clearCache()
-	}
+	}()
 
 	// ======================================================================
-	// Refresh Public IP Address
+	// Refresh Secrets
 	//
-	// refresh public ip address every 1 minute if client has a defined allow or block list
+	// re-read secrets from files or a credential helper every 1 minute to pick up rotated values
 
 	go func() {
 		ticker := time.NewTicker((1 * time.Minute))
 		defer ticker.Stop()
 
 		for {
-			// only refresh a clients public ip address if configured to allow/block specific ips
-			if len(imup.cfg.AllowedIPs()) > 0 || len(imup.cfg.BlockedIPs()) > 0 {
-				imup.cfg.RefreshPublicIP()
-			}
-
 			select {
 			case <-ticker.C:
-				continue
+				imup.cfg.RefreshSecrets()
 			case <-cctx.Done():
 				return
 			}
@@ -94,8 +144,40 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 	// These functions should run on their own goroutines so
 	// as not to block each other
 
-	// remote configuration reload
 	wg := sync.WaitGroup{}
+
+	// push channel, while it is connected the api pushes on demand speed tests and
+	// configuration changes and the polling below is skipped
+	if imup.cfg.Push() {
+		imup.Push = imup.newPush()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			imup.Push.Run(cctx)
+		}()
+
+		// status is sent over the push channel every minute
+		go func() {
+			ticker := time.NewTicker(time.Minute)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					if imup.Push.Connected() {
+						if err := imup.Push.Send(push.TypeStatus, imup.status()); err != nil {
+							log.Debug("cannot send status over push channel", "error", err)
+						}
+					}
+				case <-cctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// remote configuration reload
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -103,13 +185,13 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 			ticker := time.NewTicker(time.Duration(1 * time.Hour))
 			defer ticker.Stop()
 			for {
-				if imup.cfg.Realtime() {
+				if imup.cfg.Realtime() && !imup.Push.Connected() {
 					// when api sends a new config, reload it
 					if err := imup.remoteConfigReload(cctx); err != nil {
 						log.Error("failed to reload config", "error", err)
 						imup.Errors.write("RemoteConfigReload", err)
 					} else {
-						imup.Errors.reportErrors("RemoteConfigReload")
+						imup.Errors.resolve("RemoteConfigReload")
 					}
 				}
 
@@ -135,11 +217,11 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 
 				if imup.cfg.Realtime() {
 					// liveness checkin
-					if err := imup.sendClientHealthy(cctx); err != nil {
+					if err := imup.sendLiveness(cctx); err != nil {
 						log.Error("failed liveness checkin", "error", err)
 						imup.Errors.write("SendClientHealthy", err)
 					} else {
-						imup.Errors.reportErrors("SendClientHealthy")
+						imup.Errors.resolve("SendClientHealthy")
 					}
 				}
 				select {
@@ -161,57 +243,7 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 			defer ticker.Stop()
 			for {
 
-				if imup.cfg.Realtime() {
-					if ok, err := imup.shouldRunSpeedtest(cctx); err != nil {
-						log.Error("failed on-demand speed test check", "error", err)
-						imup.Errors.write("ShouldRunSpeedtest", err)
-					} else if ok {
-						// post on demand speed test status
-						if err := imup.postSpeedTestRealtimeStatus(cctx, "running"); err != nil {
-							log.Error("failed to post realtime speedtest", "error", err)
-							imup.Errors.write("PostSpeedTestStatus", err)
-						}
-
-						// run an on demand speed test
-						opts := speedtesting.Options{
-							Insecure:      imup.cfg.InsecureSpeedTests(),
-							OnDemand:      true,
-							ClientVersion: ClientVersion,
-						}
-						if result, err := speedtesting.Run(cctx, opts); err != nil {
-							// async post on demand speed test status
-							if err := imup.postSpeedTestRealtimeStatus(ctx, "error"); err != nil {
-								log.Error("failed to update on-demand speed test status", "error", err)
-							}
-
-							log.Error("failed to run on-demand speed test", "error", err)
-							imup.Errors.write("RunSpeedTestOnce", err)
-						} else {
-							// async post on demand speed test result
-							go func() {
-								if err := imup.postSpeedTestRealtimeResults(ctx, "complete", result); err != nil {
-									log.Error("failed to update on-demand speed test status", "error", err)
-								}
-							}()
-
-							imup.Errors.reportErrors("ShouldRunSpeedtest")
-							imup.Errors.reportErrors("PostSpeedTestStatus")
-							imup.Errors.reportErrors("RunSpeedTestOnce")
-
-							// enqueue a job
-							imup.ChannelImupData <- sendDataJob{
-								IMUPAddress: imup.cfg.PostSpeedTestData(),
-								IMUPData: &imupData{
-									Email:    imup.cfg.EmailAddress(),
-									ID:       imup.cfg.HostID(),
-									Key:      imup.cfg.APIKey(),
-									GroupID:  imup.cfg.GroupID(),
-									IMUPData: result,
-								},
-							}
-						}
-					}
-				}
+				imup.pollOnDemandSpeedTest(cctx, ctx)
 
 				select {
 				case <-ticker.C:
@@ -229,26 +261,48 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 	// collects speed test data using the ndt7 protocol
 	// data is collected pseudo randomly, every 4 hours
 	go func() {
+		// a restart does not run a speed test sooner than it would have without one
+		if delay := imup.speedTestDelay(); delay > 0 {
+			log.Info("waiting for the next speed test", "delay", delay.Round(time.Second))
+			select {
+			case <-time.After(delay):
+			case <-cctx.Done():
+				return
+			}
+		}
+
 		ticker := time.NewTicker(speedTestInterval())
 		defer ticker.Stop()
 		for {
-			if imup.cfg.SpeedTests() {
-				monitoring := util.IPMonitored(imup.cfg.PublicIP(), imup.cfg.AllowedIPs(), imup.cfg.BlockedIPs())
+			if imup.speedTests() {
+				monitoring := imup.monitoring()
 
 				// extra check if ip based speed testing is configured
 				if monitoring {
-					opts := speedtesting.Options{
+					opts := imup.throughTunnel(speedtesting.Options{
 						Insecure:      imup.cfg.InsecureSpeedTests(),
 						OnDemand:      false,
 						ClientVersion: ClientVersion,
+					})
+
+					// optionally the test is repeated around a vpn to measure the isp
+					runs := []speedtesting.Options{opts}
+					if around, ok := imup.aroundTunnel(opts); ok {
+						runs = append(runs, around)
 					}
-					if result, err := speedtesting.Run(cctx, opts); err != nil {
-						log.Error("failed to run speed test", "error", err)
-						imup.Errors.write("CollectSpeedTestData", err)
-					} else {
-						go imup.Errors.reportErrors("CollectSpeedTestData")
+
+					for _, opts := range runs {
+						result, err := imup.runSpeedTest(cctx, opts)
+						if err != nil {
+							log.Error("failed to run speed test", "error", err)
+							imup.Errors.write("CollectSpeedTestData", err)
+							continue
+						}
+
+						imup.recordSpeedTest(result)
+						imup.Errors.resolve("CollectSpeedTestData")
 						// enqueue a job
-						imup.ChannelImupData <- sendDataJob{
+						imup.publish(sendDataJob{
 							IMUPAddress: imup.cfg.PostSpeedTestData(),
 							IMUPData: &imupData{
 								Email:    imup.cfg.EmailAddress(),
@@ -257,7 +311,7 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 								GroupID:  imup.cfg.GroupID(),
 								IMUPData: result,
 							},
-						}
+						})
 					}
 				}
 			}
@@ -289,34 +343,15 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 		defer wg.Done()
 
 		// initialize a collector
-		if imup.cfg.PingTests() {
-			collector = connectivity.NewPingCollector(connectivity.Options{
-				AddressInternal: imup.cfg.InternalPingAddress(),
-				ClientVersion:   ClientVersion,
-				Count:           imup.cfg.PingRequestsCount(),
-				Debug:           imup.cfg.Verbosity() == log.LevelDebug,
-				Delay:           time.Duration(imup.cfg.PingDelayMilli()) * time.Millisecond,
-				Interval:        time.Duration(imup.cfg.PingIntervalSeconds()) * time.Second,
-				Timeout:         time.Duration(imup.cfg.PingIntervalSeconds()) * time.Second,
-			})
-		} else {
-			collector = connectivity.NewDialerCollector(connectivity.Options{
-				ClientVersion: ClientVersion,
-				Count:         imup.cfg.ConnRequestsCount(),
-				Debug:         imup.cfg.Verbosity() == log.LevelDebug,
-				Delay:         time.Duration(imup.cfg.ConnDelayMilli()) * time.Millisecond,
-				Interval:      time.Duration(imup.cfg.ConnIntervalSeconds()) * time.Second,
-				Timeout:       time.Duration(imup.cfg.ConnIntervalSeconds()) * time.Second,
-			})
-		}
+		collector = imup.newCollector(imup.cfg.InternalPingAddress())
 
 		ticker := time.NewTicker(collector.Interval())
 		defer ticker.Stop()
 		for {
-			monitoring := util.IPMonitored(imup.cfg.PublicIP(), imup.cfg.AllowedIPs(), imup.cfg.BlockedIPs())
+			monitoring := imup.monitoring()
 			if monitoring {
 
-				collected := collector.Collect(cctx, imup.cfg.PingAddresses())
+				collected := imup.collect(cctx, collector)
 				data = append(data, collected...)
 				log.Debug("data points collected", "count", len(data))
 
@@ -339,20 +374,8 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 			if len(data) >= imup.cfg.IMUPDataLen() || firstTest {
 				firstTest = false
 
-				sc, dt := collector.DetectDowntime(data)
 				// enqueue a job
-				imup.ChannelImupData <- sendDataJob{
-					IMUPAddress: imup.cfg.PostConnectionData(),
-					IMUPData: imupData{
-						Downtime:      dt,
-						StatusChanged: sc,
-						Email:         imup.cfg.EmailAddress(),
-						ID:            imup.cfg.HostID(),
-						Key:           imup.cfg.APIKey(),
-						GroupID:       imup.cfg.GroupID(),
-						IMUPData:      data,
-					},
-				}
+				imup.publish(imup.connectivityJob(collector, data))
 				// reset connData slice
 				data = nil
 				if imup.cfg.StoreJobsOnDisk() {
@@ -363,22 +386,26 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 			select {
 			case <-ticker.C:
 				continue
+			case <-imup.rebaseline:
+				// statistics of the previous network are sent on their own and a new
+				// collector starts without the addresses avoided there
+				if len(data) > 0 {
+					imup.publish(imup.connectivityJob(collector, data))
+					data = nil
+					if imup.cfg.StoreJobsOnDisk() {
+						clearCache()
+					}
+				}
+
+				imup.resetAvoidAddresses()
+				collector = imup.newCollector(imup.cfg.InternalPingAddress())
+				ticker.Reset(collector.Interval())
+				continue
 			case <-cctx.Done():
 				log.Debug("data points to persist?", "data > 0", len(data) > 0)
 				if len(data) > 0 {
-					sc, dt := collector.DetectDowntime(data)
 					log.Debug("persisting pending conn data")
-					toUserCache(sendDataJob{
-						IMUPAddress: imup.cfg.PostConnectionData(),
-						IMUPData: imupData{
-							Downtime:      dt,
-							StatusChanged: sc,
-							Email:         imup.cfg.EmailAddress(),
-							ID:            imup.cfg.HostID(),
-							Key:           imup.cfg.APIKey(),
-							GroupID:       imup.cfg.GroupID(),
-							IMUPData:      data,
-						}})
+					toUserCache(imup.connectivityJob(collector, data))
 				}
 			}
 			return
@@ -390,6 +417,14 @@ func run(ctx context.Context, shutdown chan os.Signal) error {
 	log.Info("shutdown started", "signal", sig)
 	cancel()
 	wg.Wait()
+	<-sinksDone
+
+	// flush any pending spans and metrics
+	tctx, tcancel := context.WithTimeout(ctx, 5*time.Second)
+	defer tcancel()
+	if err := shutdownTelemetry(tctx); err != nil {
+		log.Error("failed to flush telemetry", "error", err)
+	}
 	defer log.Info("shutdown completed", "signal", sig)
 
 	return nil
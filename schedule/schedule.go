@@ -0,0 +1,109 @@
+// Package schedule decides when monitoring is active and when the network is under
+// planned maintenance, from weekly windows and one-off windows in a timezone.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	// the timezone database is embedded for hosts without one, e.g. windows
+	_ "time/tzdata"
+)
+
+// Schedule is a compiled monitoring schedule, the zero value and nil are always active
+// and never under maintenance
+type Schedule struct {
+	loc         *time.Location
+	active      []window
+	maintenance []window
+}
+
+// window is a period of time
+type window interface {
+	contains(t time.Time) bool
+}
+
+// New compiles a schedule. Active windows are when monitoring runs, every time when
+// there are none, and maintenance windows are when the network is under planned
+// maintenance. A window is weekly or one-off:
+//
+//	mon-fri 08:00-18:00            weekdays during business hours
+//	sat+sun                        all day on weekends
+//	22:00-06:00                    every night, a window may end on the next day
+//	2026-11-02T01:00/2026-11-02T05:00  once, an offset such as Z or -05:00 is optional
+//
+// Times are in timezone, an IANA name such as America/New_York, or in the local
+// timezone of the host when it is empty.
+func New(active, maintenance []string, timezone string) (*Schedule, error) {
+	loc := time.Local
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("unknown timezone %q: %v", timezone, err)
+		}
+	}
+
+	s := &Schedule{loc: loc}
+	for _, entries := range []struct {
+		entries []string
+		windows *[]window
+	}{{active, &s.active}, {maintenance, &s.maintenance}} {
+		for _, e := range entries.entries {
+			if e = strings.TrimSpace(e); e == "" {
+				continue
+			}
+
+			w, err := parseWindow(e, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid window %q: %v", e, err)
+			}
+			*entries.windows = append(*entries.windows, w)
+		}
+	}
+
+	return s, nil
+}
+
+// Active reports whether monitoring runs at t
+func (s *Schedule) Active(t time.Time) bool {
+	if s == nil || len(s.active) == 0 {
+		return true
+	}
+
+	return s.in(s.active, t)
+}
+
+// Maintenance reports whether t is in a maintenance window
+func (s *Schedule) Maintenance(t time.Time) bool {
+	if s == nil {
+		return false
+	}
+
+	return s.in(s.maintenance, t)
+}
+
+// Empty reports whether no windows are configured
+func (s *Schedule) Empty() bool {
+	return s == nil || len(s.active) == 0 && len(s.maintenance) == 0
+}
+
+func (s *Schedule) in(windows []window, t time.Time) bool {
+	t = t.In(s.loc)
+	for _, w := range windows {
+		if w.contains(t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseWindow parses a weekly or one-off window
+func parseWindow(entry string, loc *time.Location) (window, error) {
+	if from, to, ok := strings.Cut(entry, "/"); ok {
+		return parseOnce(from, to, loc)
+	}
+
+	return parseWeekly(entry)
+}
@@ -0,0 +1,125 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestActive(t *testing.T) {
+	is := is.New(t)
+
+	s, err := New([]string{"mon-fri 08:00-18:00", "sat+sun 10:00-12:00"}, nil, "America/New_York")
+	is.NoErr(err)
+
+	loc, _ := time.LoadLocation("America/New_York")
+	at := func(value string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+		is.NoErr(err)
+		return t
+	}
+
+	// 2026-10-19 is a monday
+	is.True(s.Active(at("2026-10-19 08:00")))
+	is.True(s.Active(at("2026-10-23 17:59")))
+	is.True(!s.Active(at("2026-10-19 18:00")))
+	is.True(!s.Active(at("2026-10-19 07:59")))
+	is.True(s.Active(at("2026-10-24 11:00")))
+	is.True(!s.Active(at("2026-10-24 12:00")))
+
+	// times are compared in the timezone of the schedule
+	is.True(s.Active(at("2026-10-19 12:00").UTC()))
+	is.True(!s.Active(time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC)))
+
+	// no windows are always active and never under maintenance
+	var none *Schedule
+	is.True(none.Active(time.Now()))
+	is.True(!none.Maintenance(time.Now()))
+	is.True(none.Empty())
+
+	s, err = New(nil, nil, "")
+	is.NoErr(err)
+	is.True(s.Active(time.Now()))
+	is.True(s.Empty())
+}
+
+func TestOvernight(t *testing.T) {
+	is := is.New(t)
+
+	s, err := New([]string{"fri 22:00-06:00"}, []string{"weekends"}, "UTC")
+	is.NoErr(err)
+
+	is.True(!s.Active(time.Date(2026, 10, 23, 21, 59, 0, 0, time.UTC)))
+	is.True(s.Active(time.Date(2026, 10, 23, 22, 0, 0, 0, time.UTC)))
+	is.True(s.Active(time.Date(2026, 10, 24, 5, 59, 0, 0, time.UTC))) // saturday morning
+	is.True(!s.Active(time.Date(2026, 10, 24, 22, 0, 0, 0, time.UTC)))
+	is.True(!s.Active(time.Date(2026, 10, 23, 5, 0, 0, 0, time.UTC))) // thursday night
+
+	is.True(s.Maintenance(time.Date(2026, 10, 25, 23, 59, 0, 0, time.UTC)))
+	is.True(!s.Maintenance(time.Date(2026, 10, 26, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestDaylightSaving(t *testing.T) {
+	is := is.New(t)
+
+	s, err := New([]string{"sun 08:00-18:00"}, nil, "America/New_York")
+	is.NoErr(err)
+
+	// clocks move forward on 2026-03-08 and back on 2026-11-01, both sundays
+	is.True(!s.Active(time.Date(2026, 3, 8, 11, 59, 0, 0, time.UTC))) // 07:59 edt
+	is.True(s.Active(time.Date(2026, 3, 8, 12, 0, 0, 0, time.UTC)))   // 08:00 edt
+	is.True(s.Active(time.Date(2026, 3, 8, 21, 59, 0, 0, time.UTC)))  // 17:59 edt
+	is.True(!s.Active(time.Date(2026, 3, 8, 22, 0, 0, 0, time.UTC)))  // 18:00 edt
+
+	is.True(!s.Active(time.Date(2026, 11, 1, 12, 59, 0, 0, time.UTC))) // 07:59 est
+	is.True(s.Active(time.Date(2026, 11, 1, 13, 0, 0, 0, time.UTC)))   // 08:00 est
+	is.True(s.Active(time.Date(2026, 11, 1, 22, 59, 0, 0, time.UTC)))  // 17:59 est
+	is.True(!s.Active(time.Date(2026, 11, 1, 23, 0, 0, 0, time.UTC)))  // 18:00 est
+}
+
+func TestMaintenance(t *testing.T) {
+	is := is.New(t)
+
+	s, err := New(nil, []string{"2026-11-02T01:00/2026-11-02T05:00", "2026-12-01T00:00:00Z/2026-12-01T02:00:00Z", "sun 02:00-03:00"}, "America/Chicago")
+	is.NoErr(err)
+	is.True(!s.Empty())
+
+	// 01:00 in chicago is 07:00 utc in november
+	is.True(!s.Maintenance(time.Date(2026, 11, 2, 6, 59, 0, 0, time.UTC)))
+	is.True(s.Maintenance(time.Date(2026, 11, 2, 7, 0, 0, 0, time.UTC)))
+	is.True(s.Maintenance(time.Date(2026, 11, 2, 10, 59, 0, 0, time.UTC)))
+	is.True(!s.Maintenance(time.Date(2026, 11, 2, 11, 0, 0, 0, time.UTC)))
+
+	// an offset takes precedence over the timezone
+	is.True(s.Maintenance(time.Date(2026, 12, 1, 1, 0, 0, 0, time.UTC)))
+
+	// recurring maintenance
+	is.True(s.Maintenance(time.Date(2026, 10, 25, 7, 30, 0, 0, time.UTC)))
+
+	// maintenance does not pause monitoring
+	is.True(s.Active(time.Date(2026, 11, 2, 8, 0, 0, 0, time.UTC)))
+}
+
+func TestInvalid(t *testing.T) {
+	is := is.New(t)
+
+	for _, entry := range []string{
+		"someday",
+		"mon-fri 8-18",
+		"mon 25:00-26:00",
+		"mon 08:00-08:00",
+		"mon 08:00-10:00 12:00-14:00",
+		"2026-11-02T05:00/2026-11-02T01:00",
+		"2026-11-02T01:00/tomorrow",
+	} {
+		_, err := New([]string{entry}, nil, "")
+		is.True(err != nil) // invalid window
+	}
+
+	_, err := New(nil, nil, "Mars/Olympus_Mons")
+	is.True(err != nil) // unknown timezone
+
+	_, err = New([]string{"Monday 08:00-24:00", "tues-thurs", " "}, nil, "")
+	is.NoErr(err)
+}
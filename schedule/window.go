@@ -0,0 +1,183 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const day = 24 * time.Hour
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// weekly is a window on days of the week, a window that ends before it starts ends on
+// the next day
+type weekly struct {
+	days     [7]bool
+	from, to time.Duration
+}
+
+// parseWeekly parses [days] [hh:mm-hh:mm], days are names or ranges of names separated
+// by + or space, e.g. mon-fri, sat+sun or daily
+func parseWeekly(entry string) (window, error) {
+	w := weekly{to: day}
+	days := []string{}
+	hours := ""
+	for _, field := range strings.Fields(strings.ToLower(entry)) {
+		if strings.Contains(field, ":") {
+			if hours != "" {
+				return nil, errors.New("more than one time range")
+			}
+			hours = field
+			continue
+		}
+		days = append(days, strings.Split(field, "+")...)
+	}
+
+	if len(days) == 0 {
+		days = []string{"daily"}
+	}
+
+	for _, d := range days {
+		if err := w.addDays(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if hours != "" {
+		from, to, ok := strings.Cut(hours, "-")
+		if !ok {
+			return nil, fmt.Errorf("time range %q is not hh:mm-hh:mm", hours)
+		}
+
+		var err error
+		if w.from, err = parseClock(from); err != nil {
+			return nil, err
+		}
+		if w.to, err = parseClock(to); err != nil {
+			return nil, err
+		}
+		if w.from == w.to {
+			return nil, fmt.Errorf("time range %q is empty", hours)
+		}
+	}
+
+	return w, nil
+}
+
+// addDays adds a day, a range of days such as fri-mon, daily or weekdays
+func (w *weekly) addDays(days string) error {
+	switch days {
+	case "":
+		return nil
+	case "daily", "*":
+		days = "sun-sat"
+	case "weekdays":
+		days = "mon-fri"
+	case "weekends":
+		days = "sat-sun"
+	}
+
+	first, last, _ := strings.Cut(days, "-")
+	if last == "" {
+		last = first
+	}
+
+	from, err := weekday(first)
+	if err != nil {
+		return err
+	}
+	to, err := weekday(last)
+	if err != nil {
+		return err
+	}
+
+	for d := from; ; d = (d + 1) % 7 {
+		w.days[d] = true
+		if d == to {
+			return nil
+		}
+	}
+}
+
+// weekday parses a day by its name or the first three letters of it
+func weekday(name string) (time.Weekday, error) {
+	if len(name) >= 3 {
+		if d, ok := weekdays[name[:3]]; ok && strings.HasPrefix(strings.ToLower(d.String()), name) {
+			return d, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown day %q", name)
+}
+
+// parseClock parses hh:mm, 24:00 is the end of the day
+func parseClock(s string) (time.Duration, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || n != 2 || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("time %q is not hh:mm", s)
+	}
+
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+func (w weekly) contains(t time.Time) bool {
+	// the time on the clock, a day with a daylight saving change is not 24 hours long
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	if w.from < w.to {
+		return w.days[t.Weekday()] && clock >= w.from && clock < w.to
+	}
+
+	// a window over midnight belongs to the day it starts on
+	yesterday := (t.Weekday() + 6) % 7
+	return w.days[t.Weekday()] && clock >= w.from || w.days[yesterday] && clock < w.to
+}
+
+// once is a one-off window, it ends before to
+type once struct {
+	from, to time.Time
+}
+
+// layouts of one-off windows without an offset, they are in the schedule's timezone
+var layouts = []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"}
+
+func parseOnce(from, to string, loc *time.Location) (window, error) {
+	var w once
+	var err error
+	if w.from, err = parseTime(from, loc); err != nil {
+		return nil, err
+	}
+	if w.to, err = parseTime(to, loc); err != nil {
+		return nil, err
+	}
+	if !w.from.Before(w.to) {
+		return nil, errors.New("window ends before it starts")
+	}
+
+	return w, nil
+}
+
+func parseTime(s string, loc *time.Location) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("time %q is not in the format 2006-01-02T15:04", s)
+}
+
+func (w once) contains(t time.Time) bool {
+	return !t.Before(w.from) && t.Before(w.to)
+}
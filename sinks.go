@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/sinks"
+	"github.com/imup-io/client/speedtesting"
+	log "golang.org/x/exp/slog"
+)
+
+// queue options of each sink, every sink gets its own queue
+var (
+	// the imup api retries a failed send for about 3 weeks, together with the retries of a
+	// single send, records it cannot send or queue are persisted to the user cache
+	apiSinkQueue  = sinks.QueueOptions{Size: 300, Retries: 4000, RetryWait: 30 * time.Second}
+	httpSinkQueue = sinks.QueueOptions{Size: 100, Retries: 5, RetryWait: 30 * time.Second}
+	fileSinkQueue = sinks.QueueOptions{Size: 100, Retries: 1, RetryWait: time.Second}
+	// a local broker is usually reachable when the internet is not, retry often while it reconnects
+	mqttSinkQueue = sinks.QueueOptions{Size: 300, Retries: 10, RetryWait: 5 * time.Second}
+)
+
+// newDispatcher configures every enabled sink
+func (i *imup) newDispatcher() *sinks.Dispatcher {
+	d := sinks.NewDispatcher()
+	cfg := i.cfg
+
+	if cfg.APISink() {
+		opts := apiSinkQueue
+		opts.Persist = func(r sinks.Record) { toUserCache(i.apiJob(r)) }
+		i.api = d.Add(&apiSink{i}, opts)
+	}
+
+	if url := cfg.WebhookURL(); url != "" {
+		d.Add(sinks.NewWebhook(url), httpSinkQueue)
+	}
+
+	if url := cfg.InfluxDBURL(); url != "" {
+		d.Add(sinks.NewInfluxDB(url, cfg.InfluxDBToken), httpSinkQueue)
+	}
+
+	if path := cfg.SinkFile(); path != "" {
+		d.Add(sinks.NewFile(path), fileSinkQueue)
+	}
+
+	if broker := cfg.MQTTBroker(); broker != "" {
+		d.Add(sinks.NewMQTT(sinks.MQTTOptions{
+			Broker:      broker,
+			ClientID:    cfg.MQTTClientID(),
+			Username:    cfg.MQTTUsername(),
+			Password:    cfg.MQTTPassword,
+			HostID:      cfg.HostID(),
+			TopicPrefix: cfg.MQTTTopicPrefix(),
+			QoS:         cfg.MQTTQoS(),
+		}), mqttSinkQueue)
+	}
+
+	return d
+}
+
+// publish fans a job out to every configured sink
+func (i *imup) publish(job sendDataJob) {
+	if i.Sinks == nil || i.Sinks.Len() == 0 {
+		return
+	}
+
+	if r, ok := job.record(); ok {
+		i.Sinks.Publish(r)
+	} else {
+		log.Debug("job cannot be sent to sinks", "address", job.IMUPAddress)
+	}
+}
+
+// record converts a job into a sink record
+func (j sendDataJob) record() (sinks.Record, bool) {
+	var data *imupData
+	switch d := j.IMUPData.(type) {
+	case imupData:
+		data = &d
+	case *imupData:
+		data = d
+	default:
+		return sinks.Record{}, false
+	}
+
+	r := sinks.Record{
+		HostID:        data.ID,
+		GroupID:       data.GroupID,
+		Timestamp:     time.Now(),
+		Downtime:      data.Downtime,
+		StatusChanged: data.StatusChanged,
+	}
+
+	switch d := data.IMUPData.(type) {
+	case []connectivity.Statistics:
+		r.Kind = sinks.KindConnectivity
+		r.Statistics = d
+	case *speedtesting.SpeedTestResult:
+		r.Kind = sinks.KindSpeedTest
+		r.SpeedTest = d
+	default:
+		return sinks.Record{}, false
+	}
+
+	return r, true
+}
+
+// cachedRecord converts a job restored from the user cache, whose data is untyped, into a record
+func (j sendDataJob) cachedRecord() (sinks.Record, bool) {
+	if r, ok := j.record(); ok {
+		return r, true
+	}
+
+	b, err := json.Marshal(j.IMUPData)
+	if err != nil {
+		return sinks.Record{}, false
+	}
+
+	// json decodes into the typed value the interface points to
+	data := imupData{}
+	switch jobType(j.IMUPAddress) {
+	case string(sinks.KindConnectivity):
+		data.IMUPData = &[]connectivity.Statistics{}
+	case string(sinks.KindSpeedTest):
+		data.IMUPData = &speedtesting.SpeedTestResult{}
+	default:
+		return sinks.Record{}, false
+	}
+
+	if err := json.Unmarshal(b, &data); err != nil {
+		return sinks.Record{}, false
+	}
+
+	if stats, ok := data.IMUPData.(*[]connectivity.Statistics); ok {
+		data.IMUPData = *stats
+	}
+
+	return sendDataJob{IMUPAddress: j.IMUPAddress, IMUPData: data}.record()
+}
+
+// apiSink sends records to the imup api with the credentials in effect when they are sent
+type apiSink struct {
+	i *imup
+}
+
+func (a *apiSink) Name() string { return "imup" }
+
+func (a *apiSink) Send(ctx context.Context, r sinks.Record) error {
+	if err := sendImupData(ctx, a.i.apiJob(r)); err != nil {
+		return err
+	}
+
+	a.i.State.recordSent()
+	return nil
+}
+
+// apiJob converts a record back into a job for the imup api
+func (i *imup) apiJob(r sinks.Record) sendDataJob {
+	data := &imupData{
+		Downtime:      r.Downtime,
+		StatusChanged: r.StatusChanged,
+		Email:         i.cfg.EmailAddress(),
+		ID:            r.HostID,
+		Key:           i.cfg.APIKey(),
+		GroupID:       r.GroupID,
+	}
+
+	if r.Kind == sinks.KindSpeedTest {
+		data.IMUPData = r.SpeedTest
+		return sendDataJob{IMUPAddress: i.cfg.PostSpeedTestData(), IMUPData: data}
+	}
+
+	data.IMUPData = r.Statistics
+	return sendDataJob{IMUPAddress: i.cfg.PostConnectionData(), IMUPData: data}
+}
+
+// queueDepth is the number of records waiting to be sent to the imup api
+func (i *imup) queueDepth() int {
+	if i.api == nil {
+		return 0
+	}
+
+	return i.api.Len()
+}
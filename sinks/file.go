@@ -0,0 +1,48 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fileSink appends each record as a json line to a local file
+type fileSink struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFile returns a sink that appends each record as a json line to path
+func NewFile(path string) Sink {
+	return &fileSink{path: path}
+}
+
+// Name identifies the sink in logs
+func (f *fileSink) Name() string {
+	return "file"
+}
+
+// Send appends a record to the file
+func (f *fileSink) Send(_ context.Context, r Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return fmt.Errorf("cannot create directory: %v", err)
+	}
+
+	file, err := os.OpenFile(f.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open file: %v", err)
+	}
+	defer file.Close()
+
+	if err := json.NewEncoder(file).Encode(r); err != nil {
+		return fmt.Errorf("cannot write record: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// httpTimeout bounds a single attempt to deliver a record over http
+const httpTimeout = 30 * time.Second
+
+// webhookSink posts each record as json to a url
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook returns a sink that posts each record as json to url
+func NewWebhook(url string) Sink {
+	return &webhookSink{url: url, client: &http.Client{Timeout: httpTimeout}}
+}
+
+// Name identifies the sink in logs
+func (w *webhookSink) Name() string {
+	return "webhook"
+}
+
+// Send posts a record to the webhook
+func (w *webhookSink) Send(ctx context.Context, r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	return post(ctx, w.client, w.url, "application/json", b, nil)
+}
+
+// post delivers a payload and treats any non 2xx response as a failure
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("NewRequest: %v", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client.Do: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// drain the body so the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+	}
+
+	return nil
+}
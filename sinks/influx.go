@@ -0,0 +1,174 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// influxSink writes records to an influxdb http write endpoint using line protocol
+type influxSink struct {
+	url    string
+	token  func() string
+	client *http.Client
+}
+
+// NewInfluxDB returns a sink that writes line protocol to an influxdb write url,
+// e.g. http://localhost:8086/api/v2/write?org=imup&bucket=imup&precision=ns.
+// When token returns a value it is sent as an influxdb token authorization header,
+// it is called for every write so that a rotated token is used.
+func NewInfluxDB(url string, token func() string) Sink {
+	return &influxSink{url: url, token: token, client: &http.Client{Timeout: httpTimeout}}
+}
+
+// Name identifies the sink in logs
+func (i *influxSink) Name() string {
+	return "influxdb"
+}
+
+// Send writes a record as line protocol
+func (i *influxSink) Send(ctx context.Context, r Record) error {
+	lines := LineProtocol(r)
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var headers map[string]string
+	if token := i.token(); token != "" {
+		headers = map[string]string{"Authorization": "Token " + token}
+	}
+
+	return post(ctx, i.client, i.url, "text/plain; charset=utf-8", []byte(strings.Join(lines, "\n")+"\n"), headers)
+}
+
+// LineProtocol converts a record into influxdb line protocol, one line per measurement
+func LineProtocol(r Record) []string {
+	lines := []string{}
+	tags := map[string]string{"host": r.HostID}
+	if r.GroupID != "" {
+		tags["group"] = r.GroupID
+	}
+
+	switch r.Kind {
+	case KindConnectivity:
+		for _, s := range r.Statistics {
+			t := copyTags(tags)
+			t["address"] = s.PingAddress
+			t["endpoint_type"] = s.EndpointType
+			if s.Tunnel != "" {
+				t["tunnel"] = s.Tunnel
+			}
+			if s.Maintenance {
+				t["maintenance"] = "true"
+			}
+
+			lines = append(lines, line("imup_connectivity", t, map[string]any{
+				"success":          s.Success,
+				"success_internal": s.SuccessInternal,
+				"packets_sent":     s.PacketsSent,
+				"packets_recv":     s.PacketsRecv,
+				"packet_loss":      s.PacketLoss,
+				"min_rtt_ms":       s.MinRtt.Seconds() * 1000,
+				"avg_rtt_ms":       s.AvgRtt.Seconds() * 1000,
+				"max_rtt_ms":       s.MaxRtt.Seconds() * 1000,
+				"stddev_rtt_ms":    s.StdDevRtt.Seconds() * 1000,
+			}, s.TimeStamp))
+		}
+
+		lines = append(lines, line("imup_downtime", tags, map[string]any{
+			"downtime":       r.Downtime,
+			"status_changed": r.StatusChanged,
+		}, r.Timestamp.UnixNano()))
+
+	case KindSpeedTest:
+		if r.SpeedTest == nil {
+			break
+		}
+
+		t := copyTags(tags)
+		t["server"] = r.SpeedTest.TestServer
+		if r.SpeedTest.Tunnel != "" {
+			t["tunnel"] = r.SpeedTest.Tunnel
+			t["tunnel_bypassed"] = strconv.FormatBool(r.SpeedTest.TunnelBypassed)
+		}
+		if r.SpeedTest.Maintenance {
+			t["maintenance"] = "true"
+		}
+
+		lines = append(lines, line("imup_speedtest", t, map[string]any{
+			"download_mbps":    r.SpeedTest.DownloadMbps,
+			"download_retrans": r.SpeedTest.DownloadRetrans,
+			"download_min_rtt": r.SpeedTest.DownloadMinRtt,
+			"upload_mbps":      r.SpeedTest.UploadMbps,
+			"upload_retrans":   r.SpeedTest.UploadRetrans,
+			"upload_min_rtt":   r.SpeedTest.UploadMinRTT,
+		}, r.SpeedTest.TimeStampFinish))
+	}
+
+	return lines
+}
+
+func line(measurement string, tags map[string]string, fields map[string]any, timestamp int64) string {
+	b := strings.Builder{}
+	b.WriteString(escape(measurement, ", "))
+
+	for _, k := range sortedKeys(tags) {
+		if tags[k] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", escape(k, ",= "), escape(tags[k], ",= "))
+	}
+
+	for n, k := range sortedKeys(fields) {
+		sep := ","
+		if n == 0 {
+			sep = " "
+		}
+
+		switch v := fields[k].(type) {
+		case int:
+			fmt.Fprintf(&b, "%s%s=%di", sep, escape(k, ",= "), v)
+		case bool:
+			fmt.Fprintf(&b, "%s%s=%t", sep, escape(k, ",= "), v)
+		default:
+			fmt.Fprintf(&b, "%s%s=%v", sep, escape(k, ",= "), v)
+		}
+	}
+
+	if timestamp > 0 {
+		fmt.Fprintf(&b, " %d", timestamp)
+	}
+
+	return b.String()
+}
+
+// escape prefixes any of chars in s with a backslash
+func escape(s, chars string) string {
+	for _, c := range chars {
+		s = strings.ReplaceAll(s, string(c), `\`+string(c))
+	}
+
+	return s
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	c := make(map[string]string, len(tags))
+	for k, v := range tags {
+		c[k] = v
+	}
+
+	return c
+}
@@ -0,0 +1,204 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	log "golang.org/x/exp/slog"
+)
+
+// mqttTimeout bounds connecting to a broker and waiting for a publish to be acknowledged
+const mqttTimeout = 10 * time.Second
+
+// mqttQuiesce is the time in milliseconds pending work is given to complete on disconnect
+const mqttQuiesce = 250
+
+// host status published as a retained message
+const (
+	statusUp      = "up"
+	statusDown    = "down"
+	statusOffline = "offline"
+)
+
+// MQTTOptions configures a connection to an mqtt broker
+type MQTTOptions struct {
+	// Broker is the url of the broker, e.g. tcp://localhost:1883
+	Broker   string
+	ClientID string
+	Username string
+	// Password is called on every connect so that a rotated password is used
+	Password func() string
+
+	// HostID is used in every topic so that a broker can serve many hosts
+	HostID string
+	// TopicPrefix is the first level of every topic, default is imup
+	TopicPrefix string
+	// QoS is the mqtt quality of service used for every message
+	QoS byte
+}
+
+// mqttStatus is the retained last known status of a host and the payload of outage transitions
+type mqttStatus struct {
+	HostID    string    `json:"hostId"`
+	Status    string    `json:"status"`
+	Since     time.Time `json:"since,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// mqttSink publishes records to an mqtt broker
+//
+// topics, relative to <prefix>/<host id>:
+//
+//	connectivity  every connectivity batch
+//	speedtest     every speed test result
+//	outage        a transition between up and down
+//	status        retained last known status of the host
+type mqttSink struct {
+	mu     sync.Mutex
+	client mqtt.Client
+	opts   MQTTOptions
+
+	status string
+	since  time.Time
+}
+
+// NewMQTT returns a sink that publishes records to an mqtt broker. The client
+// reconnects on its own, records sent while disconnected fail and are retried by the sinks queue.
+// Close publishes the host as offline and disconnects, the broker only publishes the will
+// of the client when the connection is lost.
+func NewMQTT(opts MQTTOptions) Sink {
+	if opts.TopicPrefix == "" {
+		opts.TopicPrefix = "imup"
+	}
+
+	if opts.ClientID == "" {
+		opts.ClientID = fmt.Sprintf("imup-%s", opts.HostID)
+	}
+
+	m := &mqttSink{opts: opts}
+
+	offline, _ := json.Marshal(mqttStatus{HostID: opts.HostID, Status: statusOffline})
+
+	co := mqtt.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetCredentialsProvider(func() (string, string) {
+			if opts.Password == nil {
+				return opts.Username, ""
+			}
+			return opts.Username, opts.Password()
+		}).
+		SetConnectTimeout(mqttTimeout).
+		SetConnectRetry(true).
+		SetAutoReconnect(true).
+		SetBinaryWill(m.topic("status"), offline, opts.QoS, true).
+		SetOnConnectHandler(func(mqtt.Client) {
+			log.Info("connected to mqtt broker", "broker", opts.Broker)
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Warn("lost connection to mqtt broker", "broker", opts.Broker, "error", err)
+		})
+
+	m.client = mqtt.NewClient(co)
+
+	// with connect retry enabled this returns immediately and keeps trying in the background
+	m.client.Connect()
+
+	return m
+}
+
+// Name identifies the sink in logs
+func (m *mqttSink) Name() string {
+	return "mqtt"
+}
+
+// Send publishes a record and for connectivity records the hosts status
+func (m *mqttSink) Send(ctx context.Context, r Record) error {
+	if !m.client.IsConnectionOpen() {
+		return fmt.Errorf("not connected to mqtt broker %s", m.opts.Broker)
+	}
+
+	switch r.Kind {
+	case KindConnectivity:
+		if err := m.publish(ctx, "connectivity", r, false); err != nil {
+			return err
+		}
+
+		return m.publishStatus(ctx, r)
+	case KindSpeedTest:
+		return m.publish(ctx, "speedtest", r, false)
+	}
+
+	return nil
+}
+
+// publishStatus updates the retained host status and publishes outage transitions
+func (m *mqttSink) publishStatus(ctx context.Context, r Record) error {
+	status := statusDown
+	for _, s := range r.Statistics {
+		if s.EndpointType != "internal" && s.Success {
+			status = statusUp
+			break
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := mqttStatus{HostID: m.opts.HostID, Status: status, Since: m.since, Timestamp: r.Timestamp}
+	if status != m.status {
+		current.Since = r.Timestamp
+
+		// a host that starts up connected has not recovered from an outage
+		if m.status != "" || status == statusDown {
+			if err := m.publish(ctx, "outage", current, false); err != nil {
+				return err
+			}
+		}
+
+		// only remember a transition once it has been published, so a retry publishes it again
+		m.status, m.since = status, current.Since
+	}
+
+	return m.publish(ctx, "status", current, true)
+}
+
+// Close publishes the retained offline status and disconnects from the broker
+func (m *mqttSink) Close() error {
+	defer m.client.Disconnect(mqttQuiesce)
+
+	if !m.client.IsConnectionOpen() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), mqttTimeout)
+	defer cancel()
+
+	return m.publish(ctx, "status", mqttStatus{HostID: m.opts.HostID, Status: statusOffline, Timestamp: time.Now()}, true)
+}
+
+func (m *mqttSink) publish(ctx context.Context, subtopic string, v any, retained bool) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	token := m.client.Publish(m.topic(subtopic), m.opts.QoS, retained, b)
+
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(mqttTimeout):
+		return fmt.Errorf("timed out publishing to %s", m.topic(subtopic))
+	}
+}
+
+func (m *mqttSink) topic(subtopic string) string {
+	return fmt.Sprintf("%s/%s/%s", m.opts.TopicPrefix, m.opts.HostID, subtopic)
+}
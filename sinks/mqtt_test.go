@@ -0,0 +1,224 @@
+package sinks_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/sinks"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/matryer/is"
+)
+
+type message struct {
+	topic    string
+	payload  []byte
+	retained bool
+}
+
+// broker is a minimal in-process mqtt 3.1.1 broker that records published messages
+type broker struct {
+	ln           net.Listener
+	mu           sync.Mutex
+	messages     []message
+	disconnected bool
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &broker{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+
+	return b
+}
+
+func (b *broker) url() string {
+	return "tcp://" + b.ln.Addr().String()
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+
+		length, err := remainingLength(r)
+		if err != nil {
+			return
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+
+		switch header >> 4 {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH
+			qos := (header >> 1) & 0x03
+			n := int(binary.BigEndian.Uint16(body))
+			m := message{topic: string(body[2 : 2+n]), retained: header&0x01 == 1}
+			body = body[2+n:]
+
+			if qos > 0 {
+				conn.Write([]byte{0x40, 0x02, body[0], body[1]})
+				body = body[2:]
+			}
+
+			m.payload = body
+			b.mu.Lock()
+			b.messages = append(b.messages, m)
+			b.mu.Unlock()
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0x00})
+		case 14: // DISCONNECT
+			b.mu.Lock()
+			b.disconnected = true
+			b.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (b *broker) published(topic string) []message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var messages []message
+	for _, m := range b.messages {
+		if m.topic == topic {
+			messages = append(messages, m)
+		}
+	}
+
+	return messages
+}
+
+func (b *broker) isDisconnected() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.disconnected
+}
+
+func remainingLength(r *bufio.Reader) (int, error) {
+	length, multiplier := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			return length, nil
+		}
+		multiplier *= 128
+	}
+}
+
+func TestMQTT(t *testing.T) {
+	is := is.New(t)
+	b := newBroker(t)
+
+	sink := sinks.NewMQTT(sinks.MQTTOptions{Broker: b.url(), HostID: "host1", QoS: 1})
+	is.Equal("mqtt", sink.Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	send := func(r sinks.Record) {
+		// the client connects in the background
+		for {
+			err := sink.Send(ctx, r)
+			if err == nil {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				t.Fatal(err)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	down := connectivityRecord
+	down.Statistics = []connectivity.Statistics{
+		{PingAddress: "1.1.1.1", EndpointType: "external", Success: false},
+		{PingAddress: "192.168.1.1", EndpointType: "internal", Success: true},
+	}
+
+	send(connectivityRecord)
+	send(down)
+	send(connectivityRecord)
+	send(sinks.Record{Kind: sinks.KindSpeedTest, HostID: "host1", SpeedTest: &speedtesting.SpeedTestResult{DownloadMbps: 100}})
+
+	is.Equal(3, len(b.published("imup/host1/connectivity")))
+	is.Equal(1, len(b.published("imup/host1/speedtest")))
+
+	status := b.published("imup/host1/status")
+	is.Equal(3, len(status))
+	is.True(status[2].retained)
+
+	// starting up connected is not an outage transition
+	outages := b.published("imup/host1/outage")
+	is.Equal(2, len(outages))
+
+	var outage struct {
+		HostID string    `json:"hostId"`
+		Status string    `json:"status"`
+		Since  time.Time `json:"since"`
+	}
+	is.NoErr(json.Unmarshal(outages[0].payload, &outage))
+	is.Equal("host1", outage.HostID)
+	is.Equal("down", outage.Status)
+	is.True(!outages[0].retained)
+
+	is.NoErr(json.Unmarshal(outages[1].payload, &outage))
+	is.Equal("up", outage.Status)
+
+	// a clean shutdown publishes the offline status itself, the will is only sent on a lost connection
+	closer, ok := sink.(sinks.Closer)
+	is.True(ok)
+	is.NoErr(closer.Close())
+
+	status = b.published("imup/host1/status")
+	is.Equal(4, len(status))
+	is.True(status[3].retained)
+	is.NoErr(json.Unmarshal(status[3].payload, &outage))
+	is.Equal("offline", outage.Status)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !b.isDisconnected() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	is.True(b.isDisconnected())
+}
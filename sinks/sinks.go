@@ -0,0 +1,250 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/speedtesting"
+	log "golang.org/x/exp/slog"
+)
+
+// Kind identifies the type of measurement held by a Record
+type Kind string
+
+const (
+	KindConnectivity Kind = "connectivity"
+	KindSpeedTest    Kind = "speedtest"
+)
+
+// Record is a single batch of measurements, it intentionally omits
+// api keys and email addresses so it can be shared with third parties
+type Record struct {
+	Kind          Kind                          `json:"kind"`
+	HostID        string                        `json:"hostId"`
+	GroupID       string                        `json:"groupId,omitempty"`
+	Timestamp     time.Time                     `json:"timestamp"`
+	Downtime      int                           `json:"downtime,omitempty"`
+	StatusChanged bool                          `json:"statusChanged,omitempty"`
+	Statistics    []connectivity.Statistics     `json:"statistics,omitempty"`
+	SpeedTest     *speedtesting.SpeedTestResult `json:"speedTest,omitempty"`
+}
+
+// Sink is a destination for measurement data
+type Sink interface {
+	Name() string
+	Send(context.Context, Record) error
+}
+
+// Closer is implemented by sinks that hold a connection, Close is called once
+// at shutdown after the queue of the sink is drained
+type Closer interface {
+	Close() error
+}
+
+// QueueOptions controls how records are buffered and retried for a single sink
+type QueueOptions struct {
+	// Size is the number of records buffered before new records are dropped
+	Size int
+	// Retries is the number of additional attempts after a failed send
+	Retries int
+	// RetryWait is the time to wait between attempts
+	RetryWait time.Duration
+	// Persist keeps the records that cannot be sent now, e.g. in an offline cache: records
+	// that do not fit in a full queue, records the sink gave up on and records still queued
+	// at shutdown. Without it the first two are dropped and the last get one last attempt.
+	Persist func(Record)
+}
+
+// DrainTimeout bounds the last attempt to send queued records at shutdown
+var DrainTimeout = 5 * time.Second
+
+// Queue buffers records for a single sink and sends them on its own goroutine,
+// a slow or unavailable sink never blocks another sink
+type Queue struct {
+	sink    Sink
+	opts    QueueOptions
+	records chan Record
+}
+
+// NewQueue returns a queue for sink, Run must be called to start sending records
+func NewQueue(sink Sink, opts QueueOptions) *Queue {
+	if opts.Size < 1 {
+		opts.Size = 1
+	}
+
+	return &Queue{sink: sink, opts: opts, records: make(chan Record, opts.Size)}
+}
+
+// Enqueue adds a record to the queue without blocking and reports if it was accepted,
+// a record that does not fit is persisted when the queue has a Persist func
+func (q *Queue) Enqueue(r Record) bool {
+	select {
+	case q.records <- r:
+		return true
+	default:
+	}
+
+	if q.opts.Persist != nil {
+		log.Warn("sink queue is full, persisting record", "sink", q.sink.Name(), "kind", r.Kind)
+		q.opts.Persist(r)
+	} else {
+		log.Warn("sink queue is full, dropping record", "sink", q.sink.Name(), "kind", r.Kind)
+	}
+
+	return false
+}
+
+// Cap is the number of records the queue buffers
+func (q *Queue) Cap() int {
+	return cap(q.records)
+}
+
+// Len is the number of records waiting to be sent
+func (q *Queue) Len() int {
+	return len(q.records)
+}
+
+// Run sends queued records until ctx is canceled, then drains the queue and closes the sink
+func (q *Queue) Run(ctx context.Context) {
+	defer q.close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			q.drain()
+			return
+		case r := <-q.records:
+			err := q.send(ctx, r)
+			if err != nil && ctx.Err() != nil {
+				// the send was interrupted by shutdown, the record is still pending
+				q.drain(r)
+				return
+			} else if err != nil {
+				log.Error("failed to send record to sink", "sink", q.sink.Name(), "kind", r.Kind, "error", err)
+				if q.opts.Persist != nil {
+					q.opts.Persist(r)
+				}
+			}
+		}
+	}
+}
+
+// drain empties the queue at shutdown, records are persisted when the queue has
+// a Persist func and otherwise sent once more within DrainTimeout
+func (q *Queue) drain(pending ...Record) {
+	for len(q.records) > 0 {
+		pending = append(pending, <-q.records)
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	log.Info("shutdown detected, draining sink queue", "sink", q.sink.Name(), "records", len(pending))
+	if q.opts.Persist != nil {
+		for _, r := range pending {
+			q.opts.Persist(r)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DrainTimeout)
+	defer cancel()
+
+	for n, r := range pending {
+		if err := q.sink.Send(ctx, r); err != nil {
+			log.Error("failed to send record to sink at shutdown", "sink", q.sink.Name(), "kind", r.Kind, "error", err)
+			if ctx.Err() != nil {
+				log.Warn("dropping records queued at shutdown", "sink", q.sink.Name(), "records", len(pending)-n-1)
+				return
+			}
+		}
+	}
+}
+
+func (q *Queue) close() {
+	if c, ok := q.sink.(Closer); ok {
+		if err := c.Close(); err != nil {
+			log.Error("failed to close sink", "sink", q.sink.Name(), "error", err)
+		}
+	}
+}
+
+func (q *Queue) send(ctx context.Context, r Record) error {
+	var err error
+	for attempt := 0; attempt <= q.opts.Retries; attempt++ {
+		if attempt > 0 {
+			log.Debug("retrying sink", "sink", q.sink.Name(), "attempt", attempt, "error", err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(q.opts.RetryWait):
+			}
+		}
+
+		if err = q.sink.Send(ctx, r); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %v", q.opts.Retries+1, err)
+}
+
+// Dispatcher fans records out to every configured sink
+type Dispatcher struct {
+	mu     sync.RWMutex
+	queues []*Queue
+}
+
+// NewDispatcher returns a dispatcher with no sinks
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{}
+}
+
+// Add registers a sink with its own queue and returns the queue, records enqueued
+// on it directly are sent to that sink alone
+func (d *Dispatcher) Add(sink Sink, opts QueueOptions) *Queue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q := NewQueue(sink, opts)
+	d.queues = append(d.queues, q)
+	return q
+}
+
+// Len is the number of configured sinks
+func (d *Dispatcher) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return len(d.queues)
+}
+
+// Publish enqueues a record on every sink
+func (d *Dispatcher) Publish(r Record) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	for _, q := range d.queues {
+		q.Enqueue(r)
+	}
+}
+
+// Run starts a worker for every sink and blocks until ctx is canceled and every queue is drained
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.mu.RLock()
+	wg := sync.WaitGroup{}
+	for _, q := range d.queues {
+		wg.Add(1)
+		go func(q *Queue) {
+			defer wg.Done()
+			q.Run(ctx)
+		}(q)
+	}
+	d.mu.RUnlock()
+
+	wg.Wait()
+}
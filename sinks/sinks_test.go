@@ -0,0 +1,260 @@
+package sinks_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/sinks"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/matryer/is"
+)
+
+var connectivityRecord = sinks.Record{
+	Kind:      sinks.KindConnectivity,
+	HostID:    "host 1",
+	GroupID:   "group",
+	Timestamp: time.Unix(0, 2000),
+	Downtime:  1,
+	Statistics: []connectivity.Statistics{
+		{PingAddress: "1.1.1.1", EndpointType: "external", Success: true, PacketsSent: 10, PacketsRecv: 10, AvgRtt: 15 * time.Millisecond, TimeStamp: 1000},
+	},
+}
+
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	records  []sinks.Record
+}
+
+func (f *flakySink) Name() string { return "flaky" }
+
+func (f *flakySink) Send(_ context.Context, r sinks.Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("unavailable")
+	}
+
+	f.records = append(f.records, r)
+	return nil
+}
+
+func (f *flakySink) sent() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.records)
+}
+
+func TestDispatcher(t *testing.T) {
+	is := is.New(t)
+
+	ok := &flakySink{}
+	flaky := &flakySink{failures: 2}
+
+	d := sinks.NewDispatcher()
+	d.Add(ok, sinks.QueueOptions{Size: 10})
+	d.Add(flaky, sinks.QueueOptions{Size: 10, Retries: 2, RetryWait: time.Millisecond})
+	is.Equal(2, d.Len())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		d.Run(ctx)
+		close(done)
+	}()
+
+	d.Publish(connectivityRecord)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for (ok.sent() < 1 || flaky.sent() < 1) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	<-done
+
+	is.Equal(1, ok.sent())
+	is.Equal(1, flaky.sent())
+}
+
+func TestQueueFull(t *testing.T) {
+	is := is.New(t)
+
+	q := sinks.NewQueue(&flakySink{}, sinks.QueueOptions{Size: 1})
+	is.True(q.Enqueue(connectivityRecord))
+	is.True(!q.Enqueue(connectivityRecord))
+	is.Equal(1, q.Len())
+	is.Equal(1, q.Cap())
+
+	// with a persist func a record that does not fit is kept
+	persisted := 0
+	q = sinks.NewQueue(&flakySink{}, sinks.QueueOptions{Size: 1, Persist: func(sinks.Record) { persisted++ }})
+	is.True(q.Enqueue(connectivityRecord))
+	is.True(!q.Enqueue(connectivityRecord))
+	is.Equal(1, persisted)
+}
+
+func TestQueueGiveUp(t *testing.T) {
+	is := is.New(t)
+
+	persisted := make(chan sinks.Record, 1)
+	failing := &flakySink{failures: 2}
+	q := sinks.NewQueue(failing, sinks.QueueOptions{Size: 1, Retries: 1, RetryWait: time.Millisecond, Persist: func(r sinks.Record) {
+		persisted <- r
+	}})
+	is.True(q.Enqueue(connectivityRecord))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go q.Run(ctx)
+
+	// a record the sink gave up on is persisted instead of dropped
+	select {
+	case r := <-persisted:
+		is.Equal(connectivityRecord.HostID, r.HostID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("record was not persisted")
+	}
+	is.Equal(0, failing.sent())
+}
+
+func TestQueueDrain(t *testing.T) {
+	is := is.New(t)
+
+	// without a persist func queued records get one last attempt
+	flushed := &flakySink{}
+	q := sinks.NewQueue(flushed, sinks.QueueOptions{Size: 10})
+	is.True(q.Enqueue(connectivityRecord))
+	is.True(q.Enqueue(connectivityRecord))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q.Run(ctx)
+	is.Equal(2, flushed.sent())
+	is.Equal(0, q.Len())
+
+	// with one they are persisted, including a record whose retries were interrupted
+	persisted := []sinks.Record{}
+	failing := &flakySink{failures: 100}
+	q = sinks.NewQueue(failing, sinks.QueueOptions{Size: 10, Retries: 100, RetryWait: time.Hour, Persist: func(r sinks.Record) {
+		persisted = append(persisted, r)
+	}})
+	is.True(q.Enqueue(connectivityRecord))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	is.True(q.Enqueue(connectivityRecord))
+
+	cancel()
+	<-done
+	is.Equal(2, len(persisted))
+	is.Equal(0, failing.sent())
+}
+
+func TestLineProtocol(t *testing.T) {
+	is := is.New(t)
+
+	lines := sinks.LineProtocol(connectivityRecord)
+	is.Equal(2, len(lines))
+	is.Equal(`imup_connectivity,address=1.1.1.1,endpoint_type=external,group=group,host=host\ 1 avg_rtt_ms=15,max_rtt_ms=0,min_rtt_ms=0,packet_loss=0,packets_recv=10i,packets_sent=10i,stddev_rtt_ms=0,success=true,success_internal=false 1000`, lines[0])
+	is.Equal(`imup_downtime,group=group,host=host\ 1 downtime=1i,status_changed=false 2000`, lines[1])
+
+	speed := sinks.LineProtocol(sinks.Record{
+		Kind:      sinks.KindSpeedTest,
+		HostID:    "host",
+		SpeedTest: &speedtesting.SpeedTestResult{DownloadMbps: 100.5, UploadMbps: 10, TestServer: "ndt.example.com", TimeStampFinish: 3000},
+	})
+	is.Equal(1, len(speed))
+	is.Equal(`imup_speedtest,host=host,server=ndt.example.com download_mbps=100.5,download_min_rtt=0,download_retrans=0,upload_mbps=10,upload_min_rtt=0,upload_retrans=0 3000`, speed[0])
+
+	// results through or around a vpn are tagged with it
+	tunneled := sinks.LineProtocol(sinks.Record{
+		Kind:      sinks.KindSpeedTest,
+		HostID:    "host",
+		SpeedTest: &speedtesting.SpeedTestResult{DownloadMbps: 100.5, TestServer: "ndt.example.com", Tunnel: "wg0", TunnelBypassed: true, TimeStampFinish: 3000},
+	})
+	is.True(strings.HasPrefix(tunneled[0], `imup_speedtest,host=host,server=ndt.example.com,tunnel=wg0,tunnel_bypassed=true `))
+
+	// as are results during isp maintenance
+	maintenance := sinks.LineProtocol(sinks.Record{
+		Kind:       sinks.KindConnectivity,
+		HostID:     "host",
+		Statistics: []connectivity.Statistics{{PingAddress: "1.1.1.1", EndpointType: "external", Maintenance: true, TimeStamp: 1000}},
+	})
+	is.True(strings.HasPrefix(maintenance[0], `imup_connectivity,address=1.1.1.1,endpoint_type=external,host=host,maintenance=true `))
+}
+
+func TestHTTPSinks(t *testing.T) {
+	is := is.New(t)
+
+	var body, auth, contentType string
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body, auth, contentType = string(b), r.Header.Get("Authorization"), r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer s.Close()
+
+	is.NoErr(sinks.NewWebhook(s.URL).Send(context.Background(), connectivityRecord))
+	is.Equal("application/json", contentType)
+	received := sinks.Record{}
+	is.NoErr(json.Unmarshal([]byte(body), &received))
+	is.Equal("host 1", received.HostID)
+	is.Equal(1, len(received.Statistics))
+
+	is.NoErr(sinks.NewInfluxDB(s.URL, func() string { return "token" }).Send(context.Background(), connectivityRecord))
+	is.Equal("Token token", auth)
+	is.True(strings.HasPrefix(body, "imup_connectivity,"))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	is.True(sinks.NewWebhook(failing.URL).Send(context.Background(), connectivityRecord) != nil)
+}
+
+func TestFileSink(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "data", "imup.jsonl")
+	f := sinks.NewFile(path)
+	is.NoErr(f.Send(context.Background(), connectivityRecord))
+	is.NoErr(f.Send(context.Background(), connectivityRecord))
+
+	file, err := os.Open(path)
+	is.NoErr(err)
+	defer file.Close()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		r := sinks.Record{}
+		is.NoErr(json.Unmarshal(scanner.Bytes(), &r))
+		is.Equal(sinks.KindConnectivity, r.Kind)
+		lines++
+	}
+	is.Equal(2, lines)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/sinks"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/matryer/is"
+)
+
+func TestSendDataJobRecord(t *testing.T) {
+	is := is.New(t)
+
+	conn := sendDataJob{IMUPData: imupData{ID: "host", Key: "secret", Downtime: 2, IMUPData: []connectivity.Statistics{{PingAddress: "1.1.1.1"}}}}
+	r, ok := conn.record()
+	is.True(ok)
+	is.Equal(sinks.KindConnectivity, r.Kind)
+	is.Equal("host", r.HostID)
+	is.Equal(2, r.Downtime)
+	is.Equal(1, len(r.Statistics))
+
+	speed := sendDataJob{IMUPData: &imupData{ID: "host", IMUPData: &speedtesting.SpeedTestResult{DownloadMbps: 10}}}
+	r, ok = speed.record()
+	is.True(ok)
+	is.Equal(sinks.KindSpeedTest, r.Kind)
+	is.Equal(10.0, r.SpeedTest.DownloadMbps)
+
+	// jobs restored from the user cache are not typed
+	_, ok = sendDataJob{IMUPData: map[string]any{}}.record()
+	is.True(!ok)
+}
+
+func TestCachedRecord(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "cached-api-key")
+	os.Setenv("EMAIL", "cached@example.com")
+	os.Setenv("HOST_ID", "cached-host")
+
+	imup := newApp()
+	is.True(imup.api != nil)
+
+	// a job written to the user cache is read back untyped
+	decode := func(job sendDataJob) sendDataJob {
+		b, err := json.Marshal(job)
+		is.NoErr(err)
+		cached := sendDataJob{}
+		is.NoErr(json.Unmarshal(b, &cached))
+		return cached
+	}
+
+	conn := imup.apiJob(sinks.Record{
+		Kind:       sinks.KindConnectivity,
+		HostID:     "cached-host",
+		Downtime:   3,
+		Statistics: []connectivity.Statistics{{PingAddress: "1.1.1.1", Success: true}},
+	})
+	is.Equal(imup.cfg.PostConnectionData(), conn.IMUPAddress)
+	is.Equal("cached-api-key", conn.IMUPData.(*imupData).Key)
+
+	r, ok := decode(conn).cachedRecord()
+	is.True(ok)
+	is.Equal(sinks.KindConnectivity, r.Kind)
+	is.Equal(3, r.Downtime)
+	is.Equal("1.1.1.1", r.Statistics[0].PingAddress)
+
+	speed := imup.apiJob(sinks.Record{Kind: sinks.KindSpeedTest, HostID: "cached-host", SpeedTest: &speedtesting.SpeedTestResult{DownloadMbps: 42}})
+	is.Equal(imup.cfg.PostSpeedTestData(), speed.IMUPAddress)
+
+	r, ok = decode(speed).cachedRecord()
+	is.True(ok)
+	is.Equal(sinks.KindSpeedTest, r.Kind)
+	is.Equal(42.0, r.SpeedTest.DownloadMbps)
+
+	_, ok = sendDataJob{IMUPAddress: "https://example.com/v1/unknown", IMUPData: map[string]any{}}.cachedRecord()
+	is.True(!ok)
+}
+
+func TestAPISinkQueueFull(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	testCache(t)
+	os.Setenv("API_KEY", "overflow-api-key")
+	os.Setenv("HOST_ID", "overflow-host")
+
+	imup := newApp()
+	r := sinks.Record{Kind: sinks.KindConnectivity, HostID: "overflow-host", Statistics: []connectivity.Statistics{{PingAddress: "1.1.1.1"}}}
+	for n := 0; n < imup.api.Cap(); n++ {
+		is.True(imup.api.Enqueue(r))
+	}
+
+	// a record for the imup api is never dropped, it waits in the user cache for the next start
+	is.True(!imup.api.Enqueue(r))
+	is.Equal(1, cachedJobs())
+
+	entries, err := readCache()
+	is.NoErr(err)
+	is.Equal("connectivity", entries[0].Type)
+}
@@ -0,0 +1,33 @@
+package speedtesting
+
+import (
+	"net"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindDialer returns a dialer whose connections leave through iface whatever the
+// routing table says, e.g. around a vpn that routes the internet through itself
+func bindDialer(iface string) *net.Dialer {
+	return &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			ifi, err := net.InterfaceByName(iface)
+			if err != nil {
+				return err
+			}
+
+			if cerr := c.Control(func(fd uintptr) {
+				if strings.HasSuffix(network, "6") {
+					err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_BOUND_IF, ifi.Index)
+				} else {
+					err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_BOUND_IF, ifi.Index)
+				}
+			}); cerr != nil {
+				return cerr
+			}
+			return err
+		},
+	}
+}
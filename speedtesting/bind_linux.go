@@ -0,0 +1,22 @@
+package speedtesting
+
+import (
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// bindDialer returns a dialer whose connections leave through iface whatever the
+// routing table says, e.g. around a vpn that routes the internet through itself
+func bindDialer(iface string) *net.Dialer {
+	return &net.Dialer{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) { err = unix.BindToDevice(int(fd), iface) }); cerr != nil {
+				return cerr
+			}
+			return err
+		},
+	}
+}
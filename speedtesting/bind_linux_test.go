@@ -0,0 +1,23 @@
+package speedtesting
+
+import (
+	"net"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestBindDialer(t *testing.T) {
+	is := is.New(t)
+
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	is.NoErr(err)
+	defer l.Close()
+
+	conn, err := bindDialer("lo").Dial("tcp4", l.Addr().String())
+	is.NoErr(err)
+	conn.Close()
+
+	_, err = bindDialer("nonexistent0").Dial("tcp4", l.Addr().String())
+	is.True(err != nil) // no such interface
+}
@@ -0,0 +1,36 @@
+//go:build !linux && !darwin
+
+package speedtesting
+
+import (
+	"net"
+
+	log "golang.org/x/exp/slog"
+)
+
+// bindDialer returns a dialer whose connections are sent from the ipv4 address of iface,
+// which routes them through iface on platforms that choose the interface by source address
+func bindDialer(iface string) *net.Dialer {
+	d := &net.Dialer{}
+
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		log.Warn("cannot bind speed test to interface", "interface", iface, "error", err)
+		return d
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		log.Warn("cannot bind speed test to interface", "interface", iface, "error", err)
+		return d
+	}
+
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			d.LocalAddr = &net.TCPAddr{IP: ipNet.IP}
+			break
+		}
+	}
+
+	return d
+}
@@ -35,6 +35,14 @@ type SpeedTestResult struct {
 	ClientVersion string            `json:"clientVersion,omitempty"`
 	OS            string            `json:"operatingSystem,omitempty"`
 	TestServer    string            `json:"testServer,omitempty"`
+
+	// Tunnel is the vpn or tunnel the host was routed through during the test,
+	// TunnelBypassed is set when the test was sent around it
+	Tunnel         string `json:"tunnel,omitempty"`
+	TunnelBypassed bool   `json:"tunnelBypassed,omitempty"`
+
+	// Maintenance is set on tests run during a planned maintenance window of the isp
+	Maintenance bool `json:"maintenance,omitempty"`
 }
 
 type Options struct {
@@ -48,6 +56,12 @@ type Options struct {
 
 	// if set takes precedence over ndt7 locate API
 	ServiceURL *url.URL
+
+	// Interface binds the test to a local interface instead of following the routing table
+	Interface string
+
+	// Tunnel is the vpn or tunnel the host is routed through, it is recorded on the result
+	Tunnel string
 }
 
 func Run(ctx context.Context, opts Options) (*SpeedTestResult, error) {
@@ -70,6 +84,8 @@ func Run(ctx context.Context, opts Options) (*SpeedTestResult, error) {
 	result.TimeStampFinish = endTime
 	result.ClientVersion = opts.ClientVersion
 	result.OS = runtime.GOOS
+	result.Tunnel = opts.Tunnel
+	result.TunnelBypassed = opts.Tunnel != "" && opts.Interface != ""
 
 	return result, nil
 }
@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/m-lab/locate/api/locate"
 	ndt7 "github.com/m-lab/ndt7-client-go"
 	"github.com/m-lab/ndt7-client-go/spec"
 	log "golang.org/x/exp/slog"
@@ -34,6 +36,18 @@ func RunSpeedTest(ctx context.Context, opts *Options) (*SpeedTestResult, error)
 	client := ndt7.NewClient(ClientName, clientVersion)
 	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
 
+	// the server is located through the same interface so that it is near the path tested
+	if opts.Interface != "" {
+		bound := bindDialer(opts.Interface)
+		dialer.NetDialContext = bound.DialContext
+		if l, ok := client.Locate.(*locate.Client); ok {
+			l.HTTPClient = &http.Client{Transport: &http.Transport{
+				Proxy:       http.ProxyFromEnvironment,
+				DialContext: bound.DialContext,
+			}}
+		}
+	}
+
 	client.Dialer = dialer
 
 	client.ServiceURL = opts.ServiceURL
@@ -0,0 +1,207 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// Version is the schema version of the state written by this client
+const Version = 1
+
+// State is what the client remembers between restarts
+type State struct {
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	// AvoidAddresses are external addresses that did not answer recent connectivity tests
+	AvoidAddresses []string `json:"avoidAddresses,omitempty"`
+	// LastSpeedTest is when the last speed test completed
+	LastSpeedTest *time.Time `json:"lastSpeedTest,omitempty"`
+	// OutageStartedAt is when the current outage started, nil while the host is connected
+	OutageStartedAt *time.Time `json:"outageStartedAt,omitempty"`
+	// ConfigVersion is the version of the applied remote configuration
+	ConfigVersion string `json:"configVersion,omitempty"`
+	// Config is the applied remote configuration as it was received from the api
+	Config json.RawMessage `json:"config,omitempty"`
+	// PublicIP is the last known public ip address of the host
+	PublicIP string `json:"publicIP,omitempty"`
+}
+
+// migration upgrades a decoded state document from one version to the next
+type migration func(doc map[string]any) error
+
+// migrations[n] upgrades a document from version n to n+1,
+// add one whenever a change to State cannot be read from the previous version
+var migrations = map[int]migration{}
+
+// Store keeps the state in memory and writes it to a file whenever it changes
+type Store struct {
+	mu    sync.Mutex
+	path  string
+	state State
+}
+
+// Open reads the state at path, a missing file is an empty state. A file that cannot be
+// decoded or migrated is moved aside to <path>.corrupt and the client starts with an empty state.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, state: State{Version: Version}}
+
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot read state: %v", err)
+	}
+
+	st, err := decode(b)
+	if err != nil {
+		log.Warn("cannot load state, starting without it", "path", path, "error", err)
+		if err := os.Rename(path, path+".corrupt"); err != nil {
+			return nil, fmt.Errorf("cannot move unreadable state aside: %v", err)
+		}
+
+		return s, nil
+	}
+
+	s.state = st
+	return s, nil
+}
+
+// decode migrates a state document to the current version
+func decode(b []byte) (State, error) {
+	doc := map[string]any{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return State{}, fmt.Errorf("json.Unmarshal: %v", err)
+	}
+
+	version := 0
+	if v, ok := doc["version"].(float64); ok {
+		version = int(v)
+	}
+
+	// fields are only ever added, a newer client's state is read as far as it is understood
+	if version > Version {
+		log.Warn("state was written by a newer client", "version", version, "supported", Version)
+	}
+
+	for ; version < Version; version++ {
+		migrate, ok := migrations[version]
+		if !ok {
+			return State{}, fmt.Errorf("no migration from state version %d", version)
+		}
+
+		if err := migrate(doc); err != nil {
+			return State{}, fmt.Errorf("cannot migrate state from version %d: %v", version, err)
+		}
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return State{}, fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	st := State{}
+	if err := json.Unmarshal(b, &st); err != nil {
+		return State{}, fmt.Errorf("json.Unmarshal: %v", err)
+	}
+	st.Version = Version
+
+	return st, nil
+}
+
+// Get returns a copy of the current state
+func (s *Store) Get() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.state.clone()
+}
+
+// Update applies fn to a copy of the state and writes it when it changed.
+// The state is updated in memory even if it cannot be written.
+func (s *Store) Update(fn func(*State)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := s.state.clone()
+	fn(&next)
+	if reflect.DeepEqual(next, s.state) {
+		return nil
+	}
+
+	next.Version = Version
+	next.UpdatedAt = time.Now()
+	s.state = next
+
+	return write(s.path, next)
+}
+
+// clone copies st so that the copy can be changed without a lock
+func (st State) clone() State {
+	c := st
+	if st.AvoidAddresses != nil {
+		c.AvoidAddresses = append([]string{}, st.AvoidAddresses...)
+	}
+
+	if st.Config != nil {
+		c.Config = append(json.RawMessage{}, st.Config...)
+	}
+
+	if st.LastSpeedTest != nil {
+		t := *st.LastSpeedTest
+		c.LastSpeedTest = &t
+	}
+
+	if st.OutageStartedAt != nil {
+		t := *st.OutageStartedAt
+		c.OutageStartedAt = &t
+	}
+
+	return c
+}
+
+// write replaces the file at path with st, a crash leaves either the old or the new file in place
+func write(path string, st State) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("cannot create state directory: %v", err)
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("cannot create state file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write state: %v", err)
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot sync state: %v", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close state file: %v", err)
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("cannot replace state file: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestStore(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "state", "client.json")
+	s, err := Open(path)
+	is.NoErr(err)
+	is.Equal(Version, s.Get().Version)
+
+	// nothing is written until the state changes
+	is.NoErr(s.Update(func(*State) {}))
+	_, err = os.Stat(path)
+	is.True(os.IsNotExist(err))
+
+	outage := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	is.NoErr(s.Update(func(st *State) {
+		st.AvoidAddresses = []string{"1.1.1.1"}
+		st.OutageStartedAt = &outage
+		st.ConfigVersion = "v2"
+		st.Config = json.RawMessage(`{"config":{"version":"v2"}}`)
+		st.PublicIP = "192.0.2.1"
+	}))
+
+	// a copy can be changed without changing the store
+	st := s.Get()
+	st.AvoidAddresses[0] = "8.8.8.8"
+	is.Equal("1.1.1.1", s.Get().AvoidAddresses[0])
+
+	reopened, err := Open(path)
+	is.NoErr(err)
+	st = reopened.Get()
+	is.Equal([]string{"1.1.1.1"}, st.AvoidAddresses)
+	is.True(outage.Equal(*st.OutageStartedAt))
+	is.Equal("v2", st.ConfigVersion)
+	is.Equal(`{"config":{"version":"v2"}}`, string(st.Config))
+	is.Equal("192.0.2.1", st.PublicIP)
+	is.True(!st.UpdatedAt.IsZero())
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	is.NoErr(err)
+	is.Equal(1, len(entries))
+}
+
+func TestOpenCorrupt(t *testing.T) {
+	is := is.New(t)
+
+	path := filepath.Join(t.TempDir(), "client.json")
+	is.NoErr(os.WriteFile(path, []byte(`{"version": 1, "publicIP": `), 0600))
+
+	s, err := Open(path)
+	is.NoErr(err)
+	is.Equal("", s.Get().PublicIP)
+
+	_, err = os.Stat(path + ".corrupt")
+	is.NoErr(err) // kept for inspection
+}
+
+func TestMigrations(t *testing.T) {
+	is := is.New(t)
+
+	// an unversioned document without a migration cannot be read
+	_, err := decode([]byte(`{"publicIP": "192.0.2.1"}`))
+	is.True(err != nil)
+
+	defer func(m map[int]migration) { migrations = m }(migrations)
+	migrations = map[int]migration{
+		0: func(doc map[string]any) error {
+			doc["publicIP"] = doc["ip"]
+			delete(doc, "ip")
+			return nil
+		},
+	}
+
+	st, err := decode([]byte(`{"ip": "192.0.2.1"}`))
+	is.NoErr(err)
+	is.Equal("192.0.2.1", st.PublicIP)
+	is.Equal(Version, st.Version)
+
+	migrations[0] = func(map[string]any) error { return fmt.Errorf("unsupported") }
+	_, err = decode([]byte(`{"ip": "192.0.2.1"}`))
+	is.True(err != nil)
+
+	// a newer client's state is read as far as it is understood
+	st, err = decode([]byte(fmt.Sprintf(`{"version": %d, "publicIP": "192.0.2.1", "unknown": true}`, Version+1)))
+	is.NoErr(err)
+	is.Equal("192.0.2.1", st.PublicIP)
+}
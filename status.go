@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/metrics"
+	"github.com/imup-io/client/netid"
+	"github.com/imup-io/client/speedtesting"
+	log "golang.org/x/exp/slog"
+)
+
+// connectivity verdicts reported by the status server
+const (
+	verdictUnknown = "unknown"
+	verdictUp      = "up"
+	verdictDown    = "down"
+	verdictLocal   = "local network down"
+)
+
+// healthSchemaVersion is the version of clientHealth, increment it when a field
+// is renamed or removed, adding a field does not change the version
+const healthSchemaVersion = 1
+
+// clientState tracks what the client currently knows about its connection
+// so that it can be reported locally without digging through logs
+type clientState struct {
+	sync.RWMutex
+
+	startedAt time.Time
+
+	lastStatistics  []connectivity.Statistics
+	lastCollectedAt time.Time
+
+	lastSpeedTest   *speedtesting.SpeedTestResult
+	lastSpeedTestAt time.Time
+
+	// outageStartedAt is when the verdict last went from up to down, zero while connected
+	outageStartedAt time.Time
+
+	// lastSentAt is when a job was last sent to the api
+	lastSentAt time.Time
+
+	// identity is the network the client was last connected to
+	identity netid.Identity
+
+	// lastNetworkChange is the most recent move to another network, nil until the first
+	lastNetworkChange *netid.Change
+}
+
+func newClientState() *clientState {
+	return &clientState{startedAt: time.Now()}
+}
+
+// recordStatistics keeps the most recently collected connectivity statistics
+func (s *clientState) recordStatistics(stats []connectivity.Statistics) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastStatistics = stats
+	s.lastCollectedAt = time.Now()
+
+	switch s.verdictLocked() {
+	case verdictDown, verdictLocal:
+		// an outage does not start during planned maintenance
+		if s.outageStartedAt.IsZero() && len(connectivity.OutsideMaintenance(stats)) > 0 {
+			s.outageStartedAt = s.lastCollectedAt
+		}
+	case verdictUp:
+		s.outageStartedAt = time.Time{}
+	}
+}
+
+// restoreOutage continues an outage that started before the client restarted
+func (s *clientState) restoreOutage(startedAt time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.outageStartedAt = startedAt
+}
+
+// outage returns when the current outage started, nil while connected
+func (s *clientState) outage() *time.Time {
+	s.RLock()
+	defer s.RUnlock()
+
+	if s.outageStartedAt.IsZero() {
+		return nil
+	}
+
+	t := s.outageStartedAt
+	return &t
+}
+
+// recordSent notes that a job was sent to the api
+func (s *clientState) recordSent() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastSentAt = time.Now()
+}
+
+// recordNetwork keeps the identity of the network and returns the previous one
+func (s *clientState) recordNetwork(id netid.Identity) netid.Identity {
+	s.Lock()
+	defer s.Unlock()
+
+	previous := s.identity
+	s.identity = id
+	return previous
+}
+
+// recordNetworkChange keeps the most recent change of the network
+func (s *clientState) recordNetworkChange(c netid.Change) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastNetworkChange = &c
+}
+
+// network returns the identity of the network the client was last connected to
+func (s *clientState) network() netid.Identity {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.identity
+}
+
+// recordSpeedTest keeps the most recent successful speed test result
+func (s *clientState) recordSpeedTest(result *speedtesting.SpeedTestResult) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.lastSpeedTest = result
+	s.lastSpeedTestAt = time.Now()
+}
+
+// verdict determines if the client is currently connected from the last collected statistics
+func (s *clientState) verdict() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.verdictLocked()
+}
+
+// verdictLocked is verdict for callers holding the lock
+func (s *clientState) verdictLocked() string {
+	if len(s.lastStatistics) == 0 {
+		return verdictUnknown
+	}
+
+	// internal gateway results are never evidence of an internet connection
+	for _, stat := range s.lastStatistics {
+		if stat.EndpointType != "internal" && stat.Success {
+			return verdictUp
+		}
+	}
+
+	for _, stat := range s.lastStatistics {
+		if stat.EndpointType == "internal" && !stat.Success {
+			return verdictLocal
+		}
+	}
+
+	return verdictDown
+}
+
+// clientHealth is the health of the client sent with every liveness check in and
+// at the top of the status report, so that both are read the same way
+type clientHealth struct {
+	SchemaVersion   int        `json:"schemaVersion"`
+	ClientVersion   string     `json:"clientVersion"`
+	HostID          string     `json:"hostId"`
+	OS              string     `json:"os"`
+	Arch            string     `json:"arch"`
+	StartedAt       time.Time  `json:"startedAt"`
+	Uptime          string     `json:"uptime"`
+	ConfigVersion   string     `json:"configVersion"`
+	Verdict         string     `json:"verdict"`
+	Monitoring      bool       `json:"monitoring"`
+	Maintenance     bool       `json:"maintenance,omitempty"`
+	QueueDepth      int        `json:"queueDepth"`
+	CachedJobs      int        `json:"cachedJobs"`
+	LastSentAt      *time.Time `json:"lastSentAt,omitempty"`
+	PublicIP        string     `json:"publicIP,omitempty"`
+	PublicIPs       []string   `json:"publicIPs,omitempty"`
+	NetworkID       string     `json:"networkId,omitempty"`
+	NetworkProfile  string     `json:"networkProfile,omitempty"`
+	OutageStartedAt *time.Time `json:"outageStartedAt,omitempty"`
+}
+
+// clientStatus is a point in time report of the clients state
+type clientStatus struct {
+	clientHealth
+
+	Network           netid.Identity                `json:"network"`
+	LastNetworkChange *netid.Change                 `json:"lastNetworkChange,omitempty"`
+	LastCollectedAt   *time.Time                    `json:"lastCollectedAt,omitempty"`
+	LastStatistics    []connectivity.Statistics     `json:"lastStatistics"`
+	LastSpeedTestAt   *time.Time                    `json:"lastSpeedTestAt,omitempty"`
+	LastSpeedTest     *speedtesting.SpeedTestResult `json:"lastSpeedTest,omitempty"`
+	Errors            map[string]errorState         `json:"errors"`
+	Config            map[string]any                `json:"config"`
+}
+
+// health reports the health of the client
+func (i *imup) health() clientHealth {
+	h := clientHealth{
+		SchemaVersion:   healthSchemaVersion,
+		ClientVersion:   ClientVersion,
+		HostID:          i.cfg.HostID(),
+		OS:              runtime.GOOS,
+		Arch:            runtime.GOARCH,
+		ConfigVersion:   i.cfg.Version(),
+		Verdict:         i.State.verdict(),
+		Monitoring:      i.monitoring(),
+		Maintenance:     i.maintenance(time.Now()),
+		QueueDepth:      i.queueDepth(),
+		CachedJobs:      cachedJobs(),
+		PublicIP:        i.cfg.PublicIP(),
+		PublicIPs:       i.cfg.PublicIPs(),
+		NetworkID:       i.State.network().ID,
+		OutageStartedAt: i.State.outage(),
+	}
+
+	if p := i.profile(); p != nil {
+		h.NetworkProfile = p.Name
+	}
+
+	i.State.RLock()
+	defer i.State.RUnlock()
+
+	h.StartedAt = i.State.startedAt
+	h.Uptime = time.Since(i.State.startedAt).Round(time.Second).String()
+	if !i.State.lastSentAt.IsZero() {
+		t := i.State.lastSentAt
+		h.LastSentAt = &t
+	}
+
+	return h
+}
+
+// status reports the current state of the client
+func (i *imup) status() clientStatus {
+	health := i.health()
+	network := i.network()
+
+	i.State.RLock()
+	defer i.State.RUnlock()
+
+	s := clientStatus{
+		clientHealth:      health,
+		Network:           network,
+		LastNetworkChange: i.State.lastNetworkChange,
+		LastStatistics:    i.State.lastStatistics,
+		LastSpeedTest:     i.State.lastSpeedTest,
+		Errors:            map[string]errorState{},
+		Config:            i.cfg.Redacted(),
+	}
+
+	if !i.State.lastCollectedAt.IsZero() {
+		t := i.State.lastCollectedAt
+		s.LastCollectedAt = &t
+	}
+
+	if !i.State.lastSpeedTestAt.IsZero() {
+		t := i.State.lastSpeedTestAt
+		s.LastSpeedTestAt = &t
+	}
+
+	if i.Errors != nil {
+		s.Errors = i.Errors.snapshot()
+	}
+
+	return s
+}
+
+// statusHandler serves the current state of the client as json
+func (i *imup) statusHandler() http.Handler {
+	mux := http.NewServeMux()
+	if i.cfg.Metrics() {
+		mux.Handle("/metrics", metrics.Handler())
+	}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(i.status()); err != nil {
+			log.Error("cannot encode status", "error", err)
+		}
+	})
+
+	return mux
+}
+
+// serveStatus runs the local status server until ctx is canceled
+func (i *imup) serveStatus(ctx context.Context, addr string) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           i.statusHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := srv.Shutdown(sctx); err != nil {
+			log.Error("cannot shutdown status server", "error", err)
+		}
+	}()
+
+	log.Info("status server listening", "address", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("status server failed", "error", err)
+	}
+}
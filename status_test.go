@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/reporting"
+	"github.com/imup-io/client/sinks"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/matryer/is"
+)
+
+func TestStatus(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "status-api-key")
+	os.Setenv("EMAIL", "status@example.com")
+	os.Setenv("HOST_ID", "status-host")
+
+	imup := newApp()
+	imup.Errors = NewErrMap(reporting.NewNoop())
+
+	is.Equal(verdictUnknown, imup.State.verdict())
+
+	imup.State.recordStatistics([]connectivity.Statistics{
+		{EndpointType: "internal", Success: true},
+		{EndpointType: "external", Success: false, SuccessInternal: true},
+	})
+	is.Equal(verdictDown, imup.State.verdict())
+
+	imup.State.recordStatistics([]connectivity.Statistics{
+		{EndpointType: "internal", Success: false},
+		{EndpointType: "external", Success: false},
+	})
+	is.Equal(verdictLocal, imup.State.verdict())
+
+	imup.State.recordStatistics([]connectivity.Statistics{{EndpointType: "external", Success: true}})
+	is.Equal(verdictUp, imup.State.verdict())
+
+	imup.State.recordSpeedTest(&speedtesting.SpeedTestResult{DownloadMbps: 100, UploadMbps: 10})
+	imup.State.recordSent()
+	imup.Errors.write("SendClientHealthy", errors.New("liveness failed"))
+	is.True(imup.api.Enqueue(sinks.Record{Kind: sinks.KindConnectivity}))
+
+	s := httptest.NewServer(imup.statusHandler())
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/status")
+	is.NoErr(err)
+	defer resp.Body.Close()
+	is.Equal(http.StatusOK, resp.StatusCode)
+
+	raw := strings.Builder{}
+	status := clientStatus{}
+	is.NoErr(json.NewDecoder(resp.Body).Decode(&status))
+	is.NoErr(json.NewEncoder(&raw).Encode(status))
+
+	is.Equal(healthSchemaVersion, status.SchemaVersion)
+	is.Equal("status-host", status.HostID)
+	is.Equal(runtime.GOOS, status.OS)
+	is.Equal("dev-preview", status.ConfigVersion)
+	is.Equal(verdictUp, status.Verdict)
+	is.Equal(1, status.QueueDepth)
+	is.True(status.LastSentAt != nil)
+	is.Equal(100.0, status.LastSpeedTest.DownloadMbps)
+	is.Equal("liveness failed", status.Errors["SendClientHealthy"].Error)
+	is.Equal(errorStateFailing, status.Errors["SendClientHealthy"].State)
+	is.Equal(1, status.Errors["SendClientHealthy"].Count)
+	is.True(status.LastCollectedAt != nil)
+	is.True(!strings.Contains(raw.String(), "status-api-key"))
+
+	notFound, err := http.Get(s.URL + "/unknown")
+	is.NoErr(err)
+	notFound.Body.Close()
+	is.Equal(http.StatusNotFound, notFound.StatusCode)
+}
+
+func TestMonitoring(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "status-api-key")
+	os.Setenv("HOST_ID", "status-host")
+	os.Setenv("ALLOWLISTED_IPS", "192.0.2.1")
+	os.Setenv("BLOCKLISTED_IPS", "2001:db8::bad")
+
+	imup := newApp()
+	imup.cfg.SetPublicIP("192.0.2.1")
+	imup.cfg.SetPublicIP("2001:db8::1")
+	is.True(imup.monitoring()) // one address of a dual stack host is allowed
+
+	imup.cfg.SetPublicIP("2001:db8::bad")
+	is.True(!imup.monitoring()) // either address is blocked
+
+	imup.cfg.SetPublicIP("192.0.2.2")
+	imup.cfg.SetPublicIP("2001:db8::1")
+	is.True(!imup.monitoring()) // neither address is allowed
+}
+
+func TestSchedule(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+	os.Setenv("API_KEY", "status-api-key")
+	os.Setenv("HOST_ID", "status-host")
+	os.Setenv("MONITORING_TIMEZONE", "UTC")
+
+	now := time.Now().UTC()
+	os.Setenv("MAINTENANCE_WINDOWS", now.Add(-time.Hour).Format(time.RFC3339)+"/"+now.Add(time.Hour).Format(time.RFC3339))
+
+	imup := newApp()
+	is.True(imup.monitoring()) // maintenance does not pause monitoring
+	is.True(imup.health().Maintenance)
+
+	// statistics collected during maintenance are tagged and do not start an outage
+	collected := imup.collect(context.Background(), &fixedCollector{stats: []connectivity.Statistics{{EndpointType: "external", SuccessInternal: true}}})
+	is.True(collected[0].Maintenance)
+	is.Equal(verdictDown, imup.State.verdict())
+	is.Equal(nil, imup.State.outage())
+
+	// outside of the monitoring schedule nothing is tested
+	tomorrow := strings.ToLower(now.Add(24 * time.Hour).Weekday().String())
+	os.Setenv("MONITORING_SCHEDULE", tomorrow)
+	os.Unsetenv("MAINTENANCE_WINDOWS")
+	imup = newApp()
+	is.True(!imup.monitoring())
+	is.True(!imup.health().Maintenance)
+
+	collected = imup.collect(context.Background(), &fixedCollector{stats: []connectivity.Statistics{{EndpointType: "external", SuccessInternal: true}}})
+	is.True(!collected[0].Maintenance)
+	is.True(imup.State.outage() != nil)
+}
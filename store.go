@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/imup-io/client/config"
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/imup-io/client/state"
+	log "golang.org/x/exp/slog"
+)
+
+// openStore opens the state kept between restarts and restores it. A client without a store,
+// such as one running a diagnostic command, does not persist anything.
+func (i *imup) openStore() {
+	store, err := state.Open(i.cfg.StateFile())
+	if err != nil {
+		log.Warn("cannot open state, state will not survive a restart", "error", err)
+		return
+	}
+
+	i.Store = store
+	st := store.Get()
+
+	// remote configuration is only sent when it changes, without it the client would run with
+	// its local configuration until the next change
+	if st.Config != nil {
+		if cfg, err := config.Reload(st.Config); err != nil {
+			log.Info("cannot restore remote config", "version", st.ConfigVersion, "error", err)
+		} else {
+			i.cfg = cfg
+			log.Info("remote config restored", "version", cfg.Version())
+		}
+	}
+
+	// the public ip may not be reachable during an outage, the last known address keeps
+	// allow and block lists working until it can be refreshed
+	if i.cfg.PublicIP() == "" && st.PublicIP != "" {
+		i.cfg.SetPublicIP(st.PublicIP)
+	}
+
+	if st.OutageStartedAt != nil {
+		log.Info("outage in progress since before restart", "startedAt", st.OutageStartedAt)
+		i.State.restoreOutage(*st.OutageStartedAt)
+	}
+}
+
+// persist applies fn to the stored state, it does nothing without a store
+func (i *imup) persist(fn func(*state.State)) {
+	if i.Store == nil {
+		return
+	}
+
+	if err := i.Store.Update(fn); err != nil {
+		log.Warn("cannot persist state", "error", err)
+	}
+}
+
+// avoidAddresses are the addresses a new collector starts out avoiding
+func (i *imup) avoidAddresses() []string {
+	if i.Store == nil {
+		return nil
+	}
+
+	return i.Store.Get().AvoidAddresses
+}
+
+// persistConnectivity keeps what a connectivity test learned
+func (i *imup) persistConnectivity(collector connectivity.StatCollector) {
+	avoid := collector.Avoided()
+	if len(avoid) == 0 {
+		avoid = nil
+	}
+
+	outage := i.State.outage()
+	i.persist(func(st *state.State) {
+		st.AvoidAddresses = avoid
+		st.OutageStartedAt = outage
+	})
+}
+
+// resetAvoidAddresses forgets the addresses avoided on a previous network
+func (i *imup) resetAvoidAddresses() {
+	i.persist(func(st *state.State) { st.AvoidAddresses = nil })
+}
+
+// recordSpeedTest keeps a successful speed test for the status server and for the schedule after a restart
+func (i *imup) recordSpeedTest(result *speedtesting.SpeedTestResult) {
+	i.State.recordSpeedTest(result)
+
+	now := time.Now()
+	i.persist(func(st *state.State) { st.LastSpeedTest = &now })
+}
+
+// persistConfig keeps an applied remote configuration so that it is restored after a restart
+func (i *imup) persistConfig(data []byte) {
+	i.persist(func(st *state.State) {
+		st.ConfigVersion = i.cfg.Version()
+		st.Config = json.RawMessage(data)
+	})
+}
+
+// persistPublicIP keeps the last known public ip address
+func (i *imup) persistPublicIP() {
+	if ip := i.cfg.PublicIP(); ip != "" {
+		i.persist(func(st *state.State) { st.PublicIP = ip })
+	}
+}
+
+// speedTestDelay is how long to wait before the first random speed test, a restart
+// does not run a speed test if one ran within a speed test interval
+func (i *imup) speedTestDelay() time.Duration {
+	if i.Store == nil {
+		return 0
+	}
+
+	last := i.Store.Get().LastSpeedTest
+	if last == nil {
+		return 0
+	}
+
+	return speedTestInterval() - time.Since(*last)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/imup-io/client/state"
+	"github.com/matryer/is"
+)
+
+func TestOpenStore(t *testing.T) {
+	is := is.New(t)
+	defer os.Clearenv()
+
+	path := filepath.Join(t.TempDir(), "state", "client.json")
+	os.Setenv("API_KEY", "store-api-key")
+	os.Setenv("HOST_ID", "store-host")
+	os.Setenv("STATE_FILE", path)
+
+	outage := time.Now().Add(-time.Hour).UTC()
+	last := time.Now().Add(-time.Minute)
+	s, err := state.Open(path)
+	is.NoErr(err)
+	is.NoErr(s.Update(func(st *state.State) {
+		st.AvoidAddresses = []string{"192.0.2.10"}
+		st.OutageStartedAt = &outage
+		st.LastSpeedTest = &last
+		st.ConfigVersion = "store-v1"
+		st.Config = json.RawMessage(`{"config": {"version": "store-v1"}}`)
+		st.PublicIP = "192.0.2.1"
+	}))
+
+	imup := newApp()
+	is.Equal(time.Duration(0), imup.speedTestDelay()) // no store, no delay
+	imup.openStore()
+
+	is.Equal("store-v1", imup.cfg.Version())
+	is.Equal("192.0.2.1", imup.cfg.PublicIP())
+	is.True(outage.Equal(*imup.State.outage()))
+	is.Equal([]string{"192.0.2.10"}, imup.avoidAddresses())
+	is.True(imup.speedTestDelay() > 0) // a speed test ran a minute ago
+
+	// an outage ends when connectivity is restored
+	imup.State.recordStatistics([]connectivity.Statistics{{EndpointType: "external", Success: true}})
+	imup.persistConnectivity(&avoidingCollector{})
+	is.True(imup.Store.Get().OutageStartedAt == nil)
+	is.True(imup.Store.Get().AvoidAddresses == nil)
+
+	imup.recordSpeedTest(&speedtesting.SpeedTestResult{DownloadMbps: 100})
+	is.True(imup.Store.Get().LastSpeedTest.After(last))
+
+	reopened, err := state.Open(path)
+	is.NoErr(err)
+	is.True(reopened.Get().OutageStartedAt == nil)
+	is.Equal("store-v1", reopened.Get().ConfigVersion)
+}
+
+type avoidingCollector struct {
+	connectivity.StatCollector
+	avoid []string
+}
+
+func (c *avoidingCollector) Avoided() []string { return c.avoid }
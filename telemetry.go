@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/imup-io/client/connectivity"
+	"github.com/imup-io/client/metrics"
+	"github.com/imup-io/client/speedtesting"
+	"github.com/imup-io/client/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// collect runs a single connectivity test and records its outcome
+func (i *imup) collect(ctx context.Context, collector connectivity.StatCollector) []connectivity.Statistics {
+	addresses := i.cfg.PingAddresses()
+
+	ctx, span := telemetry.Start(ctx, "connectivity.Collect",
+		attribute.Bool("ping", i.cfg.PingTests()),
+		attribute.StringSlice("addresses", addresses),
+	)
+
+	start := time.Now()
+	collected := collector.Collect(ctx, addresses)
+
+	// statistics are tagged with the network and tunnel they were collected on and
+	// whether the isp was under maintenance
+	network := i.State.network()
+	maintenance := i.maintenance(start)
+	for n := range collected {
+		collected[n].NetworkID = network.ID
+		collected[n].Tunnel = network.Tunnel
+		collected[n].Maintenance = maintenance
+	}
+
+	i.State.recordStatistics(collected)
+	i.persistConnectivity(collector)
+	metrics.ObserveStatistics(collected)
+
+	_, dt := collector.DetectDowntime(collected)
+	if dt > 0 {
+		metrics.AddDowntime(time.Duration(dt) * collector.Interval())
+	}
+
+	span.SetAttributes(attribute.Int("statistics", len(collected)), attribute.Int("downtime", dt), attribute.Bool("maintenance", maintenance))
+	telemetry.End(span, nil)
+
+	return collected
+}
+
+// runSpeedTest runs a single speed test and records its outcome
+func (i *imup) runSpeedTest(ctx context.Context, opts speedtesting.Options) (*speedtesting.SpeedTestResult, error) {
+	ctx, span := telemetry.Start(ctx, "speedtesting.Run", attribute.Bool("on_demand", opts.OnDemand))
+
+	start := time.Now()
+	result, err := speedtesting.Run(ctx, opts)
+	i.enrichSpeedTest(result)
+	if result != nil {
+		result.Maintenance = i.maintenance(start)
+	}
+	metrics.ObserveSpeedTest(result, opts.OnDemand)
+	if result != nil {
+		span.SetAttributes(
+			attribute.Float64("download_mbps", result.DownloadMbps),
+			attribute.Float64("upload_mbps", result.UploadMbps),
+		)
+	}
+
+	telemetry.End(span, err)
+
+	return result, err
+}
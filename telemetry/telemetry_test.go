@@ -0,0 +1,53 @@
+package telemetry_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/imup-io/client/telemetry"
+	"github.com/matryer/is"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetup(t *testing.T) {
+	is := is.New(t)
+
+	mu := sync.Mutex{}
+	received := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received[r.URL.Path]++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	_, err := telemetry.Setup(ctx, telemetry.Options{Endpoint: "localhost:4318"})
+	is.True(err != nil) // endpoint must be a url
+
+	shutdown, err := telemetry.Setup(ctx, telemetry.Options{})
+	is.NoErr(err)
+	is.NoErr(shutdown(ctx)) // without an endpoint telemetry is a no-op
+
+	shutdown, err = telemetry.Setup(ctx, telemetry.Options{Endpoint: srv.URL + "/otlp/", HostID: "host", Version: "test"})
+	is.NoErr(err)
+
+	_, span := telemetry.Start(ctx, "test")
+	telemetry.End(span, errors.New("failed"))
+
+	counter, err := otel.Meter(telemetry.Name).Int64Counter("imup.test")
+	is.NoErr(err)
+	counter.Add(ctx, 1)
+
+	is.NoErr(shutdown(ctx))
+
+	mu.Lock()
+	defer mu.Unlock()
+	is.Equal(1, received["/otlp/v1/traces"])
+	is.Equal(1, received["/otlp/v1/metrics"])
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	log "golang.org/x/exp/slog"
+)
+
+// IPMatcher matches ip addresses against a list of entries, each entry is one of
+//
+//	192.0.2.1                  a single address, the same as 192.0.2.1/32
+//	10.0.0.0/16, 2001:db8::/32 a cidr prefix
+//	10.0.0.10-10.0.0.20        an inclusive range
+//	!10.0.5.0/24               an exclusion of an address, prefix or range
+//
+// An address matches when it is in an entry and in no exclusion, a list of
+// only exclusions matches every address that is not excluded.
+type IPMatcher struct {
+	configured bool
+	include    []ipRange
+	exclude    []ipRange
+}
+
+// ipRange is an inclusive range of addresses of one family
+type ipRange struct {
+	from, to netip.Addr
+}
+
+func (r ipRange) contains(addr netip.Addr) bool {
+	return addr.BitLen() == r.from.BitLen() && r.from.Compare(addr) <= 0 && addr.Compare(r.to) <= 0
+}
+
+// NewIPMatcher compiles entries, empty entries are ignored and invalid entries are
+// logged and never match
+func NewIPMatcher(entries []string) *IPMatcher {
+	m := &IPMatcher{}
+	for _, e := range entries {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+
+		// an invalid entry still configures the list, so that an allowlist of
+		// only invalid entries allows nothing
+		m.configured = true
+
+		exclude := strings.HasPrefix(e, "!")
+		r, err := parseIPRange(strings.TrimSpace(strings.TrimPrefix(e, "!")))
+		if err != nil {
+			log.Warn("ignoring invalid ip address entry", "entry", e, "error", err)
+			continue
+		}
+
+		if exclude {
+			m.exclude = append(m.exclude, r)
+		} else {
+			m.include = append(m.include, r)
+		}
+	}
+
+	return m
+}
+
+// parseIPRange parses an address, a cidr prefix or a range
+func parseIPRange(entry string) (ipRange, error) {
+	if from, to, ok := strings.Cut(entry, "-"); ok {
+		start, err := netip.ParseAddr(strings.TrimSpace(from))
+		if err != nil {
+			return ipRange{}, err
+		}
+
+		end, err := netip.ParseAddr(strings.TrimSpace(to))
+		if err != nil {
+			return ipRange{}, err
+		}
+
+		start, end = start.Unmap(), end.Unmap()
+		if start.BitLen() != end.BitLen() || end.Less(start) {
+			return ipRange{}, fmt.Errorf("invalid range %s", entry)
+		}
+
+		return ipRange{start, end}, nil
+	}
+
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return ipRange{}, err
+		}
+
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefix = prefix.Masked()
+
+		return ipRange{prefix.Addr(), lastAddr(prefix)}, nil
+	}
+
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return ipRange{}, err
+	}
+	addr = addr.Unmap().WithZone("")
+
+	return ipRange{addr, addr}, nil
+}
+
+// lastAddr returns the highest address in a masked prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	b := prefix.Addr().AsSlice()
+	for bit := prefix.Bits(); bit < len(b)*8; bit++ {
+		b[bit/8] |= 0x80 >> (bit % 8)
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// Empty reports whether no entries are configured
+func (m *IPMatcher) Empty() bool {
+	return m == nil || !m.configured
+}
+
+// Contains reports whether ip matches the entries, an empty matcher contains nothing
+func (m *IPMatcher) Contains(ip string) bool {
+	if m.Empty() {
+		return false
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+
+	for _, r := range m.exclude {
+		if r.contains(addr) {
+			return false
+		}
+	}
+
+	if len(m.include) == 0 {
+		return len(m.exclude) > 0
+	}
+
+	for _, r := range m.include {
+		if r.contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/imup-io/client/util"
+	"github.com/matryer/is"
+)
+
+func Test_IPMatcher(t *testing.T) {
+	is := is.New(t)
+
+	m := util.NewIPMatcher([]string{
+		"192.0.2.1",
+		"198.51.100.1/32",
+		"10.0.0.0/16",
+		"!10.0.5.0/24",
+		"172.16.0.10-172.16.0.20",
+		"2001:db8::/32",
+		"!2001:db8:dead::/48",
+		"::ffff:203.0.113.0/120",
+	})
+	is.True(!m.Empty())
+
+	for ip, want := range map[string]bool{
+		"192.0.2.1":           true,
+		"192.0.2.2":           false,
+		"198.51.100.1":        true,
+		"10.0.0.1":            true,
+		"10.0.255.255":        true,
+		"10.1.0.0":            false,
+		"10.0.5.7":            false, // excluded
+		"172.16.0.10":         true,
+		"172.16.0.20":         true,
+		"172.16.0.21":         false,
+		"2001:db8::1":         true,
+		"2001:db8:dead::1":    false, // excluded
+		"2001:db9::1":         false,
+		"::ffff:192.0.2.1":    true, // ipv4 mapped
+		"203.0.113.9":         true,
+		"not an ip":           false,
+		"":                    false,
+		"fe80::1%eth0":        false,
+		"2001:db8:beef::1%en": true,
+	} {
+		if m.Contains(ip) != want {
+			t.Errorf("Contains(%q) = %t, want %t", ip, !want, want)
+		}
+	}
+
+	// ipv4 prefixes do not match ipv6 addresses of the same bits
+	is.True(!util.NewIPMatcher([]string{"0.0.0.0/0"}).Contains("::1"))
+	is.True(util.NewIPMatcher([]string{"::/0"}).Contains("2001:db8::1"))
+
+	// only exclusions match everything else
+	m = util.NewIPMatcher([]string{"!10.0.0.0/8"})
+	is.True(m.Contains("192.0.2.1"))
+	is.True(!m.Contains("10.1.2.3"))
+
+	// an invalid list matches nothing but is configured
+	m = util.NewIPMatcher([]string{"office", "10.0.0.20-10.0.0.10", "10.0.0.0/33"})
+	is.True(!m.Empty())
+	is.True(!m.Contains("10.0.0.15"))
+
+	is.True(util.NewIPMatcher([]string{"", " "}).Empty())
+	is.True(util.NewIPMatcher(nil).Empty())
+	is.True(!util.NewIPMatcher(nil).Contains("10.0.0.1"))
+}
+
+func Test_IPMatcherLargePrefix(t *testing.T) {
+	is := is.New(t)
+
+	// large prefixes are not expanded
+	m := util.NewIPMatcher([]string{"10.0.0.0/8", "2001:db8::/16"})
+	is.True(m.Contains("10.255.255.255"))
+	is.True(m.Contains("2001:ffff::1"))
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// helperTimeout bounds how long a credential helper may run
+const helperTimeout = 10 * time.Second
+
+// SecretSource locates a secret outside of flags and the environment,
+// either in a file (e.g. a docker or kubernetes secrets mount) or from the
+// output of an external credential helper. Secrets read from a source can
+// be read again to pick up a rotated value.
+type SecretSource struct {
+	// Name identifies the secret, it is passed as the last argument to a credential helper
+	Name string
+	// File is a path to a file containing only the secret
+	File string
+	// Helper is a command and its arguments that writes the secret to stdout, it is run
+	// directly and not through a shell
+	Helper []string
+}
+
+// Configured reports if the secret can be read from a file or credential helper
+func (s SecretSource) Configured() bool {
+	return s.File != "" || len(s.Helper) > 0
+}
+
+// Read returns the secret from a file, or if no file is configured, a credential helper
+func (s SecretSource) Read(ctx context.Context) (string, error) {
+	if s.File != "" {
+		b, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", fmt.Errorf("cannot read %s from file: %v", s.Name, err)
+		}
+
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	if len(s.Helper) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, helperTimeout)
+		defer cancel()
+
+		var stdout, stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, s.Helper[0], append(append([]string{}, s.Helper[1:]...), s.Name)...)
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			return "", fmt.Errorf("credential helper failed for %s: %v: %s", s.Name, err, strings.TrimSpace(stderr.String()))
+		}
+
+		return strings.TrimSpace(stdout.String()), nil
+	}
+
+	return "", fmt.Errorf("no source configured for %s", s.Name)
+}
+
+// ValueOr returns the secret from its source, or a fallback if the source is not configured or cannot be read
+func (s SecretSource) ValueOr(defaultVal string) string {
+	if !s.Configured() {
+		return defaultVal
+	}
+
+	v, err := s.Read(context.Background())
+	if err != nil {
+		log.Error("cannot read secret", "name", s.Name, "error", err)
+		return defaultVal
+	}
+
+	if v == "" {
+		return defaultVal
+	}
+
+	return v
+}
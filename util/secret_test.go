@@ -0,0 +1,62 @@
+package util_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/imup-io/client/util"
+	"github.com/matryer/is"
+)
+
+func Test_SecretSourceFile(t *testing.T) {
+	is := is.New(t)
+	file := filepath.Join(t.TempDir(), "api_key")
+	is.NoErr(os.WriteFile(file, []byte("from-file\n"), 0600))
+
+	src := util.SecretSource{Name: "API_KEY", File: file}
+	is.True(src.Configured())
+
+	v, err := src.Read(context.Background())
+	is.NoErr(err)
+	is.Equal("from-file", v)
+
+	// rotation
+	is.NoErr(os.WriteFile(file, []byte("rotated"), 0600))
+	is.Equal("rotated", src.ValueOr("fallback"))
+
+	missing := util.SecretSource{Name: "API_KEY", File: filepath.Join(t.TempDir(), "missing")}
+	is.Equal("fallback", missing.ValueOr("fallback"))
+
+	unset := util.SecretSource{Name: "API_KEY"}
+	is.True(!unset.Configured())
+	is.Equal("fallback", unset.ValueOr("fallback"))
+}
+
+func Test_SecretSourceHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helper test relies on echo")
+	}
+
+	is := is.New(t)
+	src := util.SecretSource{Name: "API_KEY", Helper: []string{"echo", "helper"}}
+
+	v, err := src.Read(context.Background())
+	is.NoErr(err)
+	is.Equal("helper API_KEY", v)
+
+	failing := util.SecretSource{Name: "API_KEY", Helper: []string{"false"}}
+	_, err = failing.Read(context.Background())
+	is.True(err != nil)
+
+	// a helper path with spaces and quoted arguments are passed as they are
+	helper := filepath.Join(t.TempDir(), "credential helper")
+	is.NoErr(os.WriteFile(helper, []byte("#!/bin/sh\necho \"$1|$2\"\n"), 0o700))
+
+	quoted := util.SecretSource{Name: "EMAIL", Helper: []string{helper, "--profile 'prod'"}}
+	v, err = quoted.Read(context.Background())
+	is.NoErr(err)
+	is.Equal("--profile 'prod'|EMAIL", v)
+}
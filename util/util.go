@@ -71,45 +71,6 @@ func LevelMap(ptr *string, varName, defaultVal string) log.Level {
 
 // IPMonitored considers configured allowed and blocked ip addresses and inspects a clients
 // public ip address to determine if it should be used for speed and connectivity testing
-func IPMonitored(publicIP string, allowed, blocked []string) bool {
-	return ipAllowed(publicIP, allowed) && !ipBlocked(publicIP, blocked)
-}
-
-// iterate over list of allowed ips and ensure the public ip is a match
-func ipAllowed(publicIP string, ips []string) bool {
-	allowed := true
-	for _, v := range ips {
-		if v == "" {
-			continue
-		}
-
-		if publicIP == v {
-			// ip is allowed
-			return true
-		}
-
-		allowed = false
-	}
-
-	return allowed
-}
-
-// iterate over list of blocked ips and ensure the public ip is not a match
-func ipBlocked(publicIP string, ips []string) bool {
-	blocked := false
-
-	for _, v := range ips {
-		if v == "" {
-			continue
-		}
-
-		if publicIP == v {
-			blocked = true
-			break
-		}
-
-		blocked = false
-	}
-
-	return blocked
+func IPMonitored(publicIP string, allowed, blocked *IPMatcher) bool {
+	return (allowed.Empty() || allowed.Contains(publicIP)) && !blocked.Contains(publicIP)
 }
@@ -4,7 +4,6 @@ import (
 	"os"
 	"testing"
 
-	"github.com/imup-io/client/config"
 	"github.com/imup-io/client/util"
 	"github.com/matryer/is"
 )
@@ -60,24 +59,17 @@ func Test_BooleanValueOr(t *testing.T) {
 
 func Test_IPMonitored(t *testing.T) {
 	is := is.New(t)
-	os.Setenv("EMAIL", "test@example.com")
-
-	cfg, err := config.New()
-	is.NoErr(err)
-	is.Equal(true, util.IPMonitored("10.0.0.1", cfg.AllowedIPs(), cfg.BlockedIPs()))
-	is.Equal(true, util.IPMonitored("127.0.0.1", cfg.AllowedIPs(), cfg.BlockedIPs()))
-
-	os.Setenv("ALLOWLISTED_IPS", "192.168.1.1/32")
-	cfg, err = config.New()
-	is.NoErr(err)
-	is.Equal(true, util.IPMonitored("192.168.1.1", cfg.AllowedIPs(), cfg.BlockedIPs()))
-	is.Equal(false, util.IPMonitored("127.0.0.1", cfg.AllowedIPs(), cfg.BlockedIPs()))
-
-	os.Setenv("ALLOWLISTED_IPS", "")
-	os.Setenv("BLOCKLISTED_IPS", "127.0.0.1/32,1.1.1.1/32")
-	cfg, err = config.New()
-	is.NoErr(err)
-	is.Equal(false, util.IPMonitored("127.0.0.1", cfg.AllowedIPs(), cfg.BlockedIPs()))
-	is.Equal(true, util.IPMonitored("192.168.1.1", cfg.AllowedIPs(), cfg.BlockedIPs()))
 
+	allowed, blocked := util.NewIPMatcher(nil), util.NewIPMatcher(nil)
+	is.Equal(true, util.IPMonitored("10.0.0.1", allowed, blocked))
+	is.Equal(true, util.IPMonitored("127.0.0.1", allowed, blocked))
+
+	allowed = util.NewIPMatcher([]string{"192.168.1.1/32"})
+	is.Equal(true, util.IPMonitored("192.168.1.1", allowed, blocked))
+	is.Equal(false, util.IPMonitored("127.0.0.1", allowed, blocked))
+
+	allowed = util.NewIPMatcher([]string{""})
+	blocked = util.NewIPMatcher([]string{"127.0.0.1/32", "1.1.1.1/32"})
+	is.Equal(false, util.IPMonitored("127.0.0.1", allowed, blocked))
+	is.Equal(true, util.IPMonitored("192.168.1.1", allowed, blocked))
 }